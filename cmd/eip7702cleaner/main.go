@@ -1,48 +1,246 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"math/big"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
+	"time"
 
 	cmdpkg "github.com/ethanzhrepo/eip7702cleaner/pkg/cmd"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
 )
 
 var (
 	// 命令行标志
-	rpcURL   string
-	debug    bool
-	gasLimit uint64
+	rpcURL         string
+	debug          bool
+	gasLimit       uint64
+	interactiveGas bool
+	jsonTx         bool
+	pollViaTx      bool
+	codeMethod     string
+	chainName      bool
+	addressesFile  string
+	onlyTarget     string
+	quiet          bool
+	assumeYes      bool
+	paranoid       bool
+	configPath     string
+	safeAddress    string
+	sweepTokens    string
+	address        string
+	estimateOnly   bool
+	dryRunDiff     bool
+	fiat           string
+	confirmations  uint64
+	confirmTimeout time.Duration
+	maxWait        time.Duration
+	useRegistry    bool
+	registryAddr   string
+	yesForClean    bool
+	batch          bool
+	batchSize      int
+	batchDelay     time.Duration
+	simulate       bool
+	strict         bool
+	userAgent      string
+	rpcTimeout     time.Duration
+	broadcastAt    string
+	gasOracleURL   string
+	gasPriority    string
+	rpcFallbacks   []string
+	rpcRetries     int
+	rpcMaxAttempts int
+	otelEndpoint   string
+	verifyOnly     bool
+	expectState    string
+	expectTarget   string
+	bundleOut      string
+	allowEmpty     bool
+	allowSelf      bool
+	bumpSchedule   string
+	maxFeeCap      string
+	frontRunBlocks int
+	confirmTries   int
+	pollInterval   time.Duration
+	minRecoverable string
+	reportFile     string
+	expectedHash   string
+	callData       string
+	callValue      string
+	selfSponsor    bool
+	maxCostUSD     float64
+	maxCost        string
+	ignorePrice    bool
+	bundlePath     string
+	history        bool
+	fromBlock      uint64
+	toBlock        uint64
+	networkFile    string
+	networkName    string
+	jsonErrors     bool
+	signerURL      string
+	signerAddress  string
+	promptTimeout  time.Duration
+	outputAddrs    string
+	checkpointFile string
+	onError        string
+	feeAdvisory    bool
+	authChainID    string
+	authContract   string
+	authNonce      string
+	authR          string
+	authS          string
+	authYParity    string
+	monitorEvery   time.Duration
+	concurrency    int
+	signChainID    string
+	signNonce      string
+	signClear      bool
+	signOffline    bool
+	signOut        string
+	signQR         bool
+	signQRFile     string
+	chainTargets   []string
+	demoKeys       []string
+	noWait         bool
+	skipNodeCheck  bool
+	parallel       bool
+	keystorePath   string
+	mnemonic       bool
+	derivationPath string
+	relayerWords   bool
+	relayerPath    string
+	relayerLedger  bool
+	vaultPath      string
+	vaultField     string
+	azureKeyURL    string
+	victimKeyEnv   string
+	keyFilePath    string
+	relayerKeyEnv  string
+	fireblocksID   string
+	fireblocksCoin string
+	fireblocksNote string
+	expectAddress  string
+	authFile       string
+	offline        bool
+	offChainID     string
+	offNonce       string
+	relayerNonce   string
+	victimNonce    string
+	nonceOverride  string
+	allChainsAuth  bool
+	dryRun         bool
+	priorityFee    string
+	maxFee         string
+	txOut          string
+	noBroadcast    bool
+	relayerKeys    string
+	bumpPercent    float64
 
 	// 根命令
 	rootCmd = &cobra.Command{
-		Use:   "eip7702cleaner",
-		Short: "EIP-7702 Cleaner Tool",
-		Long:  `A command-line tool for checking and cleaning EIP-7702 contracts on Ethereum addresses.`,
+		Use:               "eip7702cleaner",
+		Short:             "EIP-7702 Cleaner Tool",
+		Long:              `A command-line tool for checking and cleaning EIP-7702 contracts on Ethereum addresses.`,
+		PersistentPreRunE: applyConfig,
+	}
+
+	// config 子命令
+	configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Manage the configuration file",
+	}
+
+	configEncryptCmd = &cobra.Command{
+		Use:   "encrypt",
+		Short: "Encrypt sensitive values (such as keyed RPC URLs) in the configuration file",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := cmdpkg.EncryptConfig(configPath); err != nil {
+				fail(err)
+			}
+		},
+	}
+
+	configShowCmd = &cobra.Command{
+		Use:   "show",
+		Short: "Show the effective configuration and where each value comes from",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			showConfig(cmd)
+		},
+	}
+
+	configDecryptCmd = &cobra.Command{
+		Use:   "decrypt",
+		Short: "Decrypt encrypted values in the configuration file",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := cmdpkg.DecryptConfig(configPath); err != nil {
+				fail(err)
+			}
+		},
 	}
 
 	// check 子命令
 	checkCmd = &cobra.Command{
-		Use:   "check [address]",
+		Use:   "check [address...]",
 		Short: "Check if an address has an EIP-7702 contract",
-		Args:  cobra.ExactArgs(1),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && addressesFile == "" {
+				return fmt.Errorf("requires at least one address or --addresses-file")
+			}
+			return nil
+		},
 		Run: func(cmd *cobra.Command, args []string) {
-			address := args[0]
+			addresses := args
+			if addressesFile != "" {
+				fromFile, err := cmdpkg.ReadAddressesFile(addressesFile)
+				if err != nil {
+					fail(err)
+				}
+				addresses = append(addresses, fromFile...)
+			}
 
 			// 仅在debug模式下显示解析信息
 			if debug {
-				fmt.Printf("Debug - Cobra parsing - Address: %s\n", address)
-				fmt.Printf("Debug - Cobra parsing - RPC URL: %s\n", rpcURL)
-				fmt.Printf("Debug - Cobra parsing - Debug: %v\n", debug)
-				fmt.Printf("Debug - Cobra parsing - Gas Limit: %d\n", gasLimit)
+				fmt.Fprintf(os.Stderr, "Debug - Cobra parsing - Addresses: %v\n", addresses)
+				fmt.Fprintf(os.Stderr, "Debug - Cobra parsing - RPC URL: %s\n", rpcURL)
+				fmt.Fprintf(os.Stderr, "Debug - Cobra parsing - Debug: %v\n", debug)
+				fmt.Fprintf(os.Stderr, "Debug - Cobra parsing - Gas Limit: %d\n", gasLimit)
+			}
+
+			opts := cmdpkg.CheckOptions{
+				RPCURL:     rpcURL,
+				Debug:      debug,
+				CodeMethod: codeMethod,
+				ChainName:  chainName,
+				OnlyTarget: onlyTarget,
+				Strict:     strict,
+
+				OutputAddresses: outputAddrs,
+
+				UseOnchainRegistry: useRegistry,
+				RegistryAddress:    registryAddr,
 			}
 
-			err := cmdpkg.Check(address, rpcURL, debug)
+			var err error
+			if len(addresses) == 1 && addressesFile == "" && onlyTarget == "" && outputAddrs == "" {
+				err = cmdpkg.Check(addresses[0], opts)
+			} else {
+				err = cmdpkg.CheckBatch(addresses, opts)
+			}
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-				os.Exit(1)
+				fail(err)
 			}
 		},
 	}
@@ -55,15 +253,33 @@ var (
 		Run: func(cmd *cobra.Command, args []string) {
 			// 仅在debug模式下显示解析信息
 			if debug {
-				fmt.Printf("Debug - Cobra parsing - RPC URL: %s\n", rpcURL)
-				fmt.Printf("Debug - Cobra parsing - Debug: %v\n", debug)
-				fmt.Printf("Debug - Cobra parsing - Gas Limit: %d\n", gasLimit)
+				fmt.Fprintf(os.Stderr, "Debug - Cobra parsing - RPC URL: %s\n", rpcURL)
+				fmt.Fprintf(os.Stderr, "Debug - Cobra parsing - Debug: %v\n", debug)
+				fmt.Fprintf(os.Stderr, "Debug - Cobra parsing - Gas Limit: %d\n", gasLimit)
+			}
+
+			opts, err := txOptions()
+			if err == nil {
+				err = cmdpkg.Clear(opts)
 			}
+			if err != nil {
+				fail(err)
+			}
+		},
+	}
 
-			err := cmdpkg.Clear(rpcURL, gasLimit)
+	// clear-all-chains 子命令
+	clearAllChainsCmd = &cobra.Command{
+		Use:   "clear-all-chains",
+		Short: "Clear the EIP-7702 delegation of one compromised key on several chains",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			opts, err := txOptions()
+			if err == nil {
+				err = cmdpkg.ClearAllChains(chainTargets, opts)
+			}
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-				os.Exit(1)
+				fail(err)
 			}
 		},
 	}
@@ -78,24 +294,708 @@ var (
 
 			// 仅在debug模式下显示解析信息
 			if debug {
-				fmt.Printf("Debug - Cobra parsing - Contract Address: %s\n", contractAddress)
-				fmt.Printf("Debug - Cobra parsing - RPC URL: %s\n", rpcURL)
-				fmt.Printf("Debug - Cobra parsing - Debug: %v\n", debug)
-				fmt.Printf("Debug - Cobra parsing - Gas Limit: %d\n", gasLimit)
+				fmt.Fprintf(os.Stderr, "Debug - Cobra parsing - Contract Address: %s\n", contractAddress)
+				fmt.Fprintf(os.Stderr, "Debug - Cobra parsing - RPC URL: %s\n", rpcURL)
+				fmt.Fprintf(os.Stderr, "Debug - Cobra parsing - Debug: %v\n", debug)
+				fmt.Fprintf(os.Stderr, "Debug - Cobra parsing - Gas Limit: %d\n", gasLimit)
+			}
+
+			opts, err := txOptions()
+			if err == nil {
+				err = cmdpkg.Set(contractAddress, opts)
+			}
+			if err != nil {
+				fail(err)
+			}
+		},
+	}
+
+	// relay 子命令
+	relayCmd = &cobra.Command{
+		Use:   "relay [auth_file]",
+		Short: "Broadcast an EIP-7702 authorization signed on another machine, paying for gas with the relayer",
+		Long: `Broadcast an EIP-7702 authorization signed elsewhere, e.g. with sign-auth on an
+air-gapped machine, in a transaction signed by the relayer. The authorization is
+read from auth_file, or from stdin when it is "-". One for the zero address
+clears the delegation, any other sets it to its address.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			opts, err := txOptions()
+			if err == nil {
+				err = cmdpkg.Relay(args[0], opts)
+			}
+			if err != nil {
+				fail(err)
+			}
+		},
+	}
+
+	// verify 子命令
+	verifyCmd = &cobra.Command{
+		Use:   "verify [address]",
+		Short: "Verify that an address is clean or delegated to an expected contract",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			opts := cmdpkg.CheckOptions{
+				RPCURL: rpcURL,
+				Debug:  debug,
+			}
+			if err := cmdpkg.Verify(args[0], expectState, expectTarget, opts); err != nil {
+				fail(err)
+			}
+		},
+	}
+
+	// audit 子命令
+	auditCmd = &cobra.Command{
+		Use:   "audit [address]",
+		Short: "Show the delegation state of an address and, with --history, its past delegation changes",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			opts := cmdpkg.CheckOptions{
+				RPCURL:     rpcURL,
+				Debug:      debug,
+				CodeMethod: codeMethod,
+			}
+			err := cmdpkg.Check(args[0], opts)
+			if err == nil && history {
+				fmt.Println()
+				err = cmdpkg.AuditHistory(args[0], fromBlock, toBlock, opts)
+			}
+			if err != nil {
+				fail(err)
+			}
+		},
+	}
+
+	// monitor 子命令
+	monitorCmd = &cobra.Command{
+		Use:   "monitor [address...]",
+		Short: "Show a live table of the delegation state and balance of a set of addresses",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && addressesFile == "" {
+				return fmt.Errorf("requires at least one address or --addresses-file")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			addresses := args
+			if addressesFile != "" {
+				fromFile, err := cmdpkg.ReadAddressesFile(addressesFile)
+				if err != nil {
+					fail(err)
+				}
+				addresses = append(addresses, fromFile...)
+			}
+
+			opts := cmdpkg.CheckOptions{
+				RPCURL:     rpcURL,
+				Debug:      debug,
+				CodeMethod: codeMethod,
+			}
+			if err := cmdpkg.MonitorAddresses(addresses, monitorEvery, concurrency, opts); err != nil {
+				fail(err)
+			}
+		},
+	}
+
+	// sign-authorization 子命令
+	signAuthorizationCmd = &cobra.Command{
+		Use:     "sign-authorization [contract_address]",
+		Aliases: []string{"sign-auth"},
+		Short:   "Sign an EIP-7702 authorization and print it as JSON, without building a transaction",
+		Args:    cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if signClear == (len(args) == 1) {
+				fail(cmdpkg.WithErrorType(cmdpkg.ErrorTypeInvalidInput, fmt.Errorf("give either a contract address or --clear")))
+			}
+			contract := common.Address{}.Hex()
+			if !signClear {
+				contract = args[0]
+			}
+			out := cmdpkg.SignAuthOptions{
+				Offline: signOffline,
+				OutFile: signOut,
+				QR:      signQR,
+				QRFile:  signQRFile,
+			}
+			opts := cmdpkg.CheckOptions{
+				RPCURL: rpcURL,
+				Debug:  debug,
+			}
+			if err := cmdpkg.SignAuthorization(contract, signChainID, signNonce, out, opts); err != nil {
+				fail(err)
+			}
+		},
+	}
+
+	// recover-authority 子命令
+	recoverAuthorityCmd = &cobra.Command{
+		Use:   "recover-authority",
+		Short: "Recover the address that signed an EIP-7702 authorization tuple",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			authority, err := cmdpkg.RecoverAuthority(authChainID, authContract, authNonce, authR, authS, authYParity)
+			if err != nil {
+				fail(err)
+			}
+			fmt.Println(authority.Hex())
+		},
+	}
+
+	// validate-tx 子命令
+	validateTxCmd = &cobra.Command{
+		Use:   "validate-tx [rawhex]",
+		Short: "Run every pre-broadcast check on a signed EIP-7702 transaction, without broadcasting it",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			opts := cmdpkg.CheckOptions{
+				RPCURL: rpcURL,
+				Debug:  debug,
+			}
+			if err := cmdpkg.ValidateTx(args[0], opts); err != nil {
+				fail(err)
+			}
+		},
+	}
+
+	// speedup 子命令
+	speedupCmd = &cobra.Command{
+		Use:   "speedup [tx_hash]",
+		Short: "Replace a pending EIP-7702 transaction with the same one paying higher fees",
+		Long: `Rebuild a pending set or clear transaction with the same nonce, call and signed
+authorizations but higher fees, have its sender (the relayer) sign it again and
+broadcast it in place of the original, which is stuck when the fees it offers
+fell behind the network. Only the sender key is needed.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			opts, err := txOptions()
+			if err == nil {
+				err = cmdpkg.Speedup(args[0], opts)
+			}
+			if err != nil {
+				fail(err)
+			}
+		},
+	}
+
+	// cancel 子命令
+	cancelCmd = &cobra.Command{
+		Use:   "cancel [tx_hash]",
+		Short: "Replace a pending transaction with a 0-value transfer to its own sender",
+		Long: `Replace a pending set or clear transaction, stuck or sent by mistake, with a
+0-value transfer from its sender (the relayer) to itself at the same nonce and
+higher fees, so that the original is never mined. Only the sender key is needed.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			opts, err := txOptions()
+			if err == nil {
+				err = cmdpkg.Cancel(args[0], opts)
+			}
+			if err != nil {
+				fail(err)
 			}
+		},
+	}
 
-			err := cmdpkg.Set(contractAddress, rpcURL, gasLimit)
+	// broadcast 子命令
+	broadcastCmd = &cobra.Command{
+		Use:   "broadcast",
+		Short: "Broadcast a reviewed bundle written by set or clear with --bundle-out",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			opts, err := txOptions()
+			if err == nil {
+				err = cmdpkg.BroadcastBundle(bundlePath, opts)
+			}
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-				os.Exit(1)
+				fail(err)
 			}
 		},
 	}
 )
 
+// applyConfig passes the global settings to the library and fills in flags that were
+// not given on the command line from the configuration file
+func applyConfig(cmd *cobra.Command, args []string) error {
+	cmdpkg.UserAgent = userAgent
+	cmdpkg.RPCTimeout = rpcTimeout
+	cmdpkg.RPCFallbackURLs = rpcFallbacks
+	cmdpkg.RPCRetries = rpcRetries
+	cmdpkg.RPCMaxAttempts = rpcMaxAttempts
+	if rpcRetries < 0 || rpcMaxAttempts < 0 {
+		return fmt.Errorf("--rpc-retries and --rpc-max-attempts cannot be negative")
+	}
+	if err := cmdpkg.StartTracing(otelEndpoint, cmd.CommandPath()); err != nil {
+		return err
+	}
+	cmdpkg.PromptTimeout = promptTimeout
+	if err := cmdpkg.SetDemoKeys(demoKeys); err != nil {
+		return err
+	}
+	if cmd.Parent() == configCmd && cmd != configShowCmd {
+		return nil
+	}
+
+	cfg, err := cmdpkg.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if networkFile != "" {
+		if err := cmdpkg.LoadNetworkFile(networkFile); err != nil {
+			return err
+		}
+	}
+	settingSources["rpc-url"] = settingSource(cmd, "rpc-url", cfg.RPCURL != "")
+	settingSources["gas-limit"] = settingSource(cmd, "gas-limit", cfg.GasLimit != 0)
+	settingSources["registry-address"] = settingSource(cmd, "registry-address", cfg.RegistryAddress != "")
+	if networkName != "" && !cmd.Flags().Changed("rpc-url") {
+		network, ok := cmdpkg.LookupNetworkByName(networkName)
+		if !ok {
+			return fmt.Errorf("unknown network %q", networkName)
+		}
+		if network.RPCURL == "" {
+			return fmt.Errorf("network %q has no RPC URL, define one with --network-file or pass --rpc-url", network.Name)
+		}
+		rpcURL = network.RPCURL
+		settingSources["rpc-url"] = "network " + network.Name
+	} else if cfg.RPCURL != "" && !cmd.Flags().Changed("rpc-url") {
+		// An encrypted value is only decrypted for a command that will contact the
+		// node, so no passphrase is asked for a value that goes unused
+		switch {
+		case cfg.RPCURLEncrypted() && (cmd == configShowCmd || !usesRPC(cmd)):
+			encryptedRPC = true
+		default:
+			if rpcURL, err = cfg.DecryptRPCURL(); err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+		}
+	}
+	if cfg.GasLimit != 0 && !cmd.Flags().Changed("gas-limit") {
+		gasLimit = cfg.GasLimit
+	}
+	if cfg.RegistryAddress != "" && !cmd.Flags().Changed("registry-address") {
+		registryAddr = cfg.RegistryAddress
+	}
+	return nil
+}
+
+// encryptedRPC is set when the encrypted rpc_url of the configuration was selected but left undecrypted
+var encryptedRPC bool
+
+// usesRPC tells whether a command may contact the node: it has an --rpc-url
+// flag and does not run with --offline
+func usesRPC(cmd *cobra.Command) bool {
+	return cmd.Flags().Lookup("rpc-url") != nil && !cmd.Flags().Changed("offline")
+}
+
+// settingSources records where applyConfig took each layered setting from, for config show
+var settingSources = map[string]string{}
+
+// settingSource tells whether a setting comes from its flag, the configuration file or the default
+func settingSource(cmd *cobra.Command, flag string, inConfig bool) string {
+	switch {
+	case cmd.Flags().Changed(flag):
+		return "flag"
+	case inConfig:
+		return "config"
+	default:
+		return "default"
+	}
+}
+
+// showConfig prints the effective settings once flags, the configuration file
+// and the environment have been applied, with secrets redacted
+func showConfig(cmd *cobra.Command) {
+	flagSource := func(name string) string {
+		if cmd.Flags().Changed(name) {
+			return "flag"
+		}
+		return "default"
+	}
+	row := func(name, value, source string) {
+		if value == "" {
+			value = "-"
+		}
+		fmt.Printf("%-18s %-58s %s\n", name, value, source)
+	}
+
+	configState := "not found"
+	if _, err := os.Stat(configPath); err == nil {
+		configState = "found"
+	}
+	effectiveRPC := rpcURL
+	if effectiveRPC == "" {
+		effectiveRPC = cmdpkg.DefaultRPCURL
+	}
+	gas := fmt.Sprint(gasLimit)
+	if gasLimit == 0 {
+		gas = fmt.Sprintf("%d + %d per authorization", cmdpkg.AuthorizationBaseGas, cmdpkg.PerAuthorizationGas)
+	}
+	agent := userAgent
+	if agent == "" {
+		agent = "eip7702cleaner/" + cmdpkg.Version
+	}
+	prompt := promptTimeout.String()
+	if promptTimeout == 0 {
+		prompt = "none (wait forever)"
+	}
+	passphrase := "not set"
+	if cmdpkg.ConfigPassphraseSet() {
+		passphrase = "set"
+	}
+
+	row("Setting", "Value", "Source")
+	row("config", configPath+" ("+configState+")", flagSource("config"))
+	if encryptedRPC {
+		row("rpc-url", "(encrypted)", settingSources["rpc-url"])
+	} else {
+		row("rpc-url", cmdpkg.RedactURL(effectiveRPC), settingSources["rpc-url"])
+	}
+	row("network", networkName, flagSource("network"))
+	row("network-file", networkFile, flagSource("network-file"))
+	row("gas-limit", gas, settingSources["gas-limit"])
+	row("registry-address", registryAddr, settingSources["registry-address"])
+	fallbacks := make([]string, len(rpcFallbacks))
+	for i, fallback := range rpcFallbacks {
+		fallbacks[i] = cmdpkg.RedactURL(fallback)
+	}
+	maxAttempts := fmt.Sprint(rpcMaxAttempts)
+	if rpcMaxAttempts == 0 {
+		maxAttempts = "no cap"
+	}
+	row("rpc-fallback-url", strings.Join(fallbacks, ", "), flagSource("rpc-fallback-url"))
+	row("rpc-retries", fmt.Sprint(rpcRetries), flagSource("rpc-retries"))
+	row("rpc-max-attempts", maxAttempts, flagSource("rpc-max-attempts"))
+	row("rpc-timeout", rpcTimeout.String(), flagSource("rpc-timeout"))
+	row("prompt-timeout", prompt, flagSource("prompt-timeout"))
+	row("user-agent", agent, flagSource("user-agent"))
+	otel := otelEndpoint
+	if otel != "" {
+		otel = cmdpkg.RedactURL(otel)
+	}
+	row("otel-endpoint", otel, flagSource("otel-endpoint"))
+	row("passphrase", passphrase, "env "+cmdpkg.ConfigPassphraseEnv)
+}
+
+// txOptions collects the flags shared by the set and clear commands. Its
+// errors are all bad flag values or combinations, reported as invalid input.
+func txOptions() (_ cmdpkg.TxOptions, err error) {
+	defer func() { err = cmdpkg.WithErrorType(cmdpkg.ErrorTypeInvalidInput, err) }()
+
+	tokens, err := cmdpkg.ParseTokenList(sweepTokens)
+	if err != nil {
+		return cmdpkg.TxOptions{}, err
+	}
+	if confirmTries < 0 {
+		return cmdpkg.TxOptions{}, fmt.Errorf("--confirm-attempts must not be negative")
+	}
+	if pollInterval <= 0 {
+		return cmdpkg.TxOptions{}, fmt.Errorf("--poll-interval must be positive")
+	}
+	schedule, err := cmdpkg.ParseBumpSchedule(bumpSchedule)
+	if err != nil {
+		return cmdpkg.TxOptions{}, err
+	}
+	var codeHash common.Hash
+	if expectedHash != "" {
+		if codeHash, err = cmdpkg.ParseCodeHash(expectedHash); err != nil {
+			return cmdpkg.TxOptions{}, err
+		}
+	}
+	var data []byte
+	if callData != "" {
+		if data, err = hexutil.Decode(callData); err != nil {
+			return cmdpkg.TxOptions{}, fmt.Errorf("invalid --data, expected 0x-prefixed hex: %w", err)
+		}
+	}
+	var value *big.Int
+	if callValue != "" {
+		if value, err = cmdpkg.ParseEther(callValue); err != nil {
+			return cmdpkg.TxOptions{}, fmt.Errorf("invalid --value: %w", err)
+		}
+	}
+	if maxCostUSD < 0 {
+		return cmdpkg.TxOptions{}, fmt.Errorf("--max-cost-usd must not be negative")
+	}
+	var costCap *big.Int
+	if maxCost != "" {
+		if costCap, err = cmdpkg.ParseEther(maxCost); err != nil {
+			return cmdpkg.TxOptions{}, fmt.Errorf("invalid --max-cost: %w", err)
+		}
+	}
+	var minValue *big.Int
+	if minRecoverable != "" {
+		if minValue, err = cmdpkg.ParseEther(minRecoverable); err != nil {
+			return cmdpkg.TxOptions{}, fmt.Errorf("invalid --abort-if-balance-below: %w", err)
+		}
+	}
+	if _, err := cmdpkg.ParseGasPriority(gasPriority); err != nil {
+		return cmdpkg.TxOptions{}, err
+	}
+	if gasPriority != "" && gasOracleURL == "" {
+		return cmdpkg.TxOptions{}, fmt.Errorf("--priority requires --gas-oracle-url")
+	}
+	var scheduled time.Time
+	if broadcastAt != "" {
+		if scheduled, err = time.Parse(time.RFC3339, broadcastAt); err != nil {
+			return cmdpkg.TxOptions{}, fmt.Errorf("invalid --broadcast-at, expected an RFC3339 time like 2006-01-02T15:04:05Z: %w", err)
+		}
+		if !scheduled.After(time.Now()) {
+			return cmdpkg.TxOptions{}, fmt.Errorf("--broadcast-at %s is not in the future", broadcastAt)
+		}
+	}
+	var feeCap *big.Int
+	if maxFeeCap != "" {
+		if feeCap, err = cmdpkg.ParseGwei(maxFeeCap); err != nil {
+			return cmdpkg.TxOptions{}, fmt.Errorf("invalid --max-fee-cap: %w", err)
+		}
+	}
+	var offlineParams *cmdpkg.OfflineParams
+	var victimOverride, relayerOverride, authOverride *uint64
+	var pinnedTip, pinnedFeeCap *big.Int
+	if offline {
+		// --victim-nonce is another name for --nonce, which --offline requires
+		nonce := offNonce
+		if victimNonce != "" {
+			if offNonce != "" {
+				return cmdpkg.TxOptions{}, fmt.Errorf("--victim-nonce and --nonce cannot be combined, they give the same nonce")
+			}
+			nonce = victimNonce
+		}
+		if offlineParams, err = cmdpkg.ParseOfflineParams(offChainID, nonce, relayerNonce, priorityFee, maxFee, selfSponsor); err != nil {
+			return cmdpkg.TxOptions{}, err
+		}
+	} else {
+		if offChainID != "" || offNonce != "" {
+			return cmdpkg.TxOptions{}, fmt.Errorf("--chain-id and --nonce require --offline")
+		}
+		if priorityFee != "" {
+			if pinnedTip, err = cmdpkg.ParseGwei(priorityFee); err != nil {
+				return cmdpkg.TxOptions{}, fmt.Errorf("invalid --priority-fee: %w", err)
+			}
+		}
+		if maxFee != "" {
+			if pinnedFeeCap, err = cmdpkg.ParseGwei(maxFee); err != nil {
+				return cmdpkg.TxOptions{}, fmt.Errorf("invalid --max-fee: %w", err)
+			}
+		}
+		if pinnedTip != nil && pinnedFeeCap != nil && pinnedFeeCap.Cmp(pinnedTip) < 0 {
+			return cmdpkg.TxOptions{}, fmt.Errorf("--max-fee %s Gwei is below --priority-fee %s Gwei", maxFee, priorityFee)
+		}
+		if (pinnedTip != nil || pinnedFeeCap != nil) && gasOracleURL != "" {
+			return cmdpkg.TxOptions{}, fmt.Errorf("--max-fee and --priority-fee cannot be combined with --gas-oracle-url, which would be ignored")
+		}
+		if victimNonce != "" {
+			if victimOverride, err = cmdpkg.ParseNonce("--victim-nonce", victimNonce); err != nil {
+				return cmdpkg.TxOptions{}, err
+			}
+		}
+		if relayerNonce != "" {
+			if relayerOverride, err = cmdpkg.ParseNonce("--relayer-nonce", relayerNonce); err != nil {
+				return cmdpkg.TxOptions{}, err
+			}
+		}
+	}
+	if nonceOverride != "" {
+		if authOverride, err = cmdpkg.ParseNonce("--auth-nonce", nonceOverride); err != nil {
+			return cmdpkg.TxOptions{}, err
+		}
+	}
+
+	return cmdpkg.TxOptions{
+		Context:            rootCmd.Context(),
+		RPCURL:             rpcURL,
+		GasLimit:           gasLimit,
+		InteractiveGas:     interactiveGas,
+		JSONTx:             jsonTx,
+		PollViaTxLookup:    pollViaTx,
+		Paranoid:           paranoid,
+		Quiet:              quiet,
+		Yes:                assumeYes,
+		SelfSponsor:        selfSponsor,
+		Keystore:           keystorePath,
+		RelayerKeystore:    relayerKeys,
+		Mnemonic:           mnemonic,
+		HDPath:             derivationPath,
+		RelayerMnemonic:    relayerWords,
+		RelayerHDPath:      relayerPath,
+		RelayerLedger:      relayerLedger,
+		RelayerVaultPath:   vaultPath,
+		RelayerVaultField:  vaultField,
+		RelayerAzureKey:    azureKeyURL,
+		KeyEnv:             victimKeyEnv,
+		KeyFile:            keyFilePath,
+		RelayerKeyEnv:      relayerKeyEnv,
+		AuthFile:           authFile,
+		MaxCostUSD:         maxCostUSD,
+		MaxCost:            costCap,
+		IgnorePriceFailure: ignorePrice,
+		FeeAdvisory:        feeAdvisory,
+		SkipNodeCheck:      skipNodeCheck,
+		GasOracleURL:       gasOracleURL,
+		GasPriority:        gasPriority,
+		GasTip:             pinnedTip,
+		GasFeeCap:          pinnedFeeCap,
+
+		RelayerSignerURL:     signerURL,
+		RelayerSignerAddress: signerAddress,
+		FireblocksVault:      fireblocksID,
+		FireblocksAsset:      fireblocksCoin,
+		FireblocksNote:       fireblocksNote,
+		ExpectAddress:        expectAddress,
+		AssumeYesForClean:    yesForClean,
+		AllowEmptyTarget:     allowEmpty,
+		AllowSelfTarget:      allowSelf,
+		ExpectedCodeHash:     codeHash,
+		CallData:             data,
+		CallValue:            value,
+		Batch:                batch,
+		BatchSize:            batchSize,
+		BatchDelay:           batchDelay,
+		CheckpointFile:       checkpointFile,
+		OnError:              onError,
+		NoWait:               noWait,
+		Parallel:             parallel,
+		Confirmations:        confirmations,
+		ConfirmTimeout:       confirmTimeout,
+		ConfirmAttempts:      confirmTries,
+		PollInterval:         pollInterval,
+		MaxWait:              maxWait,
+		BumpSchedule:         schedule,
+		MaxFeeCap:            feeCap,
+		MinRecoverable:       minValue,
+		ReportFile:           reportFile,
+		FrontRunBlocks:       frontRunBlocks,
+		SafeAddress:          safeAddress,
+		SweepTokens:          tokens,
+		Address:              address,
+		EstimateOnly:         estimateOnly,
+		DryRunDiff:           dryRunDiff,
+		SimulateOverride:     simulate,
+		VerifyOnly:           verifyOnly,
+		Fiat:                 fiat,
+		BundleOut:            bundleOut,
+		TxOut:                txOut,
+		NoBroadcast:          noBroadcast,
+		BroadcastAt:          scheduled,
+		Bump:                 bumpPercent,
+		VictimNonce:          victimOverride,
+		RelayerNonce:         relayerOverride,
+		AuthNonce:            authOverride,
+		AllChainsAuth:        allChainsAuth,
+		DryRun:               dryRun,
+
+		Offline: offlineParams,
+	}, nil
+}
+
+// addTxFlags registers the flags shared by the set and clear commands
+func addTxFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&interactiveGas, "interactive-gas", false, "Review and adjust the suggested gas fees before confirming")
+	cmd.Flags().BoolVar(&jsonTx, "json-tx", false, "Print the signed transaction in EIP-2718 typed transaction JSON form")
+	cmd.Flags().BoolVar(&pollViaTx, "poll-receipt-via-logs", false, "Detect inclusion via eth_getTransactionByHash before fetching the receipt")
+	cmd.Flags().BoolVar(&paranoid, "paranoid", false, "Re-decode the signed transaction and verify it matches what was confirmed before broadcasting")
+	cmd.Flags().BoolVar(&quiet, "quiet", false, "Only show the gas summary, the confirmation prompt and the final result")
+	cmd.Flags().BoolVar(&quiet, "summary-only", false, "Alias for --quiet")
+	cmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Skip the confirmation prompt")
+	cmd.Flags().BoolVar(&selfSponsor, "relayer-same-as-user", false, "Pay for gas from the authorizing address itself, prompting for a single key")
+	cmd.Flags().BoolVar(&selfSponsor, "self-relay", false, "Alias for --relayer-same-as-user")
+	cmd.Flags().StringVar(&victimKeyEnv, "victim-key-env", "", "Read the hex account key from this environment variable instead of prompting, for unattended runs with --yes")
+	cmd.Flags().StringVar(&keyFilePath, "key-file", "", "Read the hex account key from this file instead of prompting, for unattended runs with --yes")
+	cmd.Flags().StringVar(&relayerKeyEnv, "relayer-key-env", "", "Read the hex relayer key from this environment variable instead of prompting")
+	cmd.Flags().StringVar(&keystorePath, "keystore", "", "Encrypted keystore file (UTC/JSON V3) of the account key, prompting for its passphrase instead of the hex key")
+	cmd.Flags().StringVar(&relayerKeys, "relayer-keystore", "", "Encrypted keystore file (UTC/JSON V3) of the relayer key")
+	cmd.Flags().BoolVar(&mnemonic, "mnemonic", false, "Derive the account key from a BIP-39 mnemonic phrase, with an optional passphrase, instead of the hex key")
+	cmd.Flags().StringVar(&derivationPath, "derivation-path", "", "BIP-32 derivation path of the account key with --mnemonic (default "+cmdpkg.DefaultDerivationPath+")")
+	cmd.Flags().BoolVar(&relayerWords, "relayer-mnemonic", false, "Derive the relayer key from a BIP-39 mnemonic phrase")
+	cmd.Flags().StringVar(&relayerPath, "relayer-derivation-path", "", "BIP-32 derivation path of the relayer key with --relayer-mnemonic or --relayer-ledger (default "+cmdpkg.DefaultDerivationPath+")")
+	cmd.Flags().StringVar(&vaultPath, "relayer-vault-path", "", "Read the relayer key from this HashiCorp Vault KV secret, e.g. secret/data/relayer, using VAULT_ADDR and VAULT_TOKEN")
+	cmd.Flags().StringVar(&vaultField, "relayer-vault-field", "", "Field of the Vault secret holding the hex relayer key (default "+cmdpkg.DefaultVaultField+")")
+	cmd.Flags().StringVar(&azureKeyURL, "relayer-azure-key", "", "Sign the transaction with this Azure Key Vault key, e.g. https://my-vault.vault.azure.net/keys/relayer, an EC key on the P-256K curve")
+	cmd.Flags().StringVar(&fireblocksID, "relayer-fireblocks-vault", "", "Sign the transaction with the RAW signing API of this Fireblocks vault account ID, using FIREBLOCKS_API_KEY and FIREBLOCKS_API_SECRET_PATH")
+	cmd.Flags().StringVar(&fireblocksCoin, "fireblocks-asset", "", "Asset of the Fireblocks vault account whose key signs, e.g. ETH_TEST5 on Sepolia (default "+cmdpkg.DefaultFireblocksAsset+")")
+	cmd.Flags().StringVar(&fireblocksNote, "fireblocks-note", "", "Note attached to the Fireblocks signing request, shown to approvers and kept in the audit trail")
+	cmd.Flags().BoolVar(&relayerLedger, "relayer-ledger", false, "Sign the transaction with the relayer account of a Ledger connected over USB, with the Ethereum app open")
+	cmd.Flags().StringVar(&signerURL, "relayer-signer-url", "", "JSON-RPC endpoint of a remote signer holding the relayer key, instead of prompting for it")
+	cmd.Flags().StringVar(&signerAddress, "relayer-signer-address", "", "Relayer account of the remote signer (default: its only account)")
+	cmd.Flags().StringVar(&authFile, "auth-file", "", "Use the authorization signed in this JSON file, as printed by sign-authorization, instead of the account key")
+	cmd.Flags().StringVar(&expectAddress, "expect-address", "", "Abort right after key entry, before anything is signed, unless the account key is for this address")
+	cmd.Flags().StringVar(&address, "address", "", "Address whose delegation changes, for read-only steps that run without its private key")
+	cmd.Flags().BoolVar(&offline, "offline", false, "Sign without contacting a node and print the raw transaction, taking the chain ID, nonces and fees from the flags below")
+	cmd.Flags().StringVar(&offChainID, "chain-id", "", "With --offline, chain ID to sign for")
+	cmd.Flags().StringVar(&offNonce, "nonce", "", "With --offline, current nonce of the account whose delegation changes")
+	cmd.Flags().StringVar(&relayerNonce, "relayer-nonce", "", "Nonce of the relayer transaction instead of the node's pending count, e.g. to replace a stuck one; required with --offline")
+	cmd.Flags().StringVar(&victimNonce, "victim-nonce", "", "Nonce of the account whose delegation changes instead of the node's latest count; same as --nonce with --offline")
+	cmd.Flags().BoolVar(&allChainsAuth, "all-chains-auth", false, "Sign the authorization for chain ID 0, valid on every chain where the account has the same nonce (replayable by anyone)")
+	cmd.Flags().StringVar(&nonceOverride, "auth-nonce", "", "Sign the authorization for this nonce instead of the account's, e.g. to pre-authorize a future nonce")
+	cmd.Flags().StringVar(&priorityFee, "priority-fee", "", "Max priority fee per gas in Gwei instead of the network's suggestion; required with --offline")
+	cmd.Flags().StringVar(&priorityFee, "max-priority-fee", "", "Alias for --priority-fee")
+	cmd.Flags().StringVar(&maxFee, "max-fee", "", "Max fee per gas in Gwei instead of the network's suggestion; required with --offline")
+	cmd.Flags().StringVar(&bundleOut, "bundle-out", "", "Write the signed transaction and its artifacts to this file for review instead of broadcasting it")
+	cmd.Flags().StringVar(&txOut, "out", "", "Write the raw signed transaction as hex to this file instead of broadcasting it, for submission through another channel")
+	cmd.Flags().BoolVar(&noBroadcast, "no-broadcast", false, "Print the raw signed transaction as hex on stdout instead of broadcasting it")
+	cmd.Flags().StringVar(&broadcastAt, "broadcast-at", "", "Sign now but broadcast at this RFC3339 time, after checking the nonces did not change")
+	cmd.Flags().BoolVar(&verifyOnly, "verify-only", false, "Only verify that --address is already in the state the command would produce")
+	cmd.Flags().BoolVar(&batch, "batch", false, "Process several accounts with one relayer: its key is entered once, then account keys until an empty line")
+	cmd.Flags().IntVar(&batchSize, "batch-size", 1, "With --batch, broadcast this many transactions before waiting for their receipts")
+	cmd.Flags().DurationVar(&batchDelay, "batch-delay", 0, "With --batch, pause between two broadcasts (e.g. 2s)")
+	cmd.Flags().StringVar(&onError, "on-error", "", "With --batch, what a failed broadcast does: skip (default), halt or reuse-nonce")
+	cmd.Flags().StringVar(&checkpointFile, "checkpoint-file", "", "With --batch, record progress in this file and skip accounts already processed by a previous run")
+	cmd.Flags().BoolVar(&noWait, "no-wait", false, "Return once the transaction is broadcast, printing its hash, without waiting for it to be mined")
+	cmd.Flags().Uint64Var(&confirmations, "confirmations", 1, "Number of blocks the transaction must be buried under before it counts as confirmed")
+	cmd.Flags().DurationVar(&confirmTimeout, "confirm-timeout", cmdpkg.DefaultConfirmTimeout, "How long to wait for the transaction to be confirmed")
+	cmd.Flags().IntVar(&confirmTries, "confirm-attempts", 0, "Wait for confirmation during this many polls, i.e. attempts x --poll-interval (overrides --confirm-timeout); 0 waits for --confirm-timeout")
+	cmd.Flags().DurationVar(&pollInterval, "poll-interval", cmdpkg.DefaultPollInterval, "Delay between two status checks of a pending transaction")
+	cmd.Flags().DurationVar(&maxWait, "max-wait", 0, "Overall deadline for broadcasting and confirming all transactions of the operation (0 for none)")
+	cmd.Flags().Float64Var(&maxCostUSD, "max-cost-usd", 0, "Abort before signing when the estimated max gas cost exceeds this many US dollars")
+	cmd.Flags().StringVar(&maxCost, "max-cost", "", "Abort before signing when the estimated max gas cost exceeds this amount of ETH; also the fallback when no USD price is available")
+	cmd.Flags().BoolVar(&skipNodeCheck, "skip-node-check", false, "Skip the check, before key entry, that the node's client version and the chain's fork support EIP-7702")
+	cmd.Flags().BoolVar(&feeAdvisory, "fee-advisory", false, "Before key entry, show whether the network is congested from the recent base fee trend")
+	cmd.Flags().StringVar(&gasOracleURL, "gas-oracle-url", "", "Take the gas fees from this oracle (JSON standard/fast/instant tiers in Gwei) instead of the node")
+	cmd.Flags().StringVar(&gasPriority, "priority", "", "Tier of the gas oracle to use: standard (default), fast or instant")
+	cmd.Flags().BoolVar(&ignorePrice, "ignore-price-failure", false, "Proceed without the --max-cost-usd cap when the USD price cannot be fetched")
+	cmd.Flags().StringVar(&bumpSchedule, "bump-schedule", "", "Resubmit a transaction still pending after --confirm-timeout with the fees bumped by these percentages in turn (e.g. 12,25,50)")
+	cmd.Flags().StringVar(&maxFeeCap, "max-fee-cap", "", "Highest max fee per gas in Gwei a resubmission may use")
+}
+
+// addReplacementFlags registers the flags of the commands that replace a
+// pending transaction of the relayer
+func addReplacementFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&rpcURL, "rpc-url", "", "RPC URL for Ethereum node")
+	cmd.Flags().BoolVar(&debug, "debug", false, "Enable debug output")
+	cmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Skip the confirmation prompt")
+	cmd.Flags().BoolVar(&quiet, "quiet", false, "Only show the summary, the confirmation prompt and the final result")
+	cmd.Flags().StringVar(&priorityFee, "priority-fee", "", "Max priority fee per gas in Gwei of the replacement, at least 10% over the pending transaction's")
+	cmd.Flags().StringVar(&maxFee, "max-fee", "", "Max fee per gas in Gwei of the replacement, at least 10% over the pending transaction's")
+	cmd.Flags().StringVar(&maxFeeCap, "max-fee-cap", "", "Highest max fee per gas in Gwei the bumped fees may reach")
+	cmd.Flags().StringVar(&relayerKeys, "relayer-keystore", "", "Encrypted keystore file (UTC/JSON V3) of the sender key")
+	cmd.Flags().StringVar(&relayerKeyEnv, "relayer-key-env", "", "Read the hex sender key from this environment variable instead of prompting")
+	cmd.Flags().BoolVar(&relayerWords, "relayer-mnemonic", false, "Derive the sender key from a BIP-39 mnemonic phrase")
+	cmd.Flags().StringVar(&relayerPath, "relayer-derivation-path", "", "BIP-32 derivation path of the sender key with --relayer-mnemonic or --relayer-ledger (default "+cmdpkg.DefaultDerivationPath+")")
+	cmd.Flags().BoolVar(&relayerLedger, "relayer-ledger", false, "Sign with the sender account of a Ledger connected over USB, with the Ethereum app open")
+	cmd.Flags().StringVar(&signerURL, "relayer-signer-url", "", "JSON-RPC endpoint of a remote signer holding the sender key, instead of prompting for it")
+	cmd.Flags().StringVar(&signerAddress, "relayer-signer-address", "", "Sender account of the remote signer (default: its only account)")
+	cmd.Flags().StringVar(&txOut, "out", "", "Write the raw signed replacement as hex to this file instead of broadcasting it")
+	cmd.Flags().BoolVar(&noBroadcast, "no-broadcast", false, "Print the raw signed replacement as hex on stdout instead of broadcasting it")
+	cmd.Flags().BoolVar(&noWait, "no-wait", false, "Return once the replacement is broadcast, without waiting for it to be mined")
+	cmd.Flags().Uint64Var(&confirmations, "confirmations", 1, "Number of blocks the replacement must be buried under before it counts as confirmed")
+	cmd.Flags().DurationVar(&confirmTimeout, "confirm-timeout", cmdpkg.DefaultConfirmTimeout, "How long to wait for the replacement to be confirmed")
+	cmd.Flags().DurationVar(&pollInterval, "poll-interval", cmdpkg.DefaultPollInterval, "Delay between two status checks of a pending transaction")
+	cmd.Flags().DurationVar(&maxWait, "max-wait", 0, "Overall deadline for broadcasting and confirming the replacement (0 for none)")
+}
+
 func init() {
 	checkCmd.Flags().StringVar(&rpcURL, "rpc-url", "", "RPC URL for Ethereum node")
 	checkCmd.Flags().BoolVar(&debug, "debug", false, "Enable debug output")
+	checkCmd.Flags().StringVar(&codeMethod, "code-method", cmdpkg.DefaultCodeMethod, "RPC method used to fetch the account code")
+	checkCmd.Flags().BoolVar(&chainName, "chain-name", false, "Show the name of the chain the RPC endpoint is connected to")
+	checkCmd.Flags().StringVar(&addressesFile, "addresses-file", "", "File with addresses to check, one per line")
+	checkCmd.Flags().StringVar(&onlyTarget, "only-target", "", "Only report addresses delegated to this contract")
+	checkCmd.Flags().StringVar(&outputAddrs, "output-addresses", "", "Only print the addresses in this state, one per line: delegated, clean or has-code")
+	checkCmd.Flags().BoolVar(&strict, "strict", false, "Exit with an error when an address has code that is not an EIP-7702 delegation")
+	checkCmd.Flags().BoolVar(&useRegistry, "use-onchain-registry", false, "Ask an on-chain abuse registry whether delegation targets are flagged")
+	checkCmd.Flags().StringVar(&registryAddr, "registry-address", "", "Registry contract implementing isFlagged(address) returns (bool)")
 
 	clearCmd.Flags().StringVar(&rpcURL, "rpc-url", "", "RPC URL for Ethereum node")
 	clearCmd.Flags().BoolVar(&debug, "debug", false, "Enable debug output")
@@ -103,34 +1003,244 @@ func init() {
 	setCmd.Flags().StringVar(&rpcURL, "rpc-url", "", "RPC URL for Ethereum node")
 	setCmd.Flags().BoolVar(&debug, "debug", false, "Enable debug output")
 
-	rootCmd.PersistentFlags().Uint64Var(&gasLimit, "gas-limit", 100000, "Gas limit for transactions")
+	relayCmd.Flags().StringVar(&rpcURL, "rpc-url", "", "RPC URL for Ethereum node")
+	relayCmd.Flags().BoolVar(&debug, "debug", false, "Enable debug output")
+
+	verifyCmd.Flags().StringVar(&rpcURL, "rpc-url", "", "RPC URL for Ethereum node")
+	verifyCmd.Flags().BoolVar(&debug, "debug", false, "Enable debug output")
+	verifyCmd.Flags().StringVar(&expectState, "expect", "clean", "Expected state: clean or delegated")
+	verifyCmd.Flags().StringVar(&expectTarget, "to", "", "Expected delegation target with --expect delegated")
+
+	auditCmd.Flags().StringVar(&rpcURL, "rpc-url", "", "RPC URL for Ethereum node")
+	auditCmd.Flags().BoolVar(&debug, "debug", false, "Enable debug output")
+	auditCmd.Flags().StringVar(&codeMethod, "code-method", cmdpkg.DefaultCodeMethod, "RPC method used to fetch the account code")
+	auditCmd.Flags().BoolVar(&history, "history", false, "Reconstruct the timeline of delegation changes (requires an archive node)")
+	auditCmd.Flags().Uint64Var(&fromBlock, "from-block", 0, "First block of the history scan")
+	auditCmd.Flags().Uint64Var(&toBlock, "to-block", 0, "Last block of the history scan (0 for the latest block)")
+
+	monitorCmd.Flags().StringVar(&rpcURL, "rpc-url", "", "RPC URL for Ethereum node")
+	monitorCmd.Flags().BoolVar(&debug, "debug", false, "Enable debug output")
+	monitorCmd.Flags().StringVar(&codeMethod, "code-method", cmdpkg.DefaultCodeMethod, "RPC method used to fetch the account code")
+	monitorCmd.Flags().StringVar(&addressesFile, "addresses-file", "", "File with addresses to monitor, one per line")
+	monitorCmd.Flags().DurationVar(&monitorEvery, "interval", cmdpkg.DefaultMonitorInterval, "Time between two refreshes")
+	monitorCmd.Flags().IntVar(&concurrency, "concurrency", cmdpkg.DefaultMonitorConcurrency, "Number of addresses read at once")
+
+	signAuthorizationCmd.Flags().StringVar(&rpcURL, "rpc-url", "", "RPC URL for Ethereum node")
+	signAuthorizationCmd.Flags().BoolVar(&debug, "debug", false, "Enable debug output")
+	signAuthorizationCmd.Flags().StringVar(&signChainID, "chain-id", "", "Chain ID to sign for, 0 for every chain (default: the node's)")
+	signAuthorizationCmd.Flags().StringVar(&signNonce, "nonce", "", "Nonce to sign for (default: the account's current nonce)")
+	signAuthorizationCmd.Flags().BoolVar(&signClear, "clear", false, "Sign the authorization that clears the delegation, i.e. for the zero address")
+	signAuthorizationCmd.Flags().BoolVar(&signOffline, "offline", false, "Never contact a node, for air-gapped machines; requires --chain-id and --nonce")
+	signAuthorizationCmd.Flags().StringVar(&signOut, "out", "", "Also write the authorization JSON to this file, for clear/set --auth-file on the online machine")
+	signAuthorizationCmd.Flags().BoolVar(&signQR, "qr", false, "Also show the authorization JSON as a QR code on the terminal")
+	signAuthorizationCmd.Flags().StringVar(&signQRFile, "qr-png", "", "Also write the authorization JSON as a QR code to this PNG file")
+
+	validateTxCmd.Flags().StringVar(&rpcURL, "rpc-url", "", "RPC URL for Ethereum node")
+	validateTxCmd.Flags().BoolVar(&debug, "debug", false, "Enable debug output")
+
+	recoverAuthorityCmd.Flags().StringVar(&authChainID, "chain-id", "", "Chain ID of the authorization (0 for any chain)")
+	recoverAuthorityCmd.Flags().StringVar(&authContract, "contract", "", "Delegation target of the authorization")
+	recoverAuthorityCmd.Flags().StringVar(&authNonce, "nonce", "", "Nonce of the authorization")
+	recoverAuthorityCmd.Flags().StringVar(&authR, "r", "", "Signature r value")
+	recoverAuthorityCmd.Flags().StringVar(&authS, "s", "", "Signature s value")
+	recoverAuthorityCmd.Flags().StringVar(&authYParity, "y-parity", "", "Signature y parity (0 or 1)")
+	for _, name := range []string{"chain-id", "contract", "nonce", "r", "s", "y-parity"} {
+		recoverAuthorityCmd.MarkFlagRequired(name)
+	}
+
+	configShowCmd.Flags().StringVar(&rpcURL, "rpc-url", "", "RPC URL for Ethereum node")
+	configShowCmd.Flags().StringVar(&registryAddr, "registry-address", "", "Registry contract implementing isFlagged(address) returns (bool)")
+
+	broadcastCmd.Flags().StringVar(&rpcURL, "rpc-url", "", "RPC URL for Ethereum node")
+	broadcastCmd.Flags().BoolVar(&debug, "debug", false, "Enable debug output")
+	broadcastCmd.Flags().StringVar(&bundlePath, "bundle", "", "Bundle file written with --bundle-out")
+	broadcastCmd.MarkFlagRequired("bundle")
+	broadcastCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Skip the confirmation prompt")
+	broadcastCmd.Flags().BoolVar(&quiet, "quiet", false, "Only show the summary, the confirmation prompt and the final result")
+	broadcastCmd.Flags().Uint64Var(&confirmations, "confirmations", 1, "Number of blocks the transaction must be buried under before it counts as confirmed")
+	broadcastCmd.Flags().DurationVar(&confirmTimeout, "confirm-timeout", cmdpkg.DefaultConfirmTimeout, "How long to wait for the transaction to be confirmed")
+	broadcastCmd.Flags().DurationVar(&pollInterval, "poll-interval", cmdpkg.DefaultPollInterval, "Delay between two status checks of a pending transaction")
+	broadcastCmd.Flags().DurationVar(&maxWait, "max-wait", 0, "Overall deadline for broadcasting and confirming the transaction (0 for none)")
+
+	clearAllChainsCmd.Flags().StringSliceVar(&chainTargets, "networks", nil, "Comma separated networks from --network-file, or RPC URLs, to clear on")
+	clearAllChainsCmd.MarkFlagRequired("networks")
+	clearAllChainsCmd.Flags().BoolVar(&allChainsAuth, "all-chains-auth", false, "Sign each authorization for chain ID 0, valid on every chain where the victim has the same nonce (replayable by anyone)")
+	clearAllChainsCmd.Flags().BoolVar(&parallel, "parallel", false, "Wait for the clears of all chains at once instead of one after the other")
+	clearAllChainsCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Skip the confirmation prompt on every chain")
+	clearAllChainsCmd.Flags().BoolVar(&quiet, "quiet", false, "Only show the gas summary, the confirmation prompt and the final result")
+	clearAllChainsCmd.Flags().BoolVar(&paranoid, "paranoid", false, "Re-decode the signed transaction and verify it matches what was confirmed before broadcasting")
+	clearAllChainsCmd.Flags().BoolVar(&selfSponsor, "relayer-same-as-user", false, "Pay for gas from the victim address itself, prompting for a single key")
+	clearAllChainsCmd.Flags().BoolVar(&selfSponsor, "self-relay", false, "Alias for --relayer-same-as-user")
+	clearAllChainsCmd.Flags().StringVar(&signerURL, "relayer-signer-url", "", "JSON-RPC endpoint of a remote signer holding the relayer key, instead of prompting for it")
+	clearAllChainsCmd.Flags().StringVar(&signerAddress, "relayer-signer-address", "", "Relayer account of the remote signer (default: its only account)")
+	clearAllChainsCmd.Flags().Uint64Var(&confirmations, "confirmations", 1, "Number of blocks the transaction must be buried under before it counts as confirmed")
+	clearAllChainsCmd.Flags().DurationVar(&confirmTimeout, "confirm-timeout", cmdpkg.DefaultConfirmTimeout, "How long to wait for each transaction to be confirmed")
+	clearAllChainsCmd.Flags().DurationVar(&pollInterval, "poll-interval", cmdpkg.DefaultPollInterval, "Delay between two status checks of a pending transaction")
+
+	addTxFlags(clearCmd)
+	addTxFlags(setCmd)
+	addTxFlags(relayCmd)
+	for _, cmd := range []*cobra.Command{speedupCmd, cancelCmd} {
+		addReplacementFlags(cmd)
+		cmd.Flags().Float64Var(&bumpPercent, "bump", cmdpkg.DefaultReplacementBump, "Fee increase in percent over the pending transaction, at least 10")
+	}
+	for _, cmd := range []*cobra.Command{setCmd, relayCmd} {
+		cmd.Flags().StringVar(&expectedHash, "expected-code-hash", "", "Abort unless the keccak256 of the target's code equals this hash")
+		cmd.Flags().BoolVar(&allowEmpty, "allow-empty-target", false, "Allow delegating to an address that has no contract code")
+		cmd.Flags().BoolVar(&allowSelf, "allow-self-delegation", false, "Allow delegating the authorized address to itself")
+	}
+	for _, cmd := range []*cobra.Command{clearCmd, relayCmd} {
+		cmd.Flags().StringVar(&reportFile, "report-file", "", "Write a rescue report to this file once the clear completes (Markdown for .md, JSON otherwise)")
+		cmd.Flags().IntVar(&frontRunBlocks, "front-run-blocks", 3, "After the clear is mined, scan this many following blocks for a re-delegation of the victim (0 to skip)")
+		cmd.Flags().BoolVar(&yesForClean, "assume-yes-for-clean", false, "Succeed without signing anything when the account has no delegation, and only ask for confirmation when it has one")
+	}
+	setCmd.Flags().StringVar(&callData, "data", "", "Hex calldata of a call to the authorized address in the same transaction, run with the contract's code, e.g. initialize(owner); its gas is estimated unless --gas-limit is given")
+	setCmd.Flags().StringVar(&callValue, "value", "", "ETH sent by the relayer to the authorized address with that call (e.g. 0.01)")
+	clearCmd.Flags().StringVar(&minRecoverable, "abort-if-balance-below", "", "Abort when the victim's recoverable native value after sweep gas is below this amount (e.g. 0.01)")
+	clearCmd.Flags().StringVar(&safeAddress, "safe-address", "", "After a successful clear, sweep the victim's remaining ETH to this address")
+	clearCmd.Flags().StringVar(&sweepTokens, "sweep-tokens", "", "Comma separated ERC-20 tokens to sweep to --safe-address before the ETH")
+	clearCmd.Flags().BoolVar(&estimateOnly, "estimate-only", false, "Only report the recoverable value against the rescue's gas cost (read-only, needs --address)")
+	clearCmd.Flags().BoolVar(&dryRunDiff, "dry-run-diff", false, "Only show the account state before and after the clear (read-only, needs --address)")
+	clearCmd.Flags().BoolVar(&simulate, "simulate-with-state-override", false, "Simulate the sweep as if the delegation were cleared, without broadcasting (read-only, needs --address and --safe-address)")
+	clearCmd.Flags().StringVar(&fiat, "fiat", "", "Also show values in this fiat currency (e.g. usd)")
+
+	rootCmd.PersistentFlags().Uint64Var(&gasLimit, "gas-limit", 0, "Gas limit for transactions (default: 75000 plus 25000 per authorization)")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Build and sign the transaction locally and print every decoded field instead of broadcasting it")
+	rootCmd.PersistentFlags().StringArrayVar(&rpcFallbacks, "rpc-fallback-url", nil, "RPC endpoint to fail over to when the RPC URL cannot be used (repeatable, tried in order)")
+	rootCmd.PersistentFlags().IntVar(&rpcRetries, "rpc-retries", 0, "Retries of a transient RPC failure on the same endpoint before failing over")
+	rootCmd.PersistentFlags().IntVar(&rpcMaxAttempts, "rpc-max-attempts", 0, "Cap on the attempts of a single RPC call across all endpoints (0 for no cap)")
+	rootCmd.PersistentFlags().DurationVar(&rpcTimeout, "rpc-timeout", cmdpkg.DefaultRPCTimeout, "Timeout of a single RPC call, independent of --max-wait")
+	rootCmd.PersistentFlags().StringVar(&userAgent, "user-agent", "", "User-Agent sent with RPC requests (default \"eip7702cleaner/<version>\")")
+	rootCmd.PersistentFlags().StringVar(&otelEndpoint, "otel-endpoint", "", "Export OpenTelemetry traces of the command and its RPC calls to this OTLP/HTTP collector (e.g. http://localhost:4318)")
+	rootCmd.PersistentFlags().StringArrayVar(&demoKeys, "demo-key", nil, "INSECURE: well-known private key used instead of the next key prompt, for reproducible demos (repeatable, refused on chain ID 1)")
+	rootCmd.PersistentFlags().MarkHidden("demo-key")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", cmdpkg.DefaultConfigPath(), "Path to the configuration file")
+	// Runs once flags are parsed, before arguments are validated
+	cobra.OnInitialize(func() {
+		if jsonErrors {
+			// fail prints the error, cobra must not print it and the usage as well
+			rootCmd.SilenceErrors = true
+			rootCmd.SilenceUsage = true
+		}
+	})
+	rootCmd.PersistentFlags().DurationVar(&promptTimeout, "prompt-timeout", 0, "Abort when an interactive prompt receives no input within this duration (0 waits forever)")
+	rootCmd.PersistentFlags().BoolVar(&jsonErrors, "json", false, "Report errors as a JSON object on stdout instead of text on stderr")
+	rootCmd.PersistentFlags().StringVar(&networkFile, "network-file", "", "JSON file with custom network definitions, merged with the built-in ones")
+	rootCmd.PersistentFlags().StringVar(&networkName, "network", "", "Use the RPC URL of this network from --network-file (--rpc-url takes precedence)")
+
+	configCmd.AddCommand(configEncryptCmd)
+	configCmd.AddCommand(configDecryptCmd)
+	configCmd.AddCommand(configShowCmd)
 
 	rootCmd.AddCommand(checkCmd)
 	rootCmd.AddCommand(clearCmd)
+	rootCmd.AddCommand(clearAllChainsCmd)
 	rootCmd.AddCommand(setCmd)
+	rootCmd.AddCommand(relayCmd)
+	rootCmd.AddCommand(speedupCmd)
+	rootCmd.AddCommand(cancelCmd)
+	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(auditCmd)
+	rootCmd.AddCommand(monitorCmd)
+	rootCmd.AddCommand(signAuthorizationCmd)
+	rootCmd.AddCommand(recoverAuthorityCmd)
+	rootCmd.AddCommand(validateTxCmd)
+	rootCmd.AddCommand(broadcastCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+// restoreTerminal puts the terminal back into the state it was in at startup
+var restoreTerminal = func() {}
+
+// fail reports err and exits with status 1: as a JSON object on stdout with
+// --json, as plain text on stderr otherwise
+func fail(err error) {
+	cmdpkg.FailOperation(err)
+	if jsonErrors {
+		var out struct {
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		out.Error.Type = cmdpkg.ErrorType(err)
+		out.Error.Message = err.Error()
+		data, _ := json.Marshal(out)
+		fmt.Println(string(data))
+	} else {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	exit(exitCode(1))
+}
+
+// interruptGrace is how long a command is given to stop once Ctrl+C cancelled
+// its context, before the process exits anyway, e.g. when blocked on a prompt
+const interruptGrace = 2 * time.Second
+
+// exitMu serializes exit: the signal handler and the command may both call it,
+// and the second caller blocks until the first one terminates the process
+var exitMu sync.Mutex
+
+// exit exports the remaining trace spans, restores the terminal and terminates the process
+func exit(code int) {
+	exitMu.Lock()
+	cmdpkg.Shutdown()
+	restoreTerminal()
+	os.Exit(code)
+}
+
+// exitCode returns code, or 130 (128 + SIGINT) once Ctrl+C was pressed, so that
+// a script does not take the interruption for a success or an ordinary failure
+func exitCode(code int) int {
+	if ctx := rootCmd.Context(); ctx != nil && ctx.Err() != nil {
+		return 130
+	}
+	return code
 }
 
 func main() {
 	fd := int(os.Stdin.Fd())
 
-	oldState, err := term.GetState(fd)
-	if err != nil {
-		fmt.Printf("\nError getting terminal state: %v\n", err)
-		os.Exit(1)
+	// Without a terminal, e.g. in CI with keys from --victim-key-env, there is no state to restore
+	if term.IsTerminal(fd) {
+		oldState, err := term.GetState(fd)
+		if err != nil {
+			fmt.Printf("\nError getting terminal state: %v\n", err)
+			os.Exit(1)
+		}
+		restoreTerminal = func() { term.Restore(fd, oldState) }
+	}
+
+	// Keys are kept in memory for the whole command, a crash must not write them to disk
+	if err := cmdpkg.DisableCoreDumps(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not disable core dumps, a crash could write the keys in memory to disk: %v\n", err)
 	}
-	defer term.Restore(fd, oldState)
 
+	// Ctrl+C cancels the context of the running command, which stops waiting,
+	// broadcasting and sleeping until --broadcast-at, and exits through fail. A
+	// command that does not return within interruptGrace, or a second Ctrl+C,
+	// exits right away. Either way the exit code is 130.
+	ctx, cancel := context.WithCancel(context.Background())
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
 	go func() {
 		<-c
-		term.Restore(fd, oldState)
-		fmt.Println("Ctrl+C pressed, exiting...")
-		os.Exit(0)
+		fmt.Fprintln(os.Stderr, "Ctrl+C pressed, exiting...")
+		cancel()
+		select {
+		case <-c:
+		case <-time.After(interruptGrace):
+		}
+		exit(130)
 	}()
 
-	if err := rootCmd.Execute(); err != nil {
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		if jsonErrors {
+			// Errors reaching this point come from cobra: bad flags or arguments, or the configuration
+			fail(cmdpkg.WithErrorType(cmdpkg.ErrorTypeInvalidInput, err))
+		}
 		fmt.Println(err)
-		os.Exit(1)
+		exit(exitCode(1))
 	}
+	exit(exitCode(0))
 }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	cmdpkg "github.com/ethanzhrepo/eip7702cleaner/pkg/cmd"
+)
+
+// TestReadOnlyFlags checks that the read-only modes of clear and set reach
+// the command and return from their own branch, before any key is asked for
+func TestReadOnlyFlags(t *testing.T) {
+	runClear := func(opts cmdpkg.TxOptions) error { return cmdpkg.Clear(opts) }
+	runSet := func(opts cmdpkg.TxOptions) error {
+		return cmdpkg.Set("0x000000000000000000000000000000000000dEaD", opts)
+	}
+	tests := []struct {
+		name  string
+		flag  *bool
+		field func(cmdpkg.TxOptions) bool
+		run   func(cmdpkg.TxOptions) error
+		err   string
+	}{
+		{"simulate-with-state-override", &simulate, func(o cmdpkg.TxOptions) bool { return o.SimulateOverride }, runClear, "--simulate-with-state-override requires the victim --address"},
+		{"verify-only", &verifyOnly, func(o cmdpkg.TxOptions) bool { return o.VerifyOnly }, runClear, "--verify-only requires the victim --address"},
+		{"verify-only", &verifyOnly, func(o cmdpkg.TxOptions) bool { return o.VerifyOnly }, runSet, "--verify-only requires the --address to verify"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*tt.flag = true
+			defer func() { *tt.flag = false }()
+
+			opts, err := txOptions()
+			if err != nil {
+				t.Fatalf("txOptions: %v", err)
+			}
+			if !tt.field(opts) {
+				t.Fatalf("--%s is not carried into TxOptions", tt.name)
+			}
+			// Without --address the branch fails right away; reaching the key
+			// prompt instead would fail reading the key from the test's stdin
+			err = tt.run(opts)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
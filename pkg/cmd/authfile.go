@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+// authStdin is the authorization read from stdin with --auth-file -, which can
+// only be read once
+var authStdin struct {
+	once sync.Once
+	data []byte
+	err  error
+}
+
+// authorizer is the account whose delegation changes: its key, or with
+// --auth-file only the authorization it signed beforehand
+type authorizer struct {
+	key    *ecdsa.PrivateKey   // nil when the authorization was signed beforehand
+	signed *AuthorizationTuple // nil when the authorization is signed with key
+	addr   common.Address
+}
+
+// keyAuthorizer returns the authorizer signing with key
+func keyAuthorizer(key *ecdsa.PrivateKey) authorizer {
+	return authorizer{key: key, addr: crypto.PubkeyToAddress(key.PublicKey)}
+}
+
+// address returns the authorizing account
+func (a authorizer) address() common.Address {
+	return a.addr
+}
+
+// readAuthFile reads a signed authorization in the JSON form sign-authorization
+// prints, checking it delegates to template and recovers to an account. The
+// nonce and chain are checked against the node once they are known.
+func readAuthFile(path string, template common.Address) (authorizer, error) {
+	data, err := readAuthData(path)
+	if err != nil {
+		return authorizer{}, fmt.Errorf("failed to read --auth-file: %w", err)
+	}
+	var auth AuthorizationTuple
+	if err := json.Unmarshal(data, &auth); err != nil {
+		return authorizer{}, invalidInput("invalid authorization in %s: %v", path, err)
+	}
+	if auth.Address != template {
+		if template == (common.Address{}) {
+			return authorizer{}, invalidInput("the authorization in %s delegates to %s, a clear needs one signed for the zero address", path, auth.Address.Hex())
+		}
+		return authorizer{}, invalidInput("the authorization in %s delegates to %s, not to %s", path, auth.Address.Hex(), template.Hex())
+	}
+	authority, err := auth.Authority()
+	if err != nil {
+		return authorizer{}, invalidInput("the signature of the authorization in %s is invalid: %v", path, err)
+	}
+
+	fmt.Fprintf(promptOutput, "Signed authorization of %s for nonce %d\n", authority.Hex(), auth.Nonce)
+	if auth.ChainID.Sign() == 0 {
+		notice(color.FgYellow, "Warning: the authorization is signed for chain ID 0 and is valid on every chain where the nonce matches")
+	}
+	return authorizer{signed: &auth, addr: authority}, nil
+}
+
+// readAuthData returns the contents of the authorization file, or of stdin when
+// path is "-"
+func readAuthData(path string) ([]byte, error) {
+	if path != "-" {
+		return os.ReadFile(path)
+	}
+	authStdin.once.Do(func() {
+		if stdinIsTerminal() {
+			notice(color.FgYellow, "Paste the signed authorization JSON, then press Ctrl-D on an empty line:")
+		}
+		authStdin.data, authStdin.err = io.ReadAll(io.LimitReader(os.Stdin, maxKeystoreSize))
+	})
+	return authStdin.data, authStdin.err
+}
+
+// authFileLabel names where the authorization is read from
+func authFileLabel(path string) string {
+	if path == "-" {
+		return "stdin"
+	}
+	return path
+}
+
+// stdinIsTerminal reports whether prompts can still be answered once stdin was read to its end
+func stdinIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// relayerPrompts reports whether setting up the relayer reads stdin, for a
+// hex key, a keystore passphrase or a mnemonic
+func (o TxOptions) relayerPrompts() bool {
+	return o.RelayerSignerURL == "" && o.RelayerAzureKey == "" && o.FireblocksVault == "" &&
+		!o.RelayerLedger && o.RelayerKeyEnv == "" && o.RelayerVaultPath == ""
+}
+
+// loadAuthorizer reads the signed authorization of --auth-file when given, or
+// else loads the key of the account, who naming it in errors and c and prompt
+// introducing a key prompt. The caller wipes the key with zeroKey.
+func (o TxOptions) loadAuthorizer(template common.Address, who string, c color.Attribute, prompt string) (authorizer, error) {
+	if o.AuthFile != "" {
+		return readAuthFile(o.AuthFile, template)
+	}
+	key, err := o.userKeySource().load(c, prompt)
+	if err != nil {
+		return authorizer{}, fmt.Errorf("error reading %s private key: %w", who, err)
+	}
+	return keyAuthorizer(key), nil
+}
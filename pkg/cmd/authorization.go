@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	gmath "github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/fatih/color"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// qrImageSize is the width and height in pixels of the QR codes written as PNG
+const qrImageSize = 512
+
+// SignAuthOptions are the offline mode and extra outputs of SignAuthorization
+type SignAuthOptions struct {
+	Offline bool   // Never contact a node: the chain ID and nonce must be given
+	OutFile string // Also write the authorization JSON to this file, for --auth-file on another machine
+	QR      bool   // Also show the authorization JSON as a QR code on the terminal
+	QRFile  string // Also write that QR code as a PNG image to this file
+}
+
+// SignAuthorization signs an EIP-7702 authorization delegating the account of
+// the key read from the user to contractAddress, the zero address clearing the
+// delegation, and prints it on stdout in the SetCodeAuthorization JSON form
+// wallets and libraries such as viem and ethers accept. No transaction is
+// built: the authorization is meant to be included by another tool or a
+// relayer service. chainID and nonce may be decimal or 0x hex; when empty they
+// are read from the node, chain ID 0 making the authorization valid on every chain.
+// With out.Offline they are required instead, so the signing machine can stay
+// air-gapped and hand the authorization over as a file or QR code.
+func SignAuthorization(contractAddress, chainID, nonce string, out SignAuthOptions, opts CheckOptions) error {
+	if !common.IsHexAddress(contractAddress) {
+		return invalidInput("invalid contract address format: %s", contractAddress)
+	}
+	if out.Offline && (chainID == "" || nonce == "") {
+		return invalidInput("--offline requires --chain-id and --nonce, as no node is contacted")
+	}
+	auth := AuthorizationTuple{Address: common.HexToAddress(contractAddress)}
+
+	rpcURL := opts.rpcURLOrDefault()
+	var ok bool
+	if chainID != "" {
+		if auth.ChainID, ok = gmath.ParseBig256(chainID); !ok {
+			return invalidInput("invalid chain ID %q", chainID)
+		}
+	} else {
+		id, err := getChainID(rpcURL)
+		if err != nil {
+			return fmt.Errorf("failed to get chain ID: %w", err)
+		}
+		auth.ChainID = id
+	}
+	if err := checkDemoChain(auth.ChainID); err != nil {
+		return err
+	}
+	if auth.ChainID.Sign() == 0 {
+		notice(color.FgYellow, "Warning: chain ID 0 makes the authorization valid on every chain where the nonce matches")
+	} else {
+		notice(color.FgCyan, "Chain: %s", chainLabel(auth.ChainID))
+	}
+	if nonce != "" {
+		if auth.Nonce, ok = gmath.ParseUint64(nonce); !ok {
+			return invalidInput("invalid nonce %q", nonce)
+		}
+	}
+
+	notice(color.FgYellow, "Please enter the private key of the address to be authorized:")
+	privateKey, err := readPrivateKey()
+	if err != nil {
+		return fmt.Errorf("error reading private key: %w", err)
+	}
+	defer zeroKey(privateKey)
+	authority := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	if nonce == "" {
+		current, err := getNonce(rpcURL, authority.Hex())
+		if err != nil {
+			return fmt.Errorf("failed to get nonce: %w", err)
+		}
+		auth.Nonce = uint64(current)
+	}
+	fmt.Fprintf(promptOutput, "Authority: %s\n", authority.Hex())
+	fmt.Fprintf(promptOutput, "Nonce: %d (must still be the account's nonce when the authorization is applied)\n", auth.Nonce)
+	if auth.Address == (common.Address{}) {
+		fmt.Fprintf(promptOutput, "Delegation: cleared\n")
+	} else {
+		fmt.Fprintf(promptOutput, "Delegation: %s\n", auth.Address.Hex())
+	}
+
+	if auth, err = signAuthTuple(auth.ChainID, auth.Address, auth.Nonce, privateKey); err != nil {
+		return fmt.Errorf("failed to sign the authorization: %w", err)
+	}
+
+	// Check the signature the way a node will before handing it out
+	if recovered, err := auth.Authority(); err != nil || recovered != authority {
+		return verificationFailure("signed authorization does not recover to %s, aborting", authority.Hex())
+	}
+
+	authJSON, err := json.MarshalIndent(auth, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode authorization as JSON: %w", err)
+	}
+	fmt.Fprintln(resultOutput, string(authJSON))
+	return writeAuthorization(authJSON, out)
+}
+
+// writeAuthorization writes the signed authorization to the extra outputs of out
+func writeAuthorization(authJSON []byte, out SignAuthOptions) error {
+	if out.OutFile != "" {
+		if err := os.WriteFile(out.OutFile, append(authJSON, '\n'), 0600); err != nil {
+			return fmt.Errorf("failed to write the authorization: %w", err)
+		}
+		fmt.Fprintf(promptOutput, "Authorization written to %s\n", out.OutFile)
+	}
+	if !out.QR && out.QRFile == "" {
+		return nil
+	}
+
+	// The compact form keeps the code small enough to scan off a terminal
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, authJSON); err != nil {
+		return err
+	}
+	code, err := qrcode.New(compact.String(), qrcode.Medium)
+	if err != nil {
+		return fmt.Errorf("failed to encode the authorization as a QR code: %w", err)
+	}
+	if out.QR {
+		fmt.Fprint(promptOutput, code.ToSmallString(false))
+	}
+	if out.QRFile != "" {
+		png, err := code.PNG(qrImageSize)
+		if err != nil {
+			return fmt.Errorf("failed to render the QR code: %w", err)
+		}
+		if err := os.WriteFile(out.QRFile, png, 0600); err != nil {
+			return fmt.Errorf("failed to write the QR code: %w", err)
+		}
+		fmt.Fprintf(promptOutput, "QR code written to %s\n", out.QRFile)
+	}
+	return nil
+}
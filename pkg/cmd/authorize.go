@@ -0,0 +1,753 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/fatih/color"
+)
+
+// authAction describes the parts of the set/clear flow that differ between the two commands
+type authAction struct {
+	Template      common.Address // Contract to delegate to, the zero address clears the delegation
+	UserLabel     string         // How the authorizing address is referred to, e.g. "Victim"
+	Confirm       string         // Question asked before the transaction is broadcast
+	WarnOnConfirm bool           // Print the confirmation question in yellow
+	Generating    string         // Progress message shown while building the transaction
+	Done          string         // Past tense used in the verification hint, e.g. "cleared"
+}
+
+// authResult carries what follow-up steps, such as the fund sweep, need from a sent authorization
+type authResult struct {
+	User      common.Address // The authorizing address
+	ChainID   *big.Int
+	TxHash    string
+	Receipt   *TransactionReceipt // nil if the transaction was not mined before the wait ended
+	GasTip    *big.Int
+	GasFeeCap *big.Int
+	Deadline  time.Time       // End of the --max-wait budget shared by the follow-up steps, zero if unbounded
+	Parent    context.Context // Cancelled when the operation is interrupted, nil for none
+	Proposed  bool            // Written to a bundle or exported for review instead of being broadcast
+
+	Verification string        // Outcome of the on-chain check of the delegation, empty if it did not run
+	Sweeps       []sweepRecord // Transfers made by the fund sweep that followed
+
+	// resign rebuilds the transaction with other fees and the same nonces, for
+	// resubmission. nil when the transaction cannot be signed again.
+	resign func(gasTip, gasFeeCap *big.Int) (string, error)
+}
+
+// context returns a context that ends at the operation's --max-wait deadline,
+// or when the operation is interrupted
+func (r *authResult) context() (context.Context, context.CancelFunc) {
+	parent := r.Parent
+	if parent == nil {
+		parent = context.Background()
+	}
+	if r.Deadline.IsZero() {
+		return context.WithCancel(parent)
+	}
+	return context.WithDeadline(parent, r.Deadline)
+}
+
+// previewAuthorization shows what can be known before any key is entered: the
+// chain, the current fees and the resulting maximum cost, and when --address is
+// given, that account's nonce and delegation. Failures are only reported, key
+// entry goes ahead either way.
+func previewAuthorization(action authAction, opts TxOptions) {
+	rpcURL := opts.rpcURLOrDefault()
+	out := opts.console()
+
+	chainID, err := getChainID(rpcURL)
+	if err != nil {
+		notice(color.FgYellow, "Could not preview the network parameters (%v), continuing to key entry.", err)
+		return
+	}
+	notice(color.FgCyan, "Chain: %s", chainLabel(chainID))
+	if err := checkEIP7702Support(chainID); err != nil {
+		notice(color.FgYellow, "Warning: %v", err)
+	}
+	if !opts.SkipNodeCheck {
+		for _, advisory := range nodeSupportAdvisories(rpcURL, chainID) {
+			notice(color.FgYellow, "Warning: %s; your node or chain may not support EIP-7702, and the node would reject the transaction.", advisory)
+		}
+	}
+
+	if gasTip, gasFeeCap, err := opts.gasFees(chainID); err != nil {
+		notice(color.FgYellow, "Could not fetch the current gas fees: %v", err)
+	} else {
+		printGasInfo(gasTip, gasFeeCap, opts.gasLimitFor(1))
+	}
+	if opts.FeeAdvisory {
+		printFeeAdvisory(rpcURL)
+	}
+
+	if opts.Address == "" || opts.Batch {
+		out.infof("\nThe %s nonce is read once the keys are entered (pass --address to preview it).\n", strings.ToLower(action.UserLabel))
+		return
+	}
+	if !common.IsHexAddress(opts.Address) {
+		notice(color.FgYellow, "Invalid --address %s, skipping its preview.", opts.Address)
+		return
+	}
+	address := common.HexToAddress(opts.Address)
+	fmt.Fprintf(promptOutput, "\n%s address: %s\n", action.UserLabel, address.Hex())
+	if nonce, err := getNonce(rpcURL, address.Hex()); err == nil {
+		fmt.Fprintf(promptOutput, "%s nonce: %d\n", action.UserLabel, nonce)
+	}
+	if result, err := CheckAddress(address.Hex(), CheckOptions{RPCURL: rpcURL}); err == nil {
+		fmt.Fprintf(promptOutput, "Current delegation: %s\n", delegationLabel(result, nil))
+	}
+	out.info("")
+}
+
+// checkExpectedAccount stops right after key entry, before the relayer key is
+// asked for or anything is signed, when the key is not for the intended account:
+// the one of --expect-address or --address when given, or else the one the user
+// confirms once shown the address of the key. A signed authorization read with
+// --auth-file is checked the same way.
+func checkExpectedAccount(action authAction, user authorizer, opts TxOptions) error {
+	userAddress := user.address()
+	label := strings.ToLower(action.UserLabel)
+	what := "key"
+	if user.signed != nil {
+		what = "authorization"
+	}
+	for _, expected := range []struct{ flag, value string }{
+		{"--expect-address", opts.ExpectAddress},
+		{"--address", opts.Address},
+	} {
+		if expected.value != "" && common.HexToAddress(expected.value) != userAddress {
+			return invalidInput("the %s %s is for %s, not for %s %s; nothing was signed", label, what, userAddress.Hex(), expected.flag, expected.value)
+		}
+	}
+	if opts.ExpectAddress != "" || opts.Address != "" {
+		fmt.Fprintf(promptOutput, "The %s %s matches the expected address %s\n", label, what, userAddress.Hex())
+		return nil
+	}
+	if opts.Yes {
+		return nil
+	}
+	notice(color.FgYellow, "\nThe %s entered is for %s. Is this the %s account you intend? (y/n)", what, userAddress.Hex(), label)
+	return confirmOrCancel()
+}
+
+// overrideAuthNonce returns the authorization nonce to sign: AuthNonce when
+// given, with a warning when it is not the derived one, or else derived
+func overrideAuthNonce(derived uint64, opts TxOptions) uint64 {
+	if opts.AuthNonce == nil || *opts.AuthNonce == derived {
+		return derived
+	}
+	notice(color.FgYellow, "Warning: the authorization is signed for nonce %d (--auth-nonce), the account's is %d.", *opts.AuthNonce, derived)
+	notice(color.FgYellow, "It only takes effect if the account's nonce is %d when the transaction is included, otherwise it is skipped and the relayer still pays.", *opts.AuthNonce)
+	return *opts.AuthNonce
+}
+
+// authChainID returns the chain ID to sign the authorization for: 0 with
+// AllChainsAuth, after a warning about its replay on other chains, or else nil
+// for the transaction's own. A pre-signed authorization already has its chain
+// ID, so it cannot be combined with AllChainsAuth.
+func authChainID(action authAction, user authorizer, opts TxOptions) (*big.Int, error) {
+	if !opts.AllChainsAuth {
+		return nil, nil
+	}
+	if user.signed != nil {
+		return nil, invalidInput("--all-chains-auth cannot be combined with --auth-file, the chain ID of a signed authorization is part of its signature")
+	}
+	notice(color.FgRed, "\n⚠ --all-chains-auth: the authorization is signed for chain ID 0 and is valid on EVERY chain.")
+	notice(color.FgRed, "Anyone who sees it, once it is broadcast, can submit it on any chain where the account has the same nonce.")
+	if action.Template == (common.Address{}) {
+		notice(color.FgRed, "There it clears the delegation too, including one you meant to keep.")
+	} else {
+		notice(color.FgRed, "There it delegates the account to %s, whatever code that address holds on that chain, or will hold later.", action.Template.Hex())
+	}
+	return new(big.Int), nil
+}
+
+// warnNonceOverrides reminds that the nonces given on the command line were
+// not checked against the node
+func warnNonceOverrides(opts TxOptions) {
+	var flags []string
+	for _, override := range []struct {
+		flag string
+		set  bool
+	}{
+		{"--victim-nonce", opts.VictimNonce != nil},
+		{"--relayer-nonce", opts.RelayerNonce != nil},
+	} {
+		if override.set {
+			flags = append(flags, override.flag)
+		}
+	}
+	if len(flags) > 0 {
+		notice(color.FgYellow, "Using the nonce of %s instead of the node's; a transaction with a used nonce is rejected or replaces the pending one.", strings.Join(flags, " and "))
+	}
+}
+
+// broadcastAuthorization fetches the network parameters, builds the EIP-7702
+// authorization transaction, asks the user for confirmation and broadcasts it,
+// leaving the wait to awaitAuthorization
+func broadcastAuthorization(action authAction, user authorizer, relayer *relayerSession, opts TxOptions) (*authResult, error) {
+	rpcURL := opts.rpcURLOrDefault()
+	out := opts.console()
+	userAddress := user.address()
+
+	// Get chain ID
+	chainID, err := getChainID(rpcURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chain ID: %w", err)
+	}
+	notice(color.FgCyan, "\nChain: %s", chainLabel(chainID))
+	if err := checkEIP7702Support(chainID); err != nil {
+		return nil, err
+	}
+
+	// Get nonces
+	relayerNonce, err := relayer.nonces.Next()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get relayer nonce: %w", err)
+	}
+
+	var authNonce uint64
+	if relayer.Address() == userAddress {
+		// Self-sponsored: the sender's nonce is incremented before the
+		// authorization list is processed, so the authorization must sign the next one
+		authNonce = relayerNonce + 1
+		out.infof("Self-sponsored: transaction nonce %d, authorization nonce %d\n", relayerNonce, authNonce)
+	} else {
+		if opts.VictimNonce != nil {
+			authNonce = *opts.VictimNonce
+		} else {
+			userNonce, err := getNonce(rpcURL, userAddress.Hex())
+			if err != nil {
+				return nil, fmt.Errorf("failed to get %s nonce: %w", strings.ToLower(action.UserLabel), err)
+			}
+			authNonce = uint64(userNonce)
+		}
+		out.infof("%s nonce: %d\n", action.UserLabel, authNonce)
+		out.infof("Relayer nonce: %d\n", relayerNonce)
+	}
+	authNonce = overrideAuthNonce(authNonce, opts)
+	warnNonceOverrides(opts)
+	if user.signed != nil {
+		// A node skips an authorization whose nonce is not the account's, while
+		// the relayer still pays for the transaction
+		if user.signed.Nonce != authNonce {
+			return nil, invalidInput("the signed authorization is for nonce %d but the %s nonce is %d; sign a new one with sign-authorization", user.signed.Nonce, strings.ToLower(action.UserLabel), authNonce)
+		}
+		if user.signed.ChainID.Sign() != 0 && user.signed.ChainID.Cmp(chainID) != 0 {
+			return nil, invalidInput("the signed authorization is for chain %s, the node is on chain %s", user.signed.ChainID, chainID)
+		}
+	}
+
+	authChain, err := authChainID(action, user, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get gas parameters using EIP-1559 compatible method
+	out.info("\nFetching gas parameters from the network...")
+	gasTip, gasFeeCap, err := opts.gasFees(chainID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get suggested gas fees: %w", err)
+	}
+	if opts.feesPinned() {
+		notice(color.FgYellow, "Using the fees given with --max-fee/--priority-fee instead of the network's suggestion")
+		warnIfBelowBaseFee(rpcURL, gasFeeCap)
+	}
+
+	// Create EIP-7702 authorization request
+	req := SetAuthorizationRequest{
+		UserEOAPrivateKey: user.key,
+		Authorization:     user.signed,
+		UserEOANonce:      authNonce,
+		RelayerSigner:     relayer.signer,
+		RelayerNonce:      relayerNonce,
+		TemplateAddress:   action.Template,
+		ChainId:           chainID,
+		AuthChainId:       authChain,
+		Data:              opts.CallData,
+		Value:             opts.CallValue,
+	}
+
+	// Use the provided gas limit, the estimate of a call to the authorized
+	// account, or else the default for a single authorization. The estimate
+	// hands the signed authorization to the node, which could broadcast it, so
+	// the user confirms before it rather than after: once confirmed, the
+	// transaction is signed and broadcast without a second prompt.
+	gasLimit := opts.gasLimitFor(1)
+	confirmed := false
+	if opts.GasLimit == 0 && opts.hasCall() {
+		notice(color.FgYellow, "\nThe gas of the call is estimated by the node with the signed authorization applied, which hands the authorization to it.")
+		notice(color.FgYellow, "Confirm now: the transaction is broadcast once estimated and signed, and answering no leaves nothing signed with the node.")
+		if err := confirmAuthorization(action, opts); err != nil {
+			return nil, err
+		}
+		confirmed = true
+		out.info("Estimating the gas of the call with the authorization applied...")
+		if gasLimit, err = estimateCallGas(rpcURL, relayer.Address(), req); err != nil {
+			return nil, fmt.Errorf("failed to estimate the gas of the call, pass --gas-limit covering the authorization and the call: %w", err)
+		}
+	}
+	out.infof("Using gas limit: %d\n", gasLimit)
+	printGasInfo(gasTip, gasFeeCap, gasLimit)
+
+	if opts.InteractiveGas {
+		if gasTip, gasFeeCap, err = tuneGasInteractively(gasTip, gasFeeCap, gasLimit); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkCostCeiling(chainID, maxGasCost(gasFeeCap, gasLimit), opts); err != nil {
+		return nil, err
+	}
+	req.GasTip, req.GasFeeCap, req.GasLimit = gasTip, gasFeeCap, gasLimit
+
+	out.infof("\n%s\n", action.Generating)
+	signedTx, err := GenerateSet7702AuthTx(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate transaction: %w", err)
+	}
+
+	// Show what the signed bytes actually do, with the authority and sender their
+	// signatures recover to, so what is confirmed is exactly what gets sent and a
+	// signing bug is caught before anything is confirmed
+	summary, err := summarizeSignedTx(signedTx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the signed transaction: %w", err)
+	}
+	summary.print()
+	if authority := summary.Authorizations[0].Authority; authority != userAddress {
+		notice(color.FgRed, "✗ The signature recovers to %s, not to the %s address %s", authority.Hex(), strings.ToLower(action.UserLabel), userAddress.Hex())
+		return nil, fmt.Errorf("signed authorization does not match the %s key, aborting", strings.ToLower(action.UserLabel))
+	}
+	if opts.DryRun {
+		// Nothing is confirmed, as nothing is sent: check the bytes against the request right away
+		if err := verifySignedTx(signedTx, req); err != nil {
+			return nil, err
+		}
+		if err := printDryRun(signedTx); err != nil {
+			return nil, err
+		}
+		return &authResult{User: userAddress, ChainID: chainID, GasTip: gasTip, GasFeeCap: gasFeeCap, Proposed: true}, nil
+	}
+
+	// Confirm with user, unless that was done before the gas estimate
+	if !confirmed {
+		if err := confirmAuthorization(action, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.JSONTx {
+		if err := printTxJSON(signedTx); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Paranoid {
+		out.info("Re-verifying the signed transaction...")
+		if err := verifySignedTx(signedTx, req); err != nil {
+			return nil, err
+		}
+		notice(color.FgGreen, "Paranoid check passed: the signed transaction matches what was confirmed")
+	}
+
+	result := &authResult{
+		User:      userAddress,
+		ChainID:   chainID,
+		GasTip:    gasTip,
+		GasFeeCap: gasFeeCap,
+		Parent:    opts.Context,
+	}
+	if opts.BundleOut != "" {
+		if err := writeProposal(opts.BundleOut, action, signedTx, req); err != nil {
+			return nil, err
+		}
+		result.Proposed = true
+		return result, nil
+	}
+	if opts.TxOut != "" || opts.NoBroadcast {
+		if err := writeSignedTx(signedTx, opts); err != nil {
+			return nil, err
+		}
+		notice(color.FgCyan, "Not broadcast. Submit it through the channel of your choice, e.g. `cast publish` or a private relay such as Flashbots Protect,")
+		notice(color.FgCyan, "before the %s or the relayer sends another transaction, which would invalidate its nonces.", strings.ToLower(action.UserLabel))
+		result.Proposed = true
+		return result, nil
+	}
+	if !opts.BroadcastAt.IsZero() {
+		if err := awaitBroadcastTime(opts.baseContext(), opts.BroadcastAt, signedTx); err != nil {
+			return nil, err
+		}
+		// The accounts may have moved while waiting, and a stale nonce would make the
+		// node reject the transaction or silently skip the authorization. The nonces
+		// checked are the ones in the signed bytes, not the ones derived before signing.
+		signedAuthNonce, signedRelayerNonce := summary.Authorizations[0].Nonce, summary.Nonce
+		currentAuthNonce, currentRelayerNonce, err := currentNonces(rpcURL, userAddress, relayer.Address())
+		if err != nil {
+			return nil, err
+		}
+		if currentAuthNonce != signedAuthNonce || currentRelayerNonce != signedRelayerNonce {
+			relayer.nonces.Reset()
+			return nil, fmt.Errorf("nonces changed since signing: signed for authority nonce %d and relayer nonce %d, current nonces call for %d and %d; nothing was broadcast",
+				signedAuthNonce, signedRelayerNonce, currentAuthNonce, currentRelayerNonce)
+		}
+		notice(color.FgGreen, "✓ Nonces unchanged since signing")
+		warnIfBelowBaseFee(rpcURL, gasFeeCap)
+	}
+	if opts.MaxWait > 0 {
+		result.Deadline = time.Now().Add(opts.MaxWait)
+	}
+	ctx, cancel := result.context()
+	defer cancel()
+
+	out.info("Broadcasting transaction...")
+	txHash, err := broadcastRawTx(ctx, signedTx, rpcURL)
+	if err != nil {
+		// The local counter still holds the failed nonce; unless it is to be
+		// reused as is, it is re-read from the node for the next transaction
+		if opts.OnError != OnErrorReuseNonce {
+			relayer.nonces.Reset()
+		}
+		if ctx.Err() != nil {
+			notice(color.FgYellow, "%s before the node acknowledged the broadcast; the transaction may still have been received.", waitEnded(ctx))
+			notice(color.FgYellow, "Transaction hash to look up: %s", signedTxHash(signedTx))
+		}
+		return nil, &broadcastFailure{nonce: relayerNonce, err: err}
+	}
+	relayer.nonces.Commit()
+	result.TxHash = txHash
+	result.resign = func(gasTip, gasFeeCap *big.Int) (string, error) {
+		req.GasTip, req.GasFeeCap = gasTip, gasFeeCap
+		signedTx, err := GenerateSet7702AuthTx(req)
+		if err != nil {
+			return "", err
+		}
+		if opts.Paranoid {
+			if err := verifySignedTx(signedTx, req); err != nil {
+				return "", err
+			}
+		}
+		return signedTx, nil
+	}
+
+	printTxHash("\nTransaction successfully sent! Transaction hash:", txHash)
+	return result, nil
+}
+
+// confirmAuthorization asks the user to confirm the action, unless --yes was given
+func confirmAuthorization(action authAction, opts TxOptions) error {
+	if opts.Yes {
+		opts.console().info("\nConfirmation skipped (--yes)")
+		return nil
+	}
+	if action.WarnOnConfirm {
+		notice(color.FgYellow, "\n%s (y/n)", action.Confirm)
+	} else {
+		fmt.Fprintf(promptOutput, "\n%s (y/n)\n", action.Confirm)
+	}
+	return confirmOrCancel()
+}
+
+// currentNonces returns the authorization and relayer nonces a transaction
+// signed now would use, the relayer's counting pending transactions
+func currentNonces(rpcURL string, authority, relayer common.Address) (uint64, uint64, error) {
+	relayerNonce, err := getNonceAt(rpcURL, relayer.Hex(), "pending")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get relayer nonce: %w", err)
+	}
+	if authority == relayer {
+		// Self-sponsored, the authorization signs the nonce after the transaction's
+		return uint64(relayerNonce) + 1, uint64(relayerNonce), nil
+	}
+	authorityNonce, err := getNonce(rpcURL, authority.Hex())
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get authority nonce: %w", err)
+	}
+	return uint64(authorityNonce), uint64(relayerNonce), nil
+}
+
+// estimateCallGas asks the node for the gas of the request's call to the
+// authorized account with its authorization list applied, so the call runs the
+// delegated code, and adds 20% headroom. The authorizations are signed for
+// this, and so reach the node before the transaction does: callers confirm
+// with the user first.
+func estimateCallGas(rpcURL string, from common.Address, req SetAuthorizationRequest) (uint64, error) {
+	auths, err := req.authorizations()
+	if err != nil {
+		return 0, err
+	}
+	to, value, data, err := req.call()
+	if err != nil {
+		return 0, err
+	}
+	body := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_estimateGas",
+		"params": []interface{}{
+			map[string]interface{}{
+				"from":              from.Hex(),
+				"to":                to.Hex(),
+				"value":             (*hexutil.Big)(value),
+				"data":              hexutil.Bytes(data),
+				"authorizationList": auths,
+			},
+		},
+	}
+
+	responseBody, err := makeRPCCall(rpcURL, body)
+	if err != nil {
+		return 0, err
+	}
+
+	var result struct {
+		Result hexutil.Uint64 `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		return 0, err
+	}
+	if result.Error != nil {
+		return 0, fmt.Errorf("eth_estimateGas failed: %s", result.Error.Message)
+	}
+	gas := uint64(result.Result) * 12 / 10
+	return max(gas, IntrinsicGas(len(auths), data, nil)), nil
+}
+
+// awaitBroadcastTime holds a signed transaction until the scheduled broadcast
+// time, or until ctx ends, e.g. on Ctrl+C
+func awaitBroadcastTime(ctx context.Context, at time.Time, signedTx string) error {
+	wait := time.Until(at)
+	if wait <= 0 {
+		return nil
+	}
+	notice(color.FgCyan, "\nTransaction signed, broadcast scheduled at %s (in %s)", at.Format(time.RFC3339), wait.Round(time.Second))
+	fmt.Fprintf(promptOutput, "Transaction hash once broadcast: %s\n", signedTxHash(signedTx))
+	fmt.Fprintln(promptOutput, "Keep this process running; interrupting it discards the signed transaction.")
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("interrupted before the scheduled broadcast, nothing was broadcast: %w", ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
+
+// warnIfBelowBaseFee warns when the base fee of the next block exceeds the max
+// fee per gas, in which case the transaction waits until the base fee drops
+func warnIfBelowBaseFee(rpcURL string, gasFeeCap *big.Int) {
+	history, err := getFeeHistory(rpcURL, 1)
+	if err != nil {
+		return
+	}
+	baseFee := history.BaseFees[len(history.BaseFees)-1]
+	if baseFee.Cmp(gasFeeCap) > 0 {
+		notice(color.FgYellow, "Warning: the base fee is now %.6f Gwei, above the signed max fee of %.6f Gwei; the transaction stays pending until it drops",
+			weiToGwei(baseFee), weiToGwei(gasFeeCap))
+	}
+}
+
+// awaitAuthorization waits for a broadcast authorization to be mined and
+// verifies on chain that the delegation changed as intended
+func awaitAuthorization(action authAction, result *authResult, opts TxOptions) error {
+	if result.Proposed {
+		return nil
+	}
+	rpcURL := opts.rpcURLOrDefault()
+	out := opts.console()
+	if opts.NoWait {
+		notice(color.FgCyan, "\nNot waiting for the transaction to be mined (--no-wait).")
+		if url := explorerTxURL(result.ChainID, result.TxHash); url != "" {
+			fmt.Fprintf(promptOutput, "Track it at: %s\n", url)
+		}
+		fmt.Fprintf(promptOutput, "To verify the EIP-7702 authorization has been %s once mined, run:\n", action.Done)
+		fmt.Fprintf(promptOutput, "eip7702cleaner verify %s --rpc-url %s\n", result.User.Hex(), rpcURL)
+		return nil
+	}
+	ctx, cancel := result.context()
+	defer cancel()
+
+	var err error
+	result.Receipt, err = waitWithResubmission(ctx, rpcURL, result, opts)
+	if err != nil {
+		if errors.Is(err, errReverted) && action.Template != (common.Address{}) && opts.hasCall() {
+			warnUninitialized(result.User, action.Template)
+		}
+		return err
+	}
+
+	if result.Receipt == nil {
+		out.infof("\nTo verify the EIP-7702 authorization has been %s once mined, run:\n", action.Done)
+		out.infof("eip7702cleaner verify %s --rpc-url %s\n", result.User.Hex(), rpcURL)
+		return nil
+	}
+
+	// Close the loop: read the code back and confirm the delegation changed as intended
+	if err := verifyDelegation(result.User, action.Template, CheckOptions{RPCURL: rpcURL}); err != nil {
+		result.Verification = "failed: " + err.Error()
+		notice(color.FgRed, "\n✗ The transaction was mined but the authorization was not %s: %v", action.Done, err)
+		return WithErrorType(ErrorTypeVerification, fmt.Errorf("post-transaction verification failed: %w", err))
+	}
+	result.Verification = "passed"
+	notice(color.FgGreen, "✓ Verified on chain: the EIP-7702 authorization has been %s", action.Done)
+	return nil
+}
+
+// warnUninitialized warns that a set whose call reverted still delegated the
+// account: authorizations are applied before the call and are not undone by its
+// revert, leaving the account running code that was never initialized
+func warnUninitialized(user, template common.Address) {
+	notice(color.FgRed, "\n⚠ THE DELEGATION IS LIVE: the call reverted, but the authorization still applied.")
+	notice(color.FgRed, "%s now runs the code of %s WITHOUT having been initialized.", user.Hex(), template.Hex())
+	notice(color.FgRed, "Anyone can call its initializer first, e.g. initialize(owner) with their own owner, and take over the account.")
+	notice(color.FgRed, "Run the initializer again IMMEDIATELY: send the same --data to %s, with a higher --gas-limit if it ran out of gas.", user.Hex())
+}
+
+// hasNoDelegation reports whether a pre-flight eth_getCode shows the address
+// has no code at all. Lookup failures count as "maybe delegated".
+func hasNoDelegation(rpcURL string, address common.Address) bool {
+	result, err := CheckAddress(address.Hex(), CheckOptions{RPCURL: rpcURL})
+	return err == nil && result.Status == StatusClean
+}
+
+// txSummary is what a signed authorization transaction does, read back from its bytes
+type txSummary struct {
+	ChainID        *big.Int
+	Sender         common.Address // Recovered from the transaction signature
+	Nonce          uint64
+	Authorizations []authSummary // In the order of the authorization list, at least one
+	To             common.Address
+	Data           []byte
+	Value          *big.Int
+	GasTipCap      *big.Int
+	GasFeeCap      *big.Int
+	Gas            uint64
+	Hash           common.Hash
+}
+
+// authSummary is one authorization of a txSummary
+type authSummary struct {
+	Authority common.Address // Recovered from the authorization signature
+	Nonce     uint64
+	Chain     *big.Int       // Chain ID of the authorization, 0 for every chain
+	Target    common.Address // Zero for a clear
+}
+
+// summarizeSignedTx decodes a signed transaction and recovers its signers, the
+// sender and the authority of each authorization
+func summarizeSignedTx(signedTx string) (*txSummary, error) {
+	tx, err := DecodeSetCodeTx(signedTx)
+	if err != nil {
+		return nil, err
+	}
+	if len(tx.AuthList) == 0 {
+		return nil, errors.New("the transaction carries no authorization")
+	}
+
+	summary := &txSummary{
+		ChainID:   tx.ChainID,
+		Nonce:     tx.Nonce,
+		To:        tx.To,
+		Data:      tx.Data,
+		Value:     tx.Value,
+		GasTipCap: tx.GasTipCap,
+		GasFeeCap: tx.GasFeeCap,
+		Gas:       tx.Gas,
+	}
+	if summary.Sender, err = tx.Sender(); err != nil {
+		return nil, fmt.Errorf("failed to recover the sender: %w", err)
+	}
+	for i, auth := range tx.AuthList {
+		authority, err := auth.Authority()
+		if err != nil {
+			return nil, fmt.Errorf("failed to recover the authority of authorization %d: %w", i+1, err)
+		}
+		summary.Authorizations = append(summary.Authorizations, authSummary{
+			Authority: authority,
+			Nonce:     auth.Nonce,
+			Chain:     auth.ChainID,
+			Target:    auth.Address,
+		})
+	}
+	if summary.Hash, err = tx.Hash(); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// print shows the summary ahead of the confirmation prompt
+func (s *txSummary) print() {
+	symbol := nativeSymbol(s.ChainID)
+
+	fmt.Fprintln(promptOutput, "\nTransaction to be broadcast, decoded from the signed bytes:")
+	fmt.Fprintf(promptOutput, "  Chain:            %s\n", chainLabel(s.ChainID))
+	fmt.Fprintf(promptOutput, "  Type:             0x%02x (EIP-7702 set code)\n", SET_CODE_TX_TYPE)
+	fmt.Fprintf(promptOutput, "  Sender:           %s (nonce %d)\n", s.Sender.Hex(), s.Nonce)
+	for i, auth := range s.Authorizations {
+		label := "Authority:"
+		if len(s.Authorizations) > 1 {
+			label = fmt.Sprintf("Authority #%d:", i+1)
+		}
+		if auth.Chain != nil && auth.Chain.Sign() == 0 {
+			fmt.Fprintf(promptOutput, "  %-18s%s (nonce %d, chain ID 0: valid on every chain)\n", label, auth.Authority.Hex(), auth.Nonce)
+		} else {
+			fmt.Fprintf(promptOutput, "  %-18s%s (nonce %d)\n", label, auth.Authority.Hex(), auth.Nonce)
+		}
+		action := fmt.Sprintf("set, delegate to %s", auth.Target.Hex())
+		if auth.Target == (common.Address{}) {
+			action = "clear, remove the delegation"
+		}
+		fmt.Fprintf(promptOutput, "  Action:           %s\n", action)
+	}
+	if len(s.Data) > 0 || s.To == s.Authorizations[0].Authority {
+		fmt.Fprintf(promptOutput, "  Call:             %s with %d bytes of calldata, %s\n", s.To.Hex(), len(s.Data), hexutil.Encode(s.Data))
+	}
+	fmt.Fprintf(promptOutput, "  Value:            %.9f %s\n", weiToEth(s.Value), symbol)
+	fmt.Fprintf(promptOutput, "  Max fee per gas:  %.6f Gwei\n", weiToGwei(s.GasFeeCap))
+	fmt.Fprintf(promptOutput, "  Priority fee:     %.6f Gwei\n", weiToGwei(s.GasTipCap))
+	fmt.Fprintf(promptOutput, "  Gas limit:        %d\n", s.Gas)
+	fmt.Fprintf(promptOutput, "  Max cost:         %.9f %s\n", weiToEth(maxGasCost(s.GasFeeCap, s.Gas)), symbol)
+	fmt.Fprintf(promptOutput, "  Hash:             %s\n", s.Hash.Hex())
+}
+
+// writeSignedTx exports the raw signed transaction as 0x hex: to the TxOut
+// file when set, and otherwise on stdout
+func writeSignedTx(signedTx string, opts TxOptions) error {
+	raw := "0x" + signedTx
+	if opts.TxOut == "" {
+		fmt.Fprintln(resultOutput, raw)
+	} else {
+		if err := os.WriteFile(opts.TxOut, []byte(raw+"\n"), 0600); err != nil {
+			return fmt.Errorf("failed to write the signed transaction: %w", err)
+		}
+		fmt.Fprintf(promptOutput, "\nSigned transaction written to %s\n", opts.TxOut)
+	}
+	fmt.Fprintf(promptOutput, "Transaction hash once broadcast: %s\n", signedTxHash(signedTx))
+	return nil
+}
+
+// printTxJSON prints a signed transaction in EIP-2718 typed transaction JSON form
+func printTxJSON(signedTx string) error {
+	tx, err := DecodeSetCodeTx(signedTx)
+	if err != nil {
+		return fmt.Errorf("failed to decode signed transaction: %w", err)
+	}
+	txJSON, err := json.MarshalIndent(tx, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode transaction as JSON: %w", err)
+	}
+	fmt.Fprintln(resultOutput, string(txJSON))
+	return nil
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSummarizeSignedTx(t *testing.T) {
+	key1 := common.HexToAddress("0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf")
+	key2 := common.HexToAddress("0x2B5AD5c4795c026514f8317c7a215E218DcCD6cF")
+	key3 := common.HexToAddress("0x6813Eb9362372EEF6200f3b1dbC3f819671cBA69")
+
+	tests := []struct {
+		name  string
+		more  []AccountAuthorization
+		data  []byte
+		auths []authSummary
+	}{
+		{
+			name:  "one authorization",
+			auths: []authSummary{{Authority: key1, Nonce: 7}},
+		},
+		{
+			name: "two authorizations and a call",
+			more: []AccountAuthorization{{PrivateKey: testKey(t, 3).key, Nonce: 0}},
+			data: []byte{0x81, 0x29, 0xfc, 0x1c},
+			auths: []authSummary{
+				{Authority: key1, Nonce: 7},
+				{Authority: key3, Nonce: 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testRequest(t, testKey(t, 2))
+			req.MoreAuthorizations = tt.more
+			req.Data = tt.data
+			signedTx, err := GenerateSet7702AuthTx(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tx, err := DecodeSetCodeTx(signedTx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hash, err := tx.Hash()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			summary, err := summarizeSignedTx(signedTx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if summary.Sender != key2 || summary.Nonce != 3 || summary.ChainID.Cmp(tx.ChainID) != 0 {
+				t.Errorf("sender %s nonce %d chain %s, want %s nonce 3 chain %s", summary.Sender.Hex(), summary.Nonce, summary.ChainID, key2.Hex(), tx.ChainID)
+			}
+			if summary.To != tx.To || !bytes.Equal(summary.Data, tx.Data) || summary.Value.Cmp(tx.Value) != 0 {
+				t.Errorf("call to %s data %x value %s, want %s data %x value %s", summary.To.Hex(), summary.Data, summary.Value, tx.To.Hex(), tx.Data, tx.Value)
+			}
+			if summary.GasTipCap.Cmp(tx.GasTipCap) != 0 || summary.GasFeeCap.Cmp(tx.GasFeeCap) != 0 || summary.Gas != tx.Gas {
+				t.Errorf("fees %s/%s gas %d, want %s/%s gas %d", summary.GasTipCap, summary.GasFeeCap, summary.Gas, tx.GasTipCap, tx.GasFeeCap, tx.Gas)
+			}
+			if summary.Hash != hash {
+				t.Errorf("hash %s, want %s", summary.Hash.Hex(), hash.Hex())
+			}
+			if len(summary.Authorizations) != len(tt.auths) {
+				t.Fatalf("%d authorizations, want %d", len(summary.Authorizations), len(tt.auths))
+			}
+			for i, want := range tt.auths {
+				got := summary.Authorizations[i]
+				if got.Authority != want.Authority || got.Nonce != want.Nonce || got.Target != req.TemplateAddress || got.Chain.Cmp(req.ChainId) != 0 {
+					t.Errorf("authorization %d = %s nonce %d to %s on chain %s, want %s nonce %d to %s on chain %s", i+1,
+						got.Authority.Hex(), got.Nonce, got.Target.Hex(), got.Chain, want.Authority.Hex(), want.Nonce, req.TemplateAddress.Hex(), req.ChainId)
+				}
+			}
+
+			var printed bytes.Buffer
+			saved := promptOutput
+			promptOutput = &printed
+			summary.print()
+			promptOutput = saved
+			for _, auth := range tt.auths {
+				if !strings.Contains(printed.String(), auth.Authority.Hex()) {
+					t.Errorf("the printed summary does not show %s:\n%s", auth.Authority.Hex(), printed.String())
+				}
+			}
+		})
+	}
+}
+
+// TestSetCallDeclinedBeforeEstimate checks that a set with --data is confirmed
+// before its gas is estimated, so declining hands no signed authorization to the node
+func TestSetCallDeclinedBeforeEstimate(t *testing.T) {
+	stub := &rpcStub{clean: true}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	var stderr bytes.Buffer
+	savedPrompt, savedStdin := promptOutput, os.Stdin
+	promptOutput = &stderr
+	defer func() { promptOutput, os.Stdin = savedPrompt, savedStdin }()
+	stdin, answers, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	answers.WriteString("n\n")
+	answers.Close()
+	os.Stdin = stdin
+
+	t.Setenv("TEST_VICTIM_KEY", strings.Repeat("0", 63)+"1")
+	t.Setenv("TEST_RELAYER_KEY", strings.Repeat("0", 63)+"2")
+	err = Set("0x000000000000000000000000000000000000dEaD", TxOptions{
+		RPCURL:           server.URL,
+		ExpectAddress:    testKey(t, 1).Address().Hex(),
+		KeyEnv:           "TEST_VICTIM_KEY",
+		RelayerKeyEnv:    "TEST_RELAYER_KEY",
+		AllowEmptyTarget: true,
+		CallData:         []byte{0x81, 0x29, 0xfc, 0x1c},
+	})
+	if !errors.Is(err, ErrCancelled) {
+		t.Fatalf("error = %v, want ErrCancelled\nstderr:\n%s", err, stderr.String())
+	}
+	if stub.estimates != 0 || len(stub.nonces) != 0 {
+		t.Fatalf("declined set reached the node: %d estimates, %d transactions", stub.estimates, len(stub.nonces))
+	}
+}
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// azureKeyVaultAPIVersion is the Key Vault REST API version used
+const azureKeyVaultAPIVersion = "7.4"
+
+// AzureKeyVaultSigner is a Signer backed by a secp256k1 (P-256K) key of Azure
+// Key Vault or Managed HSM, so the key never leaves the vault. It authenticates
+// with the bearer token of AZURE_ACCESS_TOKEN, e.g. from
+// `az account get-access-token --resource https://vault.azure.net`, or else with
+// the client secret of a service principal from AZURE_TENANT_ID, AZURE_CLIENT_ID
+// and AZURE_CLIENT_SECRET.
+type AzureKeyVaultSigner struct {
+	keyID   string // Key URL including its version, so every signature uses the key the address was read from
+	address common.Address
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time // Zero for AZURE_ACCESS_TOKEN, which cannot be renewed
+}
+
+// NewAzureKeyVaultSigner returns a Signer for the key at keyURL, e.g.
+// https://my-vault.vault.azure.net/keys/relayer, the latest version being used
+// when the URL has none. The key must be an EC key on the P-256K curve.
+func NewAzureKeyVaultSigner(keyURL string) (*AzureKeyVaultSigner, error) {
+	u, err := url.Parse(strings.TrimRight(keyURL, "/"))
+	parts := []string{}
+	if err == nil {
+		parts = strings.Split(strings.Trim(u.Path, "/"), "/")
+	}
+	if err != nil || u.Scheme != "https" || u.Host == "" || len(parts) < 2 || len(parts) > 3 || parts[0] != "keys" {
+		return nil, invalidInput("invalid Azure Key Vault key URL %q, expected https://<vault>.vault.azure.net/keys/<name>[/<version>]", keyURL)
+	}
+	signer := &AzureKeyVaultSigner{}
+
+	var key struct {
+		Key struct {
+			KeyID string `json:"kid"`
+			Type  string `json:"kty"`
+			Curve string `json:"crv"`
+			X     string `json:"x"`
+			Y     string `json:"y"`
+		} `json:"key"`
+	}
+	if err := signer.call(http.MethodGet, u.Scheme+"://"+u.Host+u.Path, nil, &key); err != nil {
+		return nil, fmt.Errorf("failed to read the Azure Key Vault key: %w", err)
+	}
+	if (key.Key.Type != "EC" && key.Key.Type != "EC-HSM") || key.Key.Curve != "P-256K" {
+		return nil, invalidInput("Azure Key Vault key %s is a %s %s key, Ethereum needs an EC key on the P-256K curve", keyURL, key.Key.Type, key.Key.Curve)
+	}
+	x, errX := base64.RawURLEncoding.DecodeString(key.Key.X)
+	y, errY := base64.RawURLEncoding.DecodeString(key.Key.Y)
+	if errX != nil || errY != nil || len(x) > 32 || len(y) > 32 {
+		return nil, errors.New("Azure Key Vault returned an invalid public key")
+	}
+	pub, err := crypto.UnmarshalPubkey(append(append([]byte{0x04}, common.LeftPadBytes(x, 32)...), common.LeftPadBytes(y, 32)...))
+	if err != nil {
+		return nil, fmt.Errorf("Azure Key Vault returned an invalid public key: %w", err)
+	}
+	signer.keyID = key.Key.KeyID
+	signer.address = crypto.PubkeyToAddress(*pub)
+	return signer, nil
+}
+
+// Address returns the account of the vault key
+func (s *AzureKeyVaultSigner) Address() common.Address {
+	return s.address
+}
+
+// SignHash has the vault sign the digest with ES256K. The vault returns R and S
+// only: S is normalized to the lower half of the curve order, as Ethereum
+// requires, and the recovery id is the one recovering to the key's address.
+func (s *AzureKeyVaultSigner) SignHash(hash []byte) ([]byte, error) {
+	request := map[string]string{
+		"alg":   "ES256K",
+		"value": base64.RawURLEncoding.EncodeToString(hash),
+	}
+	var result struct {
+		Value string `json:"value"`
+	}
+	if err := s.call(http.MethodPost, s.keyID+"/sign", request, &result); err != nil {
+		return nil, fmt.Errorf("Azure Key Vault failed to sign: %w", err)
+	}
+	rs, err := base64.RawURLEncoding.DecodeString(result.Value)
+	if err != nil || len(rs) != 64 {
+		return nil, errors.New("Azure Key Vault returned an invalid signature")
+	}
+
+	n := crypto.S256().Params().N
+	sValue := new(big.Int).SetBytes(rs[32:])
+	if sValue.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		sValue.Sub(n, sValue)
+		sValue.FillBytes(rs[32:])
+	}
+	sig := append(rs, 0)
+	for v := byte(0); v < 2; v++ {
+		sig[crypto.RecoveryIDOffset] = v
+		if pub, err := crypto.SigToPub(hash, sig); err == nil && crypto.PubkeyToAddress(*pub) == s.address {
+			return sig, nil
+		}
+	}
+	return nil, fmt.Errorf("the Azure Key Vault signature does not recover to %s", s.address.Hex())
+}
+
+// call makes a Key Vault REST call and decodes its JSON answer
+func (s *AzureKeyVaultSigner) call(method, endpoint string, request, result interface{}) error {
+	token, err := s.accessToken()
+	if err != nil {
+		return err
+	}
+	var body io.Reader
+	if request != nil {
+		payload, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(payload)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, endpoint+"?api-version="+azureKeyVaultAPIVersion, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent())
+	return doAzureRequest(req, result)
+}
+
+// accessToken returns the bearer token for the vault, requesting a new one
+// from Microsoft Entra ID when using a service principal and the last one expires
+func (s *AzureKeyVaultSigner) accessToken() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if token := os.Getenv("AZURE_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+	if s.token != "" && time.Until(s.expires) > time.Minute {
+		return s.token, nil
+	}
+
+	tenant, client, secret := os.Getenv("AZURE_TENANT_ID"), os.Getenv("AZURE_CLIENT_ID"), os.Getenv("AZURE_CLIENT_SECRET")
+	if tenant == "" || client == "" || secret == "" {
+		return "", invalidInput("set AZURE_ACCESS_TOKEN, or AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET, to use Azure Key Vault")
+	}
+	authority := strings.TrimRight(os.Getenv("AZURE_AUTHORITY_HOST"), "/")
+	if authority == "" {
+		authority = "https://login.microsoftonline.com"
+	}
+	scope := "https://vault.azure.net/.default"
+	if strings.Contains(s.keyID, ".managedhsm.") {
+		scope = "https://managedhsm.azure.net/.default"
+	}
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {client},
+		"client_secret": {secret},
+		"scope":         {scope},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, authority+"/"+url.PathEscape(tenant)+"/oauth2/v2.0/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", userAgent())
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := doAzureRequest(req, &result); err != nil {
+		return "", fmt.Errorf("failed to get an Azure access token: %w", err)
+	}
+	s.token = result.AccessToken
+	s.expires = time.Now().Add(time.Duration(result.ExpiresIn) * time.Second)
+	return s.token, nil
+}
+
+// doAzureRequest sends a request to Azure and decodes its JSON answer, turning
+// Azure error bodies into errors
+func doAzureRequest(req *http.Request, result interface{}) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Key Vault answers {"error": {"message": ...}}, Entra ID {"error_description": ...}
+		var failure struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+			Description string `json:"error_description"`
+		}
+		json.Unmarshal(body, &failure)
+		if message := failure.Error.Message + failure.Description; message != "" {
+			return fmt.Errorf("%w: %s", &httpStatusError{status: resp.StatusCode}, message)
+		}
+		return &httpStatusError{status: resp.StatusCode}
+	}
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	return nil
+}
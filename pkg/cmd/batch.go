@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/fatih/color"
+)
+
+// Behaviors of a batch when an item's broadcast fails (--on-error)
+const (
+	OnErrorSkip       = "skip"        // Report the item and go on, re-reading the relayer nonce from the node
+	OnErrorHalt       = "halt"        // Stop reading items, wait for the ones already broadcast
+	OnErrorReuseNonce = "reuse-nonce" // Go on, signing the next item with the nonce of the failed one
+)
+
+// ParseOnError validates an --on-error value, empty meaning OnErrorSkip
+func ParseOnError(value string) (string, error) {
+	switch value {
+	case "":
+		return OnErrorSkip, nil
+	case OnErrorSkip, OnErrorHalt, OnErrorReuseNonce:
+		return value, nil
+	}
+	return "", invalidInput("invalid --on-error value %q (expected halt, skip or reuse-nonce)", value)
+}
+
+// broadcastFailure is returned when the node did not accept a transaction, as
+// opposed to failures before signing, which leave the relayer nonce unused
+type broadcastFailure struct {
+	nonce uint64
+	err   error
+}
+
+func (e *broadcastFailure) Error() string {
+	return fmt.Sprintf("failed to broadcast transaction with relayer nonce %d: %v", e.nonce, e.err)
+}
+
+func (e *broadcastFailure) Unwrap() error {
+	return e.err
+}
+
+// batchStep broadcasts the transaction of one batch item and returns the step
+// that waits for it and runs any follow-up work
+type batchStep func(userPrivateKey *ecdsa.PrivateKey, relayer *relayerSession) (func() error, error)
+
+// pendingItem is a broadcast batch item whose receipt has not been awaited yet
+type pendingItem struct {
+	number  int
+	key     *ecdsa.PrivateKey
+	address common.Address
+	finish  func() error
+}
+
+// runBatch runs a set/clear session over several accounts. The relayer key is
+// read and validated once and its signer and nonces are shared by every item,
+// then authority keys are read one at a time until an empty line and handed to
+// start. Items are broadcast in chunks of opts.BatchSize, opts.BatchDelay
+// apart, and each chunk's receipts are awaited before the next chunk starts.
+// A failing item is reported and the batch moves on to the next one. With
+// CheckpointFile, each account's progress is persisted and accounts recorded by
+// a previous run are skipped. OnError decides what a failed broadcast does to
+// the rest of the batch and to the relayer nonce sequence.
+func runBatch(label string, opts TxOptions, start batchStep) error {
+	onError, err := ParseOnError(opts.OnError)
+	if err != nil {
+		return err
+	}
+
+	progress, err := loadCheckpoint(opts.CheckpointFile)
+	if err != nil {
+		return fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+	if progress != nil && len(progress.Items) > 0 {
+		fmt.Fprintf(promptOutput, "Resuming from %s: %d accounts already processed\n", opts.CheckpointFile, len(progress.Items))
+	}
+
+	relayer, release, err := readRelayer(nil, "Please enter the private key of the address that will pay for gas fees (used for the whole batch):", opts)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	fmt.Fprintf(promptOutput, "\nRelayer address: %s\n", relayer.Address().Hex())
+	if err := relayer.validate(); err != nil {
+		return err
+	}
+
+	succeeded, failed := 0, 0
+	var pending []pendingItem
+	// flush waits for the receipts of the current chunk
+	flush := func() {
+		if len(pending) > 1 {
+			fmt.Fprintf(promptOutput, "\nWaiting for the %d transactions of this chunk...\n", len(pending))
+		}
+		for _, item := range pending {
+			err := item.finish()
+			zeroKey(item.key)
+			if err != nil {
+				failed++
+				notice(color.FgRed, "✗ Item #%d: %v", item.number, err)
+				continue
+			}
+			succeeded++
+			if err := progress.record(item.address, checkpointDone); err != nil {
+				notice(color.FgYellow, "Warning: failed to update the checkpoint: %v", err)
+			}
+		}
+		pending = nil
+	}
+
+	switch onError {
+	case OnErrorSkip:
+		fmt.Fprintln(promptOutput, "On a failed broadcast the item is skipped and the relayer nonce is re-read from the node (--on-error skip)")
+	case OnErrorHalt:
+		fmt.Fprintln(promptOutput, "On a failed broadcast the batch stops, after waiting for the transactions already sent (--on-error halt)")
+	case OnErrorReuseNonce:
+		fmt.Fprintln(promptOutput, "On a failed broadcast the next item is signed with the same relayer nonce (--on-error reuse-nonce)")
+	}
+
+	// sequence lists the relayer nonce of each broadcast item, in order
+	var sequence []string
+	broadcasts := 0
+	for number := 1; ; number++ {
+		notice(color.FgYellow, "\nPlease enter the private key of %s #%d (empty to finish the batch):", label, number)
+		userPrivateKey, err := readPrivateKey()
+		if errors.Is(err, errEmptyKey) {
+			break
+		}
+		if errors.Is(err, errPromptTimeout) {
+			flush()
+			return err
+		}
+		if err != nil {
+			failed++
+			notice(color.FgRed, "✗ Item #%d: %v", number, err)
+			continue
+		}
+
+		address := crypto.PubkeyToAddress(userPrivateKey.PublicKey)
+		switch progress.status(address) {
+		case checkpointDone:
+			zeroKey(userPrivateKey)
+			notice(color.FgGreen, "Item #%d: %s was completed in a previous run, skipped", number, address.Hex())
+			continue
+		case checkpointBroadcast:
+			zeroKey(userPrivateKey)
+			notice(color.FgYellow, "Item #%d: a transaction for %s was broadcast in a previous run without being confirmed, skipped; verify its state, then remove it from %s to process it again", number, address.Hex(), opts.CheckpointFile)
+			continue
+		}
+
+		if broadcasts > 0 && opts.BatchDelay > 0 {
+			select {
+			case <-opts.baseContext().Done():
+				zeroKey(userPrivateKey)
+				flush()
+				return opts.baseContext().Err()
+			case <-time.After(opts.BatchDelay):
+			}
+		}
+		finish, err := start(userPrivateKey, relayer)
+		if err != nil {
+			zeroKey(userPrivateKey)
+			failed++
+			notice(color.FgRed, "✗ Item #%d: %v", number, err)
+
+			if errors.Is(err, ErrEIP7702Unsupported) {
+				// Every other item would be rejected the same way
+				flush()
+				return err
+			}
+			var broadcastErr *broadcastFailure
+			if !errors.As(err, &broadcastErr) {
+				// Nothing was sent, the nonce is still free for the next item
+				continue
+			}
+			switch onError {
+			case OnErrorHalt:
+				notice(color.FgYellow, "Halting the batch (--on-error halt): no further item is read, relayer nonce %d stays unused", broadcastErr.nonce)
+				flush()
+				return fmt.Errorf("batch halted at item #%d after %d succeeded and %d failed: %w", number, succeeded, failed, err)
+			case OnErrorReuseNonce:
+				fmt.Fprintf(promptOutput, "The next item will reuse relayer nonce %d (--on-error reuse-nonce)\n", broadcastErr.nonce)
+			default:
+				fmt.Fprintln(promptOutput, "The relayer nonce of the next item will be re-read from the node (--on-error skip)")
+			}
+			continue
+		}
+		broadcasts++
+		if nonce, err := relayer.nonces.Next(); err == nil {
+			sequence = append(sequence, fmt.Sprintf("#%d: %d", number, nonce-1))
+		}
+		if err := progress.record(address, checkpointBroadcast); err != nil {
+			notice(color.FgYellow, "Warning: failed to update the checkpoint: %v", err)
+		}
+
+		pending = append(pending, pendingItem{number: number, key: userPrivateKey, address: address, finish: finish})
+		if len(pending) >= opts.batchSize() {
+			flush()
+		}
+	}
+	flush()
+
+	fmt.Fprintf(promptOutput, "\nBatch finished: %d succeeded, %d failed\n", succeeded, failed)
+	if len(sequence) > 0 {
+		fmt.Fprintf(promptOutput, "Relayer nonces used: %s\n", strings.Join(sequence, ", "))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d batch items failed", failed, succeeded+failed)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/fatih/color"
+)
+
+// ReadAddressesFile reads one address per line, skipping blank lines and # comments
+func ReadAddressesFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var addresses []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		addresses = append(addresses, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// addressFilters maps the --output-addresses filters to the state they select
+var addressFilters = map[string]CodeStatus{
+	"delegated": StatusDelegated,
+	"clean":     StatusClean,
+	"has-code":  StatusHasCode,
+}
+
+// CheckBatch checks several addresses, printing one line per address followed
+// by aggregate counts. With OnlyTarget set, only addresses delegated to that
+// contract are reported. With OutputAddresses set, stdout only lists the
+// addresses in that state, undecorated, for piping into other commands.
+func CheckBatch(addresses []string, opts CheckOptions) error {
+	if len(addresses) == 0 {
+		return fmt.Errorf("no addresses to check")
+	}
+
+	var filter CodeStatus
+	if opts.OutputAddresses != "" {
+		var ok bool
+		if filter, ok = addressFilters[opts.OutputAddresses]; !ok {
+			return invalidInput("invalid --output-addresses filter %q (expected delegated, clean or has-code)", opts.OutputAddresses)
+		}
+		if opts.OnlyTarget != "" && filter != StatusDelegated {
+			return invalidInput("--only-target can only be combined with --output-addresses delegated")
+		}
+	}
+
+	var onlyTarget common.Address
+	if opts.OnlyTarget != "" {
+		if !common.IsHexAddress(opts.OnlyTarget) {
+			return invalidInput("invalid target address format: %s", opts.OnlyTarget)
+		}
+		onlyTarget = common.HexToAddress(opts.OnlyTarget)
+	}
+
+	var registry common.Address
+	if opts.UseOnchainRegistry {
+		var err error
+		if registry, err = opts.registryAddress(); err != nil {
+			return err
+		}
+	}
+	// delegated describes a delegation, with the registry verdict when enabled
+	delegated := func(result *CheckResult) string {
+		line := fmt.Sprintf("⚠ %s is delegated to %s", result.Address.Hex(), result.Delegate.Hex())
+		if opts.UseOnchainRegistry {
+			line += fmt.Sprintf(" (registry: %s)", QueryRegistry(opts.rpcURLOrDefault(), registry, result.Delegate))
+		}
+		return line
+	}
+
+	if opts.ChainName {
+		chainID, err := getChainID(opts.rpcURLOrDefault())
+		if err != nil {
+			return fmt.Errorf("failed to get chain ID: %w", err)
+		}
+		notice(color.FgCyan, "Chain: %s", chainLabel(chainID))
+	}
+
+	counts := make(map[CodeStatus]int)
+	matches, failures := 0, 0
+	for _, address := range addresses {
+		result, err := CheckAddress(address, opts)
+		if err != nil {
+			failures++
+			if opts.OutputAddresses != "" {
+				notice(color.FgRed, "✗ %s: %v", address, err)
+			} else {
+				color.Red("✗ %s: %v", address, err)
+			}
+			continue
+		}
+		counts[result.Status]++
+
+		if opts.OutputAddresses != "" {
+			if result.Status == filter && (opts.OnlyTarget == "" || result.Delegate == onlyTarget) {
+				matches++
+				fmt.Println(result.Address.Hex())
+			}
+			continue
+		}
+
+		if opts.OnlyTarget != "" {
+			if result.Status == StatusDelegated && result.Delegate == onlyTarget {
+				matches++
+				color.Red(delegated(result))
+			}
+			continue
+		}
+
+		switch result.Status {
+		case StatusClean:
+			color.Green("✓ %s is safe (no code detected)", result.Address.Hex())
+		case StatusDelegated:
+			color.Red(delegated(result))
+		default:
+			if opts.Strict {
+				color.Red("✗ %s has code deployed that is not an EIP-7702 delegation", result.Address.Hex())
+			} else {
+				color.Yellow("⚠ %s has code deployed and might be a contract", result.Address.Hex())
+			}
+		}
+	}
+
+	summary := resultOutput
+	if opts.OutputAddresses != "" {
+		// Stdout is the address list, the summary goes with the prose
+		summary = promptOutput
+	}
+	fmt.Fprintf(summary, "\nChecked %d addresses: %d clean, %d delegated, %d with other code, %d failed\n",
+		len(addresses), counts[StatusClean], counts[StatusDelegated], counts[StatusHasCode], failures)
+	if opts.OutputAddresses != "" {
+		fmt.Fprintf(summary, "%d addresses listed (%s)\n", matches, opts.OutputAddresses)
+	} else if opts.OnlyTarget != "" {
+		fmt.Printf("%d addresses delegated to %s\n", matches, onlyTarget.Hex())
+	}
+	if opts.Strict && counts[StatusHasCode] > 0 {
+		return fmt.Errorf("%d addresses have unexpected code (--strict)", counts[StatusHasCode])
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestClearBatchOnError runs a batch clear of keys 3, 4 and 5 whose second
+// broadcast is refused, and checks the relayer nonces each --on-error policy
+// signs the transactions with
+func TestClearBatchOnError(t *testing.T) {
+	tests := []struct {
+		name    string
+		onError string
+		nonces  []uint64
+		err     string
+	}{
+		{"skip re-reads the nonce", OnErrorSkip, []uint64{9, 10, 9}, "1 of 3 batch items failed"},
+		{"skip by default", "", []uint64{9, 10, 9}, "1 of 3 batch items failed"},
+		{"reuse nonce", OnErrorReuseNonce, []uint64{9, 10, 10}, "1 of 3 batch items failed"},
+		{"halt", OnErrorHalt, []uint64{9, 10}, "batch halted at item #2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &rpcStub{rejectSend: 2}
+			server := httptest.NewServer(stub)
+			defer server.Close()
+
+			savedPrompt, savedKeys, savedUsed := promptOutput, demoKeys, demoKeysUsed
+			promptOutput = io.Discard
+			demoKeys, demoKeysUsed = []*ecdsa.PrivateKey{testKey(t, 3).key, testKey(t, 4).key, testKey(t, 5).key}, 0
+			defer func() { promptOutput, demoKeys, demoKeysUsed = savedPrompt, savedKeys, savedUsed }()
+
+			t.Setenv("TEST_RELAYER_KEY", strings.Repeat("0", 63)+"2")
+			err := Clear(TxOptions{
+				RPCURL:        server.URL,
+				Batch:         true,
+				Yes:           true,
+				NoWait:        true,
+				OnError:       tt.onError,
+				RelayerKeyEnv: "TEST_RELAYER_KEY",
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error = %v, want %q", err, tt.err)
+			}
+			if !reflect.DeepEqual(stub.nonces, tt.nonces) {
+				t.Fatalf("relayer nonces %v, want %v", stub.nonces, tt.nonces)
+			}
+		})
+	}
+}
+
+func TestParseOnError(t *testing.T) {
+	tests := []struct {
+		value, want string
+		err         bool
+	}{
+		{"", OnErrorSkip, false},
+		{"skip", OnErrorSkip, false},
+		{"halt", OnErrorHalt, false},
+		{"reuse-nonce", OnErrorReuseNonce, false},
+		{"retry", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseOnError(tt.value)
+		if tt.err {
+			if ErrorType(err) != ErrorTypeInvalidInput {
+				t.Errorf("ParseOnError(%q) error = %v, want invalid input", tt.value, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseOnError(%q) = %q, %v, want %q", tt.value, got, err, tt.want)
+		}
+	}
+}
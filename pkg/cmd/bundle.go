@@ -0,0 +1,351 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/fatih/color"
+)
+
+// bundleVersion is the format version written to new bundles
+const bundleVersion = 1
+
+// Bundle is a signed set/clear transaction together with everything needed to
+// review it before it is broadcast, for a propose-review-execute workflow
+type Bundle struct {
+	Version            int            `json:"version"`
+	Action             string         `json:"action"` // "set" or "clear"
+	CreatedAt          time.Time      `json:"createdAt"`
+	ChainID            *hexutil.Big   `json:"chainId"`
+	Authority          common.Address `json:"authority"`
+	AuthorityNonce     hexutil.Uint64 `json:"authorityNonce"`
+	Relayer            common.Address `json:"relayer"`
+	RelayerNonce       hexutil.Uint64 `json:"relayerNonce"`
+	Target             common.Address `json:"target"`
+	GasTipCap          *hexutil.Big   `json:"maxPriorityFeePerGas"`
+	GasFeeCap          *hexutil.Big   `json:"maxFeePerGas"`
+	GasLimit           hexutil.Uint64 `json:"gas"`
+	UnsignedTx         hexutil.Bytes  `json:"unsignedTx"`
+	AuthoritySignature hexutil.Bytes  `json:"authoritySignature"`
+	RelayerSignature   hexutil.Bytes  `json:"relayerSignature"`
+	SignedTx           hexutil.Bytes  `json:"signedTx"`
+	TxHash             common.Hash    `json:"txHash"`
+}
+
+// newBundle collects the artifacts of a signed authorization transaction
+func newBundle(action authAction, signedTx string, req SetAuthorizationRequest) (*Bundle, error) {
+	tx, err := DecodeSetCodeTx(signedTx)
+	if err != nil {
+		return nil, err
+	}
+	if len(tx.AuthList) != 1 {
+		return nil, fmt.Errorf("expected 1 authorization, got %d", len(tx.AuthList))
+	}
+	unsigned, err := tx.unsignedBytes()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := tx.Hash()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := hex.DecodeString(signedTx)
+	if err != nil {
+		return nil, err
+	}
+	relayer, err := req.relayerSigner()
+	if err != nil {
+		return nil, err
+	}
+	authority, err := req.authority()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bundle{
+		Version:            bundleVersion,
+		Action:             bundleAction(action.Template),
+		CreatedAt:          time.Now().UTC(),
+		ChainID:            (*hexutil.Big)(tx.ChainID),
+		Authority:          authority,
+		AuthorityNonce:     hexutil.Uint64(tx.AuthList[0].Nonce),
+		Relayer:            relayer.Address(),
+		RelayerNonce:       hexutil.Uint64(tx.Nonce),
+		Target:             tx.AuthList[0].Address,
+		GasTipCap:          (*hexutil.Big)(tx.GasTipCap),
+		GasFeeCap:          (*hexutil.Big)(tx.GasFeeCap),
+		GasLimit:           hexutil.Uint64(tx.Gas),
+		UnsignedTx:         unsigned,
+		AuthoritySignature: tx.AuthList[0].signature(),
+		RelayerSignature:   tx.signature(),
+		SignedTx:           raw,
+		TxHash:             hash,
+	}, nil
+}
+
+// bundleAction names the operation a template address stands for
+func bundleAction(template common.Address) string {
+	if template == (common.Address{}) {
+		return "clear"
+	}
+	return "set"
+}
+
+// Verify checks that the signed transaction is consistent with every other
+// field of the bundle: hash, unsigned payload, both signatures and the
+// addresses they recover to, and the network parameters
+func (b *Bundle) Verify() error {
+	tx, err := DecodeSetCodeTx(hex.EncodeToString(b.SignedTx))
+	if err != nil {
+		return fmt.Errorf("invalid signed transaction: %w", err)
+	}
+
+	var diffs []string
+	check := func(field string, expected, actual interface{}) {
+		if fmt.Sprint(expected) != fmt.Sprint(actual) {
+			diffs = append(diffs, fmt.Sprintf("  %-22s bundle says %v, transaction has %v", field+":", expected, actual))
+		}
+	}
+
+	hash, err := tx.Hash()
+	if err != nil {
+		return err
+	}
+	check("tx hash", b.TxHash.Hex(), hash.Hex())
+	unsigned, err := tx.unsignedBytes()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(unsigned, b.UnsignedTx) {
+		diffs = append(diffs, "  unsigned tx:           does not match the signed transaction")
+	}
+	if !bytes.Equal(tx.signature(), b.RelayerSignature) {
+		diffs = append(diffs, "  relayer signature:     does not match the signed transaction")
+	}
+
+	if len(tx.AuthList) > 0 {
+		// The transaction calls the authorized account instead of the template when it carries a call
+		check("action", b.Action, bundleAction(tx.AuthList[0].Address))
+	}
+	check("chain id", (*bigString)(b.ChainID), tx.ChainID)
+	check("relayer nonce", uint64(b.RelayerNonce), tx.Nonce)
+	check("priority fee", (*bigString)(b.GasTipCap), tx.GasTipCap)
+	check("max fee per gas", (*bigString)(b.GasFeeCap), tx.GasFeeCap)
+	check("gas limit", uint64(b.GasLimit), tx.Gas)
+	if sender, err := tx.Sender(); err != nil {
+		diffs = append(diffs, fmt.Sprintf("  relayer:               failed to recover: %v", err))
+	} else {
+		check("relayer", b.Relayer.Hex(), sender.Hex())
+	}
+
+	check("authorizations", 1, len(tx.AuthList))
+	if len(tx.AuthList) == 1 {
+		auth := tx.AuthList[0]
+		check("auth chain id", (*bigString)(b.ChainID), auth.ChainID)
+		check("authority nonce", uint64(b.AuthorityNonce), auth.Nonce)
+		check("target", b.Target.Hex(), auth.Address.Hex())
+		if !bytes.Equal(auth.signature(), b.AuthoritySignature) {
+			diffs = append(diffs, "  authority signature:   does not match the signed transaction")
+		}
+		if authority, err := auth.Authority(); err != nil {
+			diffs = append(diffs, fmt.Sprintf("  authority:             failed to recover: %v", err))
+		} else {
+			check("authority", b.Authority.Hex(), authority.Hex())
+		}
+	}
+
+	if len(diffs) > 0 {
+		return fmt.Errorf("bundle is inconsistent:\n%s", strings.Join(diffs, "\n"))
+	}
+	return nil
+}
+
+// bigString prints a possibly nil hexutil.Big as a decimal number, like *big.Int
+type bigString hexutil.Big
+
+func (b *bigString) String() string {
+	if b == nil {
+		return "<nil>"
+	}
+	return (*hexutil.Big)(b).ToInt().String()
+}
+
+// WriteBundle writes a bundle as indented JSON, readable by the owner only
+func WriteBundle(path string, bundle *Bundle) error {
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// ReadBundle reads a bundle written by WriteBundle
+func ReadBundle(path string) (*Bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var bundle Bundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("invalid bundle file %s: %w", path, err)
+	}
+	if bundle.Version != bundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", bundle.Version)
+	}
+	return &bundle, nil
+}
+
+// ErrTxExpired is returned for a pre-signed transaction whose nonce, or whose
+// authorization's nonce, the account has already used
+var ErrTxExpired = errors.New("this pre-signed transaction has expired")
+
+// checkNotExpired compares the nonces decoded from a pre-signed transaction
+// with the mined nonces of the recovered sender and authorities. A nonce
+// below the account's has been used since signing: the node would reject the
+// transaction as "nonce too low", or skip the authorization, and signing again
+// is the only way forward.
+func checkNotExpired(rpcURL string, tx *SetCodeTx) error {
+	sender, err := tx.Sender()
+	if err != nil {
+		return fmt.Errorf("failed to recover the sender: %w", err)
+	}
+	senderNonce, err := getNonce(rpcURL, sender.Hex())
+	if err != nil {
+		return fmt.Errorf("failed to get sender nonce: %w", err)
+	}
+	if tx.Nonce < uint64(senderNonce) {
+		return WithErrorType(ErrorTypeInvalidInput, fmt.Errorf("%w: it was signed for nonce %d of %s, which is already at nonce %d; sign a new one",
+			ErrTxExpired, tx.Nonce, sender.Hex(), senderNonce))
+	}
+	for _, auth := range tx.AuthList {
+		authority, err := auth.Authority()
+		if err != nil || authority == sender {
+			// Invalid authorizations are skipped anyway, and the sender's follows the transaction nonce
+			continue
+		}
+		authorityNonce, err := getNonce(rpcURL, authority.Hex())
+		if err != nil {
+			return fmt.Errorf("failed to get authority nonce: %w", err)
+		}
+		if auth.Nonce < uint64(authorityNonce) {
+			return WithErrorType(ErrorTypeInvalidInput, fmt.Errorf("%w: its authorization was signed for nonce %d of %s, which is already at nonce %d; sign a new one",
+				ErrTxExpired, auth.Nonce, authority.Hex(), authorityNonce))
+		}
+	}
+	return nil
+}
+
+// BroadcastBundle verifies a reviewed bundle against itself and the current
+// chain state, then broadcasts its signed transaction and waits for it
+func BroadcastBundle(path string, opts TxOptions) error {
+	rpcURL := opts.rpcURLOrDefault()
+
+	bundle, err := ReadBundle(path)
+	if err != nil {
+		return err
+	}
+	if err := bundle.Verify(); err != nil {
+		return err
+	}
+	notice(color.FgGreen, "✓ Bundle is internally consistent")
+
+	chainID, err := getChainID(rpcURL)
+	if err != nil {
+		return fmt.Errorf("failed to get chain ID: %w", err)
+	}
+	if chainID.Cmp(bundle.ChainID.ToInt()) != 0 {
+		return fmt.Errorf("bundle is for chain %s but the RPC endpoint is on chain %s", bundle.ChainID.ToInt(), chainID)
+	}
+	if err := checkEIP7702Support(chainID); err != nil {
+		return err
+	}
+	tx, err := DecodeSetCodeTx(hex.EncodeToString(bundle.SignedTx))
+	if err != nil {
+		return fmt.Errorf("failed to decode the signed transaction: %w", err)
+	}
+	if err := checkNotExpired(rpcURL, tx); err != nil {
+		return err
+	}
+	// Stale nonces would make the node reject the transaction, or silently skip the authorization
+	authorityNonce, relayerNonce, err := currentNonces(rpcURL, bundle.Authority, bundle.Relayer)
+	if err != nil {
+		return err
+	}
+	if authorityNonce != uint64(bundle.AuthorityNonce) || relayerNonce != uint64(bundle.RelayerNonce) {
+		return fmt.Errorf("bundle is stale: signed for authority nonce %d and relayer nonce %d, current nonces call for %d and %d",
+			uint64(bundle.AuthorityNonce), uint64(bundle.RelayerNonce), authorityNonce, relayerNonce)
+	}
+
+	notice(color.FgCyan, "\nChain: %s", chainLabel(chainID))
+	fmt.Fprintf(promptOutput, "Action: %s\n", bundle.Action)
+	fmt.Fprintf(promptOutput, "Authority: %s\n", bundle.Authority.Hex())
+	fmt.Fprintf(promptOutput, "Relayer: %s\n", bundle.Relayer.Hex())
+	if bundle.Action == "set" {
+		fmt.Fprintf(promptOutput, "Target: %s\n", bundle.Target.Hex())
+	}
+	printGasInfo(bundle.GasTipCap.ToInt(), bundle.GasFeeCap.ToInt(), uint64(bundle.GasLimit))
+	fmt.Fprintf(promptOutput, "Transaction hash: %s\n", bundle.TxHash.Hex())
+	if opts.DryRun {
+		return printDryRun(hex.EncodeToString(bundle.SignedTx))
+	}
+
+	if opts.Yes {
+		opts.console().info("\nConfirmation skipped (--yes)")
+	} else {
+		notice(color.FgYellow, "\nBroadcast this %s transaction? (y/n)", bundle.Action)
+		if err := confirmOrCancel(); err != nil {
+			return err
+		}
+	}
+
+	result := &authResult{
+		User:      bundle.Authority,
+		ChainID:   chainID,
+		GasTip:    bundle.GasTipCap.ToInt(),
+		GasFeeCap: bundle.GasFeeCap.ToInt(),
+		Parent:    opts.Context,
+	}
+	if opts.MaxWait > 0 {
+		result.Deadline = time.Now().Add(opts.MaxWait)
+	}
+	ctx, cancel := result.context()
+	defer cancel()
+
+	result.TxHash, err = broadcastRawTx(ctx, hex.EncodeToString(bundle.SignedTx), rpcURL)
+	if err != nil {
+		if ctx.Err() != nil {
+			notice(color.FgYellow, "%s before the node acknowledged the broadcast; look up %s before retrying.", waitEnded(ctx), bundle.TxHash.Hex())
+		}
+		return fmt.Errorf("failed to broadcast transaction: %w", err)
+	}
+	printTxHash("\nTransaction successfully sent! Transaction hash:", result.TxHash)
+
+	action := authAction{Template: bundle.Target, Done: "set"}
+	if bundle.Action == "clear" {
+		action.Done = "cleared"
+	}
+	return awaitAuthorization(action, result, opts)
+}
+
+// writeProposal writes the bundle of a signed transaction instead of broadcasting it
+func writeProposal(path string, action authAction, signedTx string, req SetAuthorizationRequest) error {
+	bundle, err := newBundle(action, signedTx, req)
+	if err != nil {
+		return fmt.Errorf("failed to build bundle: %w", err)
+	}
+	if err := WriteBundle(path, bundle); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	notice(color.FgGreen, "\nSigned transaction written to %s (not broadcast)", path)
+	fmt.Fprintf(promptOutput, "Transaction hash: %s\n", bundle.TxHash.Hex())
+	fmt.Fprintf(promptOutput, "After review, submit it with: eip7702cleaner broadcast --bundle %s\n", path)
+	return nil
+}
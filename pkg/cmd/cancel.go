@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/fatih/color"
+)
+
+// Cancel performs the cancel command: it replaces the pending transaction
+// txHash with a 0-value transfer from its sender to itself at the same nonce
+// and higher fees, so that the original is never mined. Only the key of the
+// sender is needed.
+func Cancel(txHash string, opts TxOptions) error {
+	percent, err := opts.replacementBump()
+	if err != nil {
+		return err
+	}
+	for _, validate := range []func() error{opts.validateTxOut, opts.validateKeystores} {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+
+	rpcCtx, cancel := context.WithTimeout(opts.baseContext(), time.Minute)
+	defer cancel()
+	p, err := fetchPendingTx(rpcCtx, opts.rpcURLOrDefault(), strings.TrimSpace(txHash))
+	if err != nil {
+		return err
+	}
+	p.print()
+	for _, auth := range p.tx.AuthorizationList {
+		if auth.Address == (common.Address{}) {
+			notice(color.FgRed, "⚠ %s clears a delegation: once cancelled, the delegation stays in place and the account remains exposed until a new clear is mined.", p.hash)
+			break
+		}
+	}
+
+	gasTip, gasFeeCap, err := p.replacementFees(percent, opts)
+	if err != nil {
+		return err
+	}
+	if opts.feesPinned() {
+		fmt.Fprintln(promptOutput, "\nCancellation fees, from --max-fee/--priority-fee:")
+	} else {
+		fmt.Fprintf(promptOutput, "\nCancellation fees, %g%% over the pending transaction or the current suggestion when higher:\n", percent)
+	}
+	printGasInfo(gasTip, gasFeeCap, transferGasLimit)
+	if err := checkCostCeiling(p.chainID, maxGasCost(gasFeeCap, transferGasLimit), opts); err != nil {
+		return err
+	}
+
+	relayer, release, err := readSender(p, opts)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	signedTx, err := buildDynamicFeeTx(p.chainID, relayer.signer, p.nonce, gasTip, gasFeeCap,
+		transferGasLimit, p.sender, new(big.Int), []byte{})
+	if err != nil {
+		return fmt.Errorf("failed to sign the cancellation: %w", err)
+	}
+	if sender, err := dynamicFeeTxSender(signedTx, p.chainID); err != nil || sender != p.sender {
+		return verificationFailure("the signed cancellation does not recover to %s, aborting", p.sender.Hex())
+	}
+
+	if opts.Yes {
+		opts.console().info("\nConfirmation skipped (--yes)")
+	} else if !opts.DryRun {
+		fmt.Fprintf(promptOutput, "\nReplace %s with a 0-value transfer from %s to itself at nonce %d? (y/n)\n", p.hash, p.sender.Hex(), p.nonce)
+		if err := confirmOrCancel(); err != nil {
+			return err
+		}
+	}
+	return submitReplacement(p, signedTx, opts)
+}
+
+// dynamicFeeTxSender recovers the sender of the signed EIP-1559 transaction
+// rawTxHex
+func dynamicFeeTxSender(rawTxHex string, chainID *big.Int) (common.Address, error) {
+	raw, err := hex.DecodeString(rawTxHex)
+	if err != nil {
+		return common.Address{}, err
+	}
+	var tx types.Transaction
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return common.Address{}, err
+	}
+	return types.Sender(types.LatestSignerForChainID(chainID), &tx)
+}
@@ -2,12 +2,16 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/fatih/color"
@@ -33,58 +37,141 @@ type RPCResponse struct {
 	Error   interface{} `json:"error,omitempty"`
 }
 
+// DefaultCodeMethod is the RPC method used to fetch account code unless overridden
+const DefaultCodeMethod = "eth_getCode"
+
+// rpcMethodPattern matches JSON-RPC method names such as eth_getCode or custom_getDelegation
+var rpcMethodPattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9]*_[a-zA-Z0-9_]+$`)
+
 // Check performs the check command
-func Check(address string, rpcURL string, debug bool) error {
+func Check(address string, opts CheckOptions) error {
+	var registry common.Address
+	if opts.UseOnchainRegistry {
+		var err error
+		if registry, err = opts.registryAddress(); err != nil {
+			return err
+		}
+	}
+
+	result, err := CheckAddress(address, opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.ChainName {
+		chainID, err := getChainID(opts.rpcURLOrDefault())
+		if err != nil {
+			return fmt.Errorf("failed to get chain ID: %w", err)
+		}
+		notice(color.FgCyan, "Chain: %s", chainLabel(chainID))
+	}
+
+	switch result.Status {
+	case StatusClean:
+		if opts.Debug {
+			fmt.Fprintf(promptOutput, "Debug - No code found, considering address safe\n")
+		}
+		color.Green("✓ Address %s is safe (no code detected)", address)
+	case StatusDelegated:
+		if opts.Debug {
+			fmt.Fprintf(promptOutput, "Debug - Extracted contract address: %s\n", result.Delegate.Hex())
+		}
+		color.Red("⚠ Address %s has an EIP-7702 contract deployed", address)
+		color.Red("⚠ Contract address: %s", result.Delegate.Hex())
+		if opts.UseOnchainRegistry {
+			printRegistryVerdict(QueryRegistry(opts.rpcURLOrDefault(), registry, result.Delegate))
+		}
+	default:
+		// Code exists but doesn't match EIP-7702 pattern
+		if opts.Debug {
+			fmt.Fprintf(promptOutput, "Debug - Code exists but does not match EIP-7702 pattern\n")
+		}
+		if opts.Strict {
+			color.Red("✗ Address %s has code deployed that is not an EIP-7702 delegation", address)
+			return fmt.Errorf("address %s has unexpected code (--strict)", address)
+		}
+		color.Yellow("⚠ Address %s has code deployed and might be a contract", address)
+	}
+	return nil
+}
+
+// printRegistryVerdict reports what the on-chain registry says about a delegation target
+func printRegistryVerdict(verdict RegistryVerdict) {
+	switch verdict {
+	case VerdictFlagged:
+		color.Red("⚠ On-chain registry: contract is FLAGGED as malicious")
+	case VerdictNotFlagged:
+		color.Green("On-chain registry: contract is not flagged")
+	default:
+		color.Yellow("On-chain registry: verdict unknown (registry call failed)")
+	}
+}
+
+// CheckAddress fetches the code of an address and classifies its delegation status
+func CheckAddress(address string, opts CheckOptions) (*CheckResult, error) {
+	rpcURL := opts.RPCURL
+	debug := opts.Debug
 	// debug = true
 
 	if debug {
-		fmt.Println("========== DEBUG INFO START ==========")
-		fmt.Printf("Check function called with address: %s\n", address)
-		fmt.Printf("RPC URL parameter: '%s'\n", rpcURL)
+		fmt.Fprintln(promptOutput, "========== DEBUG INFO START ==========")
+		fmt.Fprintf(promptOutput, "Check function called with address: %s\n", address)
+		fmt.Fprintf(promptOutput, "RPC URL parameter: '%s'\n", rpcURL)
 	}
 
 	if address == "" {
-		return fmt.Errorf("address is required")
+		return nil, fmt.Errorf("address is required")
 	}
 
 	// Fix: rpcURL might be empty even when passed from command line
 	if rpcURL == "" {
 		rpcURL = DefaultRPCURL
 		if debug {
-			fmt.Printf("Using default RPC URL: %s\n", rpcURL)
+			fmt.Fprintf(promptOutput, "Using default RPC URL: %s\n", rpcURL)
 		}
 	} else {
 		if debug {
-			fmt.Printf("Using provided RPC URL: %s\n", rpcURL)
+			fmt.Fprintf(promptOutput, "Using provided RPC URL: %s\n", rpcURL)
 		}
 	}
 
 	// Debug information
 	if debug {
-		fmt.Printf("Debug - Using RPC URL: %s\n", rpcURL)
-		fmt.Printf("Debug - Checking address: %s\n", address)
+		fmt.Fprintf(promptOutput, "Debug - Using RPC URL: %s\n", rpcURL)
+		fmt.Fprintf(promptOutput, "Debug - Checking address: %s\n", address)
 	}
 
 	// Validate Ethereum address
 	if !common.IsHexAddress(address) {
-		return fmt.Errorf("invalid Ethereum address format: %s", address)
+		return nil, invalidInput("invalid Ethereum address format: %s", address)
+	}
+
+	codeMethod := opts.CodeMethod
+	if codeMethod == "" {
+		codeMethod = DefaultCodeMethod
+	}
+	if !rpcMethodPattern.MatchString(codeMethod) {
+		return nil, invalidInput("invalid RPC method name: %s (expected a name like eth_getCode)", codeMethod)
+	}
+	if debug {
+		fmt.Fprintf(promptOutput, "Debug - Code method: %s\n", codeMethod)
 	}
 
 	// Convert to checksum address
 	checksumAddr := common.HexToAddress(address)
 	if debug {
-		fmt.Printf("Debug - Checksum address: %s\n", checksumAddr.Hex())
+		fmt.Fprintf(promptOutput, "Debug - Checksum address: %s\n", checksumAddr.Hex())
 	}
 
 	// Create HTTP client with timeout
 	httpClient := &http.Client{
-		Timeout: 10 * time.Second,
+		Timeout: rpcTimeout(),
 	}
 
 	// Create JSON-RPC request
 	request := RPCRequest{
 		JSONRPC: "2.0",
-		Method:  "eth_getCode",
+		Method:  codeMethod,
 		Params:  []interface{}{checksumAddr.Hex(), "latest"},
 		ID:      1,
 	}
@@ -93,121 +180,167 @@ func Check(address string, rpcURL string, debug bool) error {
 	requestJSON, err := json.Marshal(request)
 	if err != nil {
 		if debug {
-			fmt.Printf("Error marshaling request: %v\n", err)
+			fmt.Fprintf(promptOutput, "Error marshaling request: %v\n", err)
 		}
-		return fmt.Errorf("failed to marshal JSON-RPC request: %w", err)
+		return nil, fmt.Errorf("failed to marshal JSON-RPC request: %w", err)
 	}
 
 	if debug {
-		fmt.Printf("Debug - JSON-RPC Request: %s\n", string(requestJSON))
-		fmt.Printf("Sending HTTP request to: %s\n", rpcURL)
+		fmt.Fprintf(promptOutput, "Debug - JSON-RPC Request: %s\n", string(requestJSON))
 	}
 
-	// Create HTTP request
-	httpReq, err := http.NewRequest("POST", rpcURL, bytes.NewBuffer(requestJSON))
-	if err != nil {
+	// Failing endpoints are retried and failed over like every other RPC call
+	body, err := withFailover(context.Background(), codeMethod, rpcEndpoints(rpcURL), func(ctx context.Context, endpoint string) ([]byte, error) {
 		if debug {
-			fmt.Printf("Error creating HTTP request: %v\n", err)
+			fmt.Fprintf(promptOutput, "Sending HTTP request to: %s\n", endpoint)
 		}
-		return fmt.Errorf("failed to create HTTP request: %w", err)
-	}
-
-	// Set headers
-	httpReq.Header.Set("Content-Type", "application/json")
 
-	// Send request
-	if debug {
-		fmt.Println("Sending HTTP request...")
-	}
-	resp, err := httpClient.Do(httpReq)
-	if err != nil {
+		// Create HTTP request
+		httpReq, err := newRPCRequest(ctx, endpoint, requestJSON)
+		if err != nil {
+			if debug {
+				fmt.Fprintf(promptOutput, "Error creating HTTP request: %v\n", err)
+			}
+			return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+		}
 		if debug {
-			fmt.Printf("HTTP request failed: %v\n", err)
+			fmt.Fprintf(promptOutput, "Debug - User-Agent: %s\n", httpReq.Header.Get("User-Agent"))
+			fmt.Fprintf(promptOutput, "Debug - Request ID: %s\n", httpReq.Header.Get("X-Request-Id"))
 		}
-		return fmt.Errorf("HTTP request failed: %w", err)
-	}
-	defer resp.Body.Close()
 
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
+		// Send request
 		if debug {
-			fmt.Printf("Error reading response body: %v\n", err)
+			fmt.Fprintln(promptOutput, "Sending HTTP request...")
 		}
-		return fmt.Errorf("failed to read response body: %w", err)
-	}
+		resp, err := httpClient.Do(httpReq)
+		if err != nil {
+			if debug {
+				fmt.Fprintf(promptOutput, "HTTP request failed: %v\n", err)
+			}
+			return nil, fmt.Errorf("HTTP request failed: %w", err)
+		}
+		defer resp.Body.Close()
 
-	if debug {
-		fmt.Printf("Debug - HTTP Status: %d\n", resp.StatusCode)
-		fmt.Printf("Debug - Raw HTTP Response: %s\n", string(body))
-	}
+		// Read response body
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			if debug {
+				fmt.Fprintf(promptOutput, "Error reading response body: %v\n", err)
+			}
+			return nil, fmt.Errorf("failed to read response body: %w", err)
+		}
 
-	// Parse JSON-RPC response
-	var rpcResponse RPCResponse
-	err = json.Unmarshal(body, &rpcResponse)
-	if err != nil {
 		if debug {
-			fmt.Printf("Error unmarshaling response: %v\n", err)
+			fmt.Fprintf(promptOutput, "Debug - HTTP Status: %d\n", resp.StatusCode)
+			fmt.Fprintf(promptOutput, "Debug - Raw HTTP Response: %s\n", string(body))
+		}
+		if err := unusableStatus(resp.StatusCode, body); err != nil {
+			return nil, err
 		}
-		return fmt.Errorf("failed to unmarshal JSON-RPC response: %w", err)
+		return body, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Check for RPC error
-	if rpcResponse.Error != nil {
+	// Parse JSON-RPC response, tolerating the shapes some gateways use
+	result, err := decodeCodeResponse(body, request.ID)
+	if err != nil {
 		if debug {
-			fmt.Printf("RPC Error: %v\n", rpcResponse.Error)
+			fmt.Fprintf(promptOutput, "Error decoding response: %v\n", err)
 		}
-		return fmt.Errorf("JSON-RPC error: %v", rpcResponse.Error)
+		return nil, err
 	}
 
-	// Store the result
-	result := rpcResponse.Result
-
 	if debug {
-		fmt.Printf("Debug - RPC Result: %s\n", result)
-		fmt.Println("========== DEBUG INFO END ==========")
+		fmt.Fprintf(promptOutput, "Debug - RPC Result: %s\n", result)
+		fmt.Fprintln(promptOutput, "========== DEBUG INFO END ==========")
 	}
 
-	// If no code is found or only "0x", the address is safe (not a contract)
-	if result == "" || result == "0x" {
-		if debug {
-			fmt.Printf("Debug - No code found, considering address safe\n")
-		}
-		color.Green("✓ Address %s is safe (no code detected)", address)
-		return nil
+	code, err := hex.DecodeString(strings.TrimPrefix(result, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid code returned by the node: %w", err)
 	}
 
-	// Remove "0x" prefix if present for processing
-	codeWithoutPrefix := result
-	if strings.HasPrefix(result, "0x") {
-		codeWithoutPrefix = result[2:]
+	return ParseDelegation(checksumAddr, code), nil
+}
+
+// rpcEnvelope is a JSON-RPC response with its members left undecoded
+type rpcEnvelope struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  json.RawMessage `json:"error"`
+}
+
+// decodeCodeResponse extracts the hex code from a response to the code request
+// with the given id. Besides the standard shape it accepts a batch array
+// (the entry with the matching id is used) and a result wrapped in an object
+// under "code", "result" or "data", as returned by some gateways.
+func decodeCodeResponse(body []byte, id int) (string, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var batch []rpcEnvelope
+		if err := json.Unmarshal(trimmed, &batch); err != nil {
+			return "", unexpectedResponse(body, err)
+		}
+		for _, entry := range batch {
+			if matchesID(entry.ID, id) || len(batch) == 1 {
+				return decodeCodeEnvelope(entry, body)
+			}
+		}
+		return "", unexpectedResponse(body, fmt.Errorf("no entry with id %d in the batch response", id))
 	}
 
-	// Convert to lowercase for matching
-	codeHexLower := strings.ToLower(codeWithoutPrefix)
+	var envelope rpcEnvelope
+	if err := json.Unmarshal(trimmed, &envelope); err != nil {
+		return "", unexpectedResponse(body, err)
+	}
+	return decodeCodeEnvelope(envelope, body)
+}
 
-	if debug {
-		fmt.Printf("Debug - Code after 0x removal: %s\n", codeWithoutPrefix)
-		fmt.Printf("Debug - Code lowercase: %s\n", codeHexLower)
-		fmt.Printf("Debug - Checking if starts with ef0100: %v\n", strings.HasPrefix(codeHexLower, "ef0100"))
+// decodeCodeEnvelope extracts the code from a single response
+func decodeCodeEnvelope(envelope rpcEnvelope, body []byte) (string, error) {
+	if len(envelope.Error) > 0 && string(envelope.Error) != "null" {
+		var rpcErr interface{}
+		json.Unmarshal(envelope.Error, &rpcErr)
+		return "", fmt.Errorf("JSON-RPC error: %v", rpcErr)
 	}
+	if code, ok := codeFromResult(envelope.Result); ok {
+		return code, nil
+	}
+	return "", unexpectedResponse(body, errors.New("no hex code found in the result"))
+}
 
-	// Check if the code starts with ef0100
-	if strings.HasPrefix(codeHexLower, "ef0100") {
-		// Extract the contract address (remove ef0100 prefix and add 0x)
-		contractAddr := "0x" + codeWithoutPrefix[6:]
-		if debug {
-			fmt.Printf("Debug - Extracted contract address: %s\n", contractAddr)
+// codeFromResult reads a hex string result, possibly wrapped in an object
+func codeFromResult(raw json.RawMessage) (string, bool) {
+	var code string
+	if err := json.Unmarshal(raw, &code); err == nil {
+		return code, strings.HasPrefix(code, "0x")
+	}
+	var wrapped map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &wrapped); err != nil {
+		return "", false
+	}
+	for _, key := range []string{"code", "result", "data"} {
+		if inner, ok := wrapped[key]; ok {
+			return codeFromResult(inner)
 		}
-		color.Red("⚠ Address %s has an EIP-7702 contract deployed", address)
-		color.Red("⚠ Contract address: %s", contractAddr)
-		return nil
 	}
+	return "", false
+}
 
-	// Code exists but doesn't match EIP-7702 pattern
-	if debug {
-		fmt.Printf("Debug - Code exists but does not match EIP-7702 pattern\n")
+// matchesID reports whether a raw JSON-RPC id, a number or a string, equals id
+func matchesID(raw json.RawMessage, id int) bool {
+	return strings.Trim(string(bytes.TrimSpace(raw)), `"`) == strconv.Itoa(id)
+}
+
+// unexpectedResponse describes a response whose shape was not recognised.
+// The full body is printed by --debug.
+func unexpectedResponse(body []byte, cause error) error {
+	const maxShown = 200
+	shown := string(body)
+	if len(shown) > maxShown {
+		shown = shown[:maxShown] + "..."
 	}
-	color.Yellow("⚠ Address %s has code deployed and might be a contract", address)
-	return nil
+	return fmt.Errorf("unexpected JSON-RPC response shape (%v): %s (use --debug to see the full response)", cause, shown)
 }
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Checkpoint states of a batch item
+const (
+	checkpointBroadcast = "broadcast" // The transaction was sent, its outcome is unknown
+	checkpointDone      = "done"      // The item completed successfully
+)
+
+// checkpoint records the progress of a batch in a file, so an interrupted
+// batch can be resumed without processing an account twice. A nil checkpoint
+// records nothing.
+type checkpoint struct {
+	path  string
+	Items map[common.Address]string `json:"items"`
+}
+
+// loadCheckpoint reads the checkpoint file at path, starting an empty one when
+// it does not exist yet. An empty path disables checkpointing.
+func loadCheckpoint(path string) (*checkpoint, error) {
+	if path == "" {
+		return nil, nil
+	}
+	c := &checkpoint{path: path, Items: make(map[common.Address]string)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint file %s: %w", path, err)
+	}
+	if c.Items == nil {
+		c.Items = make(map[common.Address]string)
+	}
+	return c, nil
+}
+
+// status returns the recorded state of an account, empty if it was not processed yet
+func (c *checkpoint) status(address common.Address) string {
+	if c == nil {
+		return ""
+	}
+	return c.Items[address]
+}
+
+// record sets the state of an account and persists the checkpoint
+func (c *checkpoint) record(address common.Address, status string) error {
+	if c == nil {
+		return nil
+	}
+	c.Items[address] = status
+	return c.save()
+}
+
+// save writes the checkpoint to a temporary file next to it and renames it into
+// place, so an interruption never leaves a truncated checkpoint behind
+func (c *checkpoint) save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestLoadCheckpoint(t *testing.T) {
+	account := testKey(t, 3).Address()
+	tests := []struct {
+		name    string
+		content string // Written to the file when not empty
+		want    map[common.Address]string
+		err     bool
+	}{
+		{"missing file", "", map[common.Address]string{}, false},
+		{"recorded items", `{"items":{"` + account.Hex() + `":"done"}}`, map[common.Address]string{account: checkpointDone}, false},
+		{"null items", `{"items":null}`, map[common.Address]string{}, false},
+		{"invalid json", `{"items":`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "batch.json")
+			if tt.content != "" {
+				if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			c, err := loadCheckpoint(path)
+			if tt.err {
+				if err == nil {
+					t.Fatal("no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(c.Items, tt.want) {
+				t.Fatalf("items %v, want %v", c.Items, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckpointDisabled(t *testing.T) {
+	c, err := loadCheckpoint("")
+	if err != nil || c != nil {
+		t.Fatalf("loadCheckpoint(\"\") = %v, %v, want nil", c, err)
+	}
+	if err := c.record(testKey(t, 3).Address(), checkpointDone); err != nil {
+		t.Fatal(err)
+	}
+	if status := c.status(testKey(t, 3).Address()); status != "" {
+		t.Fatalf("status %q, want none", status)
+	}
+}
+
+func TestCheckpointRecordAndResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "batch.json")
+	c, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, sent := testKey(t, 3).Address(), testKey(t, 4).Address()
+	if err := c.record(done, checkpointDone); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.record(sent, checkpointBroadcast); err != nil {
+		t.Fatal(err)
+	}
+	if matches, _ := filepath.Glob(path + ".tmp*"); len(matches) != 0 {
+		t.Fatalf("temporary files left behind: %v", matches)
+	}
+
+	resumed, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		address common.Address
+		want    string
+	}{
+		{done, checkpointDone},
+		{sent, checkpointBroadcast},
+		{testKey(t, 5).Address(), ""},
+	}
+	for _, tt := range tests {
+		if got := resumed.status(tt.address); got != tt.want {
+			t.Errorf("status(%s) = %q, want %q", tt.address.Hex(), got, tt.want)
+		}
+	}
+}
+
+// TestClearBatchResumesFromCheckpoint checks that the accounts a previous run
+// completed or broadcast for are not cleared again
+func TestClearBatchResumesFromCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "batch.json")
+	c, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.record(testKey(t, 3).Address(), checkpointDone)
+	c.record(testKey(t, 4).Address(), checkpointBroadcast)
+
+	stub := &rpcStub{}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	savedPrompt, savedKeys, savedUsed := promptOutput, demoKeys, demoKeysUsed
+	promptOutput = io.Discard
+	demoKeys, demoKeysUsed = []*ecdsa.PrivateKey{testKey(t, 3).key, testKey(t, 4).key, testKey(t, 5).key}, 0
+	defer func() { promptOutput, demoKeys, demoKeysUsed = savedPrompt, savedKeys, savedUsed }()
+
+	t.Setenv("TEST_RELAYER_KEY", strings.Repeat("0", 63)+"2")
+	err = Clear(TxOptions{
+		RPCURL:         server.URL,
+		Batch:          true,
+		Yes:            true,
+		NoWait:         true,
+		CheckpointFile: path,
+		RelayerKeyEnv:  "TEST_RELAYER_KEY",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stub.nonces) != 1 {
+		t.Fatalf("%d transactions sent, want 1 for the account not in the checkpoint", len(stub.nonces))
+	}
+
+	resumed, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resumed.status(testKey(t, 5).Address()); got != checkpointDone {
+		t.Fatalf("status of the new item %q, want %q", got, checkpointDone)
+	}
+	if got := resumed.status(testKey(t, 4).Address()); got != checkpointBroadcast {
+		t.Fatalf("status of the item broadcast in the previous run %q, want %q", got, checkpointBroadcast)
+	}
+}
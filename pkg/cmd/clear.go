@@ -1,176 +1,271 @@
 package cmd
 
 import (
+	"crypto/ecdsa"
 	"fmt"
-	"math/big"
-	"strings"
-	"time"
 
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/fatih/color"
 )
 
 // Clear performs the clear command
-func Clear(rpcURL string, gasLimit uint64) error {
-	if rpcURL == "" {
-		rpcURL = DefaultRPCURL
+func Clear(opts TxOptions) error {
+	out := opts.console()
+
+	// Validate the sweep destination before asking for any keys
+	var safeAddress common.Address
+	if opts.SafeAddress != "" {
+		if !common.IsHexAddress(opts.SafeAddress) {
+			return invalidInput("invalid safe address format: %s", opts.SafeAddress)
+		}
+		safeAddress = common.HexToAddress(opts.SafeAddress)
+	}
+	if opts.EstimateOnly {
+		if opts.Address == "" {
+			return invalidInput("--estimate-only requires the victim --address")
+		}
+		return EstimateRescue(opts.Address, opts)
+	}
+	if opts.VerifyOnly {
+		if opts.Address == "" {
+			return invalidInput("--verify-only requires the victim --address")
+		}
+		return Verify(opts.Address, "clean", "", CheckOptions{RPCURL: opts.RPCURL})
+	}
+	if opts.DryRunDiff {
+		if opts.Address == "" {
+			return invalidInput("--dry-run-diff requires the victim --address")
+		}
+		return ClearDiff(opts.Address, opts)
+	}
+	if opts.SimulateOverride {
+		if opts.Address == "" {
+			return invalidInput("--simulate-with-state-override requires the victim --address")
+		}
+		return SimulateRescue(opts.Address, opts)
+	}
+	if len(opts.SweepTokens) > 0 && opts.SafeAddress == "" {
+		return invalidInput("--sweep-tokens requires --safe-address")
+	}
+	if opts.Offline != nil {
+		return signOffline(clearAction, opts)
 	}
 
 	// Explain why we need two private keys
-	fmt.Println("We will need two private keys to clear the EIP-7702 authorization:")
-	fmt.Println("")
-	fmt.Println("1. The private key of the victim address that has been maliciously authorized.")
-	fmt.Println("   This is required to sign the deauthorization transaction.")
-	fmt.Println("")
-	fmt.Println("2. The private key of a separate, secure address to pay for gas fees.")
-	fmt.Println("   This is necessary because the victim address may not have funds to pay for")
-	fmt.Println("   gas, or any funds sent to it might be immediately stolen by the attacker.")
-	fmt.Println("")
-	fmt.Println("The second address will only be used to broadcast the transaction and pay for gas.")
-	fmt.Println("It should be a secure address with a small amount of ETH for transaction fees.")
-	fmt.Println("")
-
-	// Get victim private key
-	color.Red("Please enter the private key of the address with malicious contract authorization:")
-	victimPrivateKeyHex, err := readPrivateKey()
-	if err != nil {
-		return fmt.Errorf("error reading victim private key: %w", err)
+	if opts.SelfSponsor {
+		out.info("Self-sponsored mode (--relayer-same-as-user): the victim key signs the deauthorization")
+		out.info("and pays for gas itself. Only use this when the account is not being actively drained:")
+		out.info("funds sent to it to pay for gas may be stolen before the clear is mined.")
+		out.info("")
+	} else if opts.AuthFile != "" {
+		out.infof("The deauthorization is read already signed from %s, so only the private key\n", authFileLabel(opts.AuthFile))
+		out.info("of a separate, secure address to pay for gas fees is needed.")
+		out.info("")
+	} else {
+		out.info("We will need two private keys to clear the EIP-7702 authorization:")
+		out.info("")
+		out.info("1. The private key of the victim address that has been maliciously authorized.")
+		out.info("   This is required to sign the deauthorization transaction.")
+		out.info("")
+		out.info("2. The private key of a separate, secure address to pay for gas fees.")
+		out.info("   This is necessary because the victim address may not have funds to pay for")
+		out.info("   gas, or any funds sent to it might be immediately stolen by the attacker.")
+		out.info("")
+		out.info("The second address will only be used to broadcast the transaction and pay for gas.")
+		out.info("It should be a secure address with a small amount of ETH for transaction fees.")
+		out.info("")
 	}
 
-	victimPrivateKey, err := crypto.HexToECDSA(strings.TrimPrefix(victimPrivateKeyHex, "0x"))
-	if err != nil {
-		return fmt.Errorf("invalid victim private key: %w", err)
+	if err := opts.validateBundleOut(); err != nil {
+		return err
 	}
-
-	// Get relayer private key
-	fmt.Println("\nPlease enter the private key of the address that will pay for gas fees:")
-	relayerPrivateKeyHex, err := readPrivateKey()
-	if err != nil {
-		return fmt.Errorf("error reading relayer private key: %w", err)
+	if err := opts.validateTxOut(); err != nil {
+		return err
 	}
-
-	relayerPrivateKey, err := crypto.HexToECDSA(strings.TrimPrefix(relayerPrivateKeyHex, "0x"))
-	if err != nil {
-		return fmt.Errorf("invalid relayer private key: %w", err)
+	if err := opts.validateBroadcastAt(); err != nil {
+		return err
 	}
-
-	// Get address from private key
-	victimAddress := crypto.PubkeyToAddress(victimPrivateKey.PublicKey)
-	relayerAddress := crypto.PubkeyToAddress(relayerPrivateKey.PublicKey)
-
-	fmt.Printf("\nVictim address: %s\n", victimAddress.Hex())
-	fmt.Printf("Relayer address: %s\n", relayerAddress.Hex())
-
-	// Get chain ID
-	chainID, err := getChainID(rpcURL)
-	if err != nil {
-		return fmt.Errorf("failed to get chain ID: %w", err)
+	if err := opts.validateNoWait(); err != nil {
+		return err
+	}
+	if err := opts.validateNonceOverrides(); err != nil {
+		return err
+	}
+	opts.warnGasLimit(1)
+	if err := opts.validateKeystores(); err != nil {
+		return err
+	}
+	if err := opts.validateExpectAddress(); err != nil {
+		return err
+	}
+	if opts.SelfSponsor && opts.Batch {
+		return invalidInput("--relayer-same-as-user cannot be combined with --batch")
+	}
+	if opts.CheckpointFile != "" && !opts.Batch {
+		return invalidInput("--checkpoint-file requires --batch")
+	}
+	if opts.OnError != "" && !opts.Batch {
+		return invalidInput("--on-error requires --batch")
+	}
+	if opts.SelfSponsor && opts.RelayerSignerURL != "" {
+		return invalidInput("--relayer-same-as-user cannot be combined with --relayer-signer-url")
+	}
+	if opts.ReportFile != "" && opts.Batch {
+		return invalidInput("--report-file cannot be combined with --batch")
+	}
+	previewAuthorization(clearAction, opts)
+	if opts.Batch {
+		return runBatch("victim address", opts, func(victimPrivateKey *ecdsa.PrivateKey, relayer *relayerSession) (func() error, error) {
+			return startClear(keyAuthorizer(victimPrivateKey), relayer, safeAddress, opts)
+		})
 	}
-	fmt.Printf("\nChain ID: %d\n", chainID)
 
-	// Get nonces
-	victimNonce, err := getNonce(rpcURL, victimAddress.Hex())
+	// Get victim private key, or the deauthorization it signed
+	victim, err := opts.loadAuthorizer(common.Address{}, "victim", color.FgRed, "Please enter the private key of the address with malicious contract authorization:")
 	if err != nil {
-		return fmt.Errorf("failed to get victim nonce: %w", err)
+		return err
+	}
+	defer zeroKey(victim.key)
+	if err := checkExpectedAccount(clearAction, victim, opts); err != nil {
+		return err
 	}
 
-	relayerNonce, err := getNonce(rpcURL, relayerAddress.Hex())
+	// Get relayer private key
+	relayer, release, err := readRelayer(victim.key, "\nPlease enter the private key of the address that will pay for gas fees:", opts)
 	if err != nil {
-		return fmt.Errorf("failed to get relayer nonce: %w", err)
+		return err
 	}
+	defer release()
 
-	fmt.Printf("Victim nonce: %d\n", victimNonce)
-	fmt.Printf("Relayer nonce: %d\n", relayerNonce)
+	return clearAccount(victim, relayer, safeAddress, opts)
+}
 
-	// Get gas parameters using EIP-1559 compatible method
-	fmt.Println("\nFetching gas parameters from the network...")
-	gasTip, gasFeeCap, err := getSuggestedGasFees(rpcURL)
+// clearAccount clears the delegation of one victim and runs the optional fund sweep
+func clearAccount(victim authorizer, relayer *relayerSession, safeAddress common.Address, opts TxOptions) error {
+	finish, err := startClear(victim, relayer, safeAddress, opts)
 	if err != nil {
-		return fmt.Errorf("failed to get suggested gas fees: %w", err)
+		return err
 	}
+	return finish()
+}
 
-	// Use the provided gas limit
-	fmt.Printf("Using gas limit: %d\n", gasLimit)
-
-	// Convert Wei to Gwei for display (1 Gwei = 10^9 Wei)
-	weiToGwei := new(big.Float).SetFloat64(1000000000)
-
-	gasTipGwei := new(big.Float).SetInt(gasTip)
-	gasTipGwei.Quo(gasTipGwei, weiToGwei)
-
-	gasFeeCapGwei := new(big.Float).SetInt(gasFeeCap)
-	gasFeeCapGwei.Quo(gasFeeCapGwei, weiToGwei)
-
-	// Calculate total max gas cost in ETH
-	totalGasWei := new(big.Float).SetInt(gasFeeCap)
-	totalGasWei.Mul(totalGasWei, new(big.Float).SetUint64(gasLimit))
-
-	// 1 ETH = 10^18 Wei
-	weiToEth := new(big.Float).SetFloat64(1000000000000000000)
-	totalGasEth := new(big.Float).Set(totalGasWei)
-	totalGasEth.Quo(totalGasEth, weiToEth)
+// clearAction describes the clear flow to broadcastAuthorization and awaitAuthorization
+var clearAction = authAction{
+	Template:   common.Address{}, // Empty address to clear authorization
+	UserLabel:  "Victim",
+	Confirm:    "Are you sure you want to clear the EIP-7702 authorization for this address?",
+	Generating: "Generating EIP-7702 deauthorization transaction...",
+	Done:       "cleared",
+}
 
-	fmt.Printf("\nGas Information:\n")
-	fmt.Printf("Max fee per gas: %.6f Gwei\n", gasFeeCapGwei)
-	fmt.Printf("Priority fee: %.6f Gwei\n", gasTipGwei)
-	fmt.Printf("Gas limit: %d\n", gasLimit)
-	fmt.Printf("Estimated max gas cost: %.9f ETH\n", totalGasEth)
+// startClear broadcasts the clear transaction of one victim and returns the
+// step that waits for it and then runs the optional fund sweep, which needs the
+// victim key
+func startClear(victim authorizer, relayer *relayerSession, safeAddress common.Address, opts TxOptions) (func() error, error) {
+	victimAddress := victim.address()
 
-	// Confirm with user
-	fmt.Println("\nAre you sure you want to clear the EIP-7702 authorization for this address? (y/n)")
-	var confirmation string
-	fmt.Scanln(&confirmation)
-	if strings.ToLower(confirmation) != "y" && strings.ToLower(confirmation) != "yes" {
-		return fmt.Errorf("operation cancelled by user")
+	fmt.Fprintf(promptOutput, "\nVictim address: %s\n", victimAddress.Hex())
+	fmt.Fprintf(promptOutput, "Relayer address: %s\n", relayer.Address().Hex())
+	if opts.SafeAddress != "" {
+		if safeAddress == victimAddress {
+			return nil, invalidInput("the safe address must differ from the victim address")
+		}
+		fmt.Fprintf(promptOutput, "Safe address (sweep destination): %s\n", safeAddress.Hex())
 	}
-
-	// Create EIP-7702 authorization request
-	req := SetAuthorizationRequest{
-		UserEOAPrivateKey:    victimPrivateKey,
-		UserEOANonce:         uint64(victimNonce),
-		RelayerEOAPrivateKey: relayerPrivateKey,
-		RelayerNonce:         uint64(relayerNonce),
-		TemplateAddress:      common.Address{}, // Empty address to clear authorization
-		ChainId:              chainID,
-		GasTip:               gasTip,
-		GasFeeCap:            gasFeeCap,
-		GasLimit:             gasLimit,
+	if err := checkRecoverable(victimAddress, opts); err != nil {
+		return nil, err
+	}
+	// In "ensure clean" loops, a clear of a clean account would only spend the
+	// relayer's gas and move the victim's nonce, invalidating pre-signed authorizations
+	if opts.AssumeYesForClean && hasNoDelegation(opts.rpcURLOrDefault(), victimAddress) {
+		notice(color.FgGreen, "✓ %s has no delegation, already clean: nothing was signed or broadcast (--assume-yes-for-clean)", victimAddress.Hex())
+		return func() error { return nil }, nil
+	}
+	var delegationBefore string
+	if opts.ReportFile != "" {
+		delegationBefore = delegationLabel(CheckAddress(victimAddress.Hex(), CheckOptions{RPCURL: opts.RPCURL}))
 	}
 
-	fmt.Println("\nGenerating EIP-7702 deauthorization transaction...")
-	signedTx, err := GenerateSet7702AuthTx(req)
+	result, err := broadcastAuthorization(clearAction, victim, relayer, opts)
 	if err != nil {
-		return fmt.Errorf("failed to generate transaction: %w", err)
+		return nil, err
 	}
 
-	fmt.Println("Broadcasting transaction...")
-	txHash, err := broadcastRawTx(signedTx, rpcURL)
-	if err != nil {
-		return fmt.Errorf("failed to broadcast transaction: %w", err)
-	}
-
-	color.Green("\nTransaction successfully sent!")
-	color.Green("Transaction hash: %s", txHash)
-
-	fmt.Println("\nWaiting for transaction to be mined...")
-	// Wait for the transaction to be mined
-	for i := 0; i < 60; i++ { // Try for 5 minutes (60 * 5 seconds)
-		time.Sleep(5 * time.Second)
-		receipt, err := getTransactionReceipt(rpcURL, txHash)
-		if err == nil && receipt != nil {
-			if receipt.Status == "0x1" {
-				color.Green("\nTransaction successfully mined!")
-				break
-			} else if receipt.Status == "0x0" {
-				return fmt.Errorf("transaction failed: %s", txHash)
+	return func() error {
+		err := awaitAuthorization(clearAction, result, opts)
+		waitErr := err
+		if err == nil {
+			err = sweepFunds(victim.key, safeAddress, result, opts)
+		}
+		// A clear that is mined but undone right away is the typical sign of a race
+		redelegations := reportFrontRun(result, opts)
+
+		if opts.ReportFile != "" && !result.Proposed {
+			if reportErr := writeRescueReport(opts.ReportFile, newRescueReport(delegationBefore, result, waitErr, redelegations)); reportErr != nil && err == nil {
+				err = reportErr
 			}
 		}
-		fmt.Print(".")
+		return err
+	}, nil
+}
+
+// ClearDiff shows, without keys or any transaction, the state of an account
+// before and after a clear as a diff: its code and delegation target, which
+// the clear empties, and its nonce, which the applied authorization increments.
+func ClearDiff(address string, opts TxOptions) error {
+	if !common.IsHexAddress(address) {
+		return invalidInput("invalid Ethereum address format: %s", address)
+	}
+	rpcURL := opts.rpcURLOrDefault()
+	before, err := CheckAddress(address, CheckOptions{RPCURL: rpcURL})
+	if err != nil {
+		return fmt.Errorf("failed to read the account code: %w", err)
+	}
+	nonce, err := getNonce(rpcURL, before.Address.Hex())
+	if err != nil {
+		return fmt.Errorf("failed to get nonce: %w", err)
 	}
 
-	fmt.Println("\nTo verify the EIP-7702 authorization has been cleared, run:")
-	fmt.Printf("eip7702cleaner check %s --rpc-url %s\n", victimAddress.Hex(), rpcURL)
+	fmt.Printf("Account %s\n", before.Address.Hex())
+	switch before.Status {
+	case StatusClean:
+		color.Green("No delegation to clear: the code stays empty")
+	case StatusHasCode:
+		color.Yellow("The account has contract code that is not an EIP-7702 delegation: nodes skip authorizations")
+		color.Yellow("from such accounts, so a clear would leave it unchanged")
+		return nil
+	default:
+		color.Red("- code:       0x%x", before.Code)
+		color.Green("+ code:       (empty)")
+		color.Red("- delegation: %s", before.Delegate.Hex())
+		color.Green("+ delegation: none")
+	}
 
+	// The authorization increments the nonce, and so does the transaction when the account sends it itself
+	after := uint64(nonce) + 1
+	if opts.SelfSponsor {
+		after++
+	}
+	color.Red("- nonce:      %d", nonce)
+	color.Green("+ nonce:      %d", after)
 	return nil
 }
+
+// sweepFunds runs the optional rescue step, only once the clear is confirmed on chain
+func sweepFunds(victimPrivateKey *ecdsa.PrivateKey, safeAddress common.Address, result *authResult, opts TxOptions) error {
+	if opts.SafeAddress == "" {
+		return nil
+	}
+	if result.Receipt == nil {
+		notice(color.FgYellow, "\nSkipping the fund sweep: the clear transaction has not been mined yet.")
+		return nil
+	}
+	// Tokens first: the ETH sweep empties the balance that pays for their transfers
+	if len(opts.SweepTokens) > 0 {
+		if err := sweepTokens(victimPrivateKey, safeAddress, opts.SweepTokens, result, opts); err != nil {
+			notice(color.FgYellow, "\nSkipping the ETH sweep: its balance is still needed to retry the token transfers.")
+			return err
+		}
+	}
+	return sweepETH(victimPrivateKey, safeAddress, result, opts)
+}
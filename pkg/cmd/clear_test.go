@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// rpcStub answers the JSON-RPC calls of a clear on Sepolia for an account
+// delegated to 0x…1111, or without code when clean is set, and keeps the hash
+// of the transaction it is sent. The rejectSend-th transaction sent, counting
+// from 1, is refused; the nonce of every transaction sent is recorded, and so
+// is the number of gas estimates.
+type rpcStub struct {
+	clean      bool
+	rejectSend int
+	mu         sync.Mutex
+	sentTx     string
+	nonces     []uint64
+	estimates  int
+}
+
+func (s *rpcStub) result(method string, params []json.RawMessage) interface{} {
+	switch method {
+	case "eth_chainId":
+		return "0xaa36a7"
+	case "eth_getTransactionCount":
+		return "0x9"
+	case "eth_getBalance":
+		return "0xde0b6b3a7640000"
+	case "eth_getCode":
+		if s.clean {
+			return "0x"
+		}
+		return "0xef0100" + strings.Repeat("11", 20)
+	case "eth_maxPriorityFeePerGas":
+		return "0x3b9aca00"
+	case "eth_gasPrice":
+		return "0x77359400"
+	case "eth_blockNumber":
+		return "0x10"
+	case "eth_estimateGas":
+		s.mu.Lock()
+		s.estimates++
+		s.mu.Unlock()
+		return "0x5208"
+	case "eth_call":
+		return "0x"
+	case "web3_clientVersion":
+		return "Geth/v1.15.11"
+	case "eth_getBlockByNumber":
+		return map[string]string{"baseFeePerGas": "0x3b9aca00", "number": "0x10", "timestamp": "0x1", "hash": "0x" + strings.Repeat("00", 32)}
+	case "eth_feeHistory":
+		return map[string]interface{}{
+			"oldestBlock":   "0x1",
+			"baseFeePerGas": []string{"0x3b9aca00", "0x3b9aca00", "0x3b9aca00", "0x3b9aca00", "0x3b9aca00"},
+			"gasUsedRatio":  []float64{0.5, 0.5, 0.5, 0.5},
+			"reward":        [][]string{{"0x3b9aca00"}, {"0x3b9aca00"}, {"0x3b9aca00"}, {"0x3b9aca00"}},
+		}
+	case "eth_sendRawTransaction":
+		var raw string
+		json.Unmarshal(params[0], &raw)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if tx, err := DecodeSetCodeTx(raw); err == nil {
+			s.nonces = append(s.nonces, tx.Nonce)
+		}
+		if len(s.nonces) == s.rejectSend {
+			return errors.New("nonce too low")
+		}
+		hash := crypto.Keccak256Hash(hexutil.MustDecode(raw)).Hex()
+		s.sentTx = hash
+		return hash
+	}
+	return nil
+}
+
+func (s *rpcStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	type request struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	type rpcError struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	type response struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Result  interface{}     `json:"result"`
+		Error   *rpcError       `json:"error,omitempty"`
+	}
+	body, _ := io.ReadAll(r.Body)
+	answer := func(req request) response {
+		result := s.result(req.Method, req.Params)
+		if err, ok := result.(error); ok {
+			return response{JSONRPC: "2.0", ID: req.ID, Error: &rpcError{Code: -32000, Message: err.Error()}}
+		}
+		return response{JSONRPC: "2.0", ID: req.ID, Result: result}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		var batch []request
+		json.Unmarshal(body, &batch)
+		out := make([]response, len(batch))
+		for i, req := range batch {
+			out[i] = answer(req)
+		}
+		json.NewEncoder(w).Encode(out)
+		return
+	}
+	var req request
+	json.Unmarshal(body, &req)
+	json.NewEncoder(w).Encode(answer(req))
+}
+
+// TestClearQuietStdout runs a whole clear in quiet mode and checks that stdout
+// carries the transaction hash alone, everything else going to stderr
+func TestClearQuietStdout(t *testing.T) {
+	stub := &rpcStub{}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	savedResult, savedPrompt := resultOutput, promptOutput
+	resultOutput, promptOutput = &stdout, &stderr
+	defer func() { resultOutput, promptOutput = savedResult, savedPrompt }()
+
+	t.Setenv("TEST_VICTIM_KEY", strings.Repeat("0", 63)+"1")
+	t.Setenv("TEST_RELAYER_KEY", strings.Repeat("0", 63)+"2")
+	err := Clear(TxOptions{
+		RPCURL:        server.URL,
+		Quiet:         true,
+		Yes:           true,
+		NoWait:        true,
+		KeyEnv:        "TEST_VICTIM_KEY",
+		RelayerKeyEnv: "TEST_RELAYER_KEY",
+	})
+	if err != nil {
+		t.Fatalf("Clear: %v\nstderr:\n%s", err, stderr.String())
+	}
+	if stub.sentTx == "" {
+		t.Fatalf("no transaction was broadcast\nstderr:\n%s", stderr.String())
+	}
+	if got, want := stdout.String(), stub.sentTx+"\n"; got != want {
+		t.Errorf("stdout = %q, want %q", got, want)
+	}
+	if stderr.Len() == 0 {
+		t.Error("stderr is empty, the gas summary and result label should go there")
+	}
+}
+
+// TestClearAssumeYesForCleanAlreadyClean checks that an account without
+// delegation is reported as clean without anything being broadcast
+func TestClearAssumeYesForCleanAlreadyClean(t *testing.T) {
+	stub := &rpcStub{clean: true}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	var stderr bytes.Buffer
+	savedPrompt := promptOutput
+	promptOutput = &stderr
+	defer func() { promptOutput = savedPrompt }()
+
+	t.Setenv("TEST_VICTIM_KEY", strings.Repeat("0", 63)+"1")
+	t.Setenv("TEST_RELAYER_KEY", strings.Repeat("0", 63)+"2")
+	err := Clear(TxOptions{
+		RPCURL:            server.URL,
+		AssumeYesForClean: true,
+		Yes:               true,
+		KeyEnv:            "TEST_VICTIM_KEY",
+		RelayerKeyEnv:     "TEST_RELAYER_KEY",
+	})
+	if err != nil {
+		t.Fatalf("Clear: %v\nstderr:\n%s", err, stderr.String())
+	}
+	if stub.sentTx != "" {
+		t.Fatalf("a no-op clear was broadcast: %s", stub.sentTx)
+	}
+}
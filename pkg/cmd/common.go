@@ -2,18 +2,21 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"crypto/ecdsa"
+	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"math/big"
 	"net/http"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/rlp"
 	"golang.org/x/term"
@@ -37,6 +40,37 @@ type TransactionReceipt struct {
 	Status            string `json:"status"`
 	GasUsed           string `json:"gasUsed"`
 	CumulativeGasUsed string `json:"cumulativeGasUsed"`
+	EffectiveGasPrice string `json:"effectiveGasPrice"`
+}
+
+// cost returns the gas paid for the transaction in Wei, nil when the receipt lacks the fields
+func (r *TransactionReceipt) cost() *big.Int {
+	gasUsed, okUsed := new(big.Int).SetString(strings.TrimPrefix(r.GasUsed, "0x"), 16)
+	price, okPrice := new(big.Int).SetString(strings.TrimPrefix(r.EffectiveGasPrice, "0x"), 16)
+	if !okUsed || !okPrice {
+		return nil
+	}
+	return gasUsed.Mul(gasUsed, price)
+}
+
+// Transaction represents the fields of an eth_getTransactionByHash result used by this tool
+type Transaction struct {
+	Hash              string               `json:"hash"`
+	Type              string               `json:"type"`
+	From              string               `json:"from"`
+	Nonce             string               `json:"nonce"`
+	BlockNumber       *string              `json:"blockNumber"`       // nil while the transaction is pending
+	AuthorizationList []AuthorizationTuple `json:"authorizationList"` // Only in type 0x04 transactions
+
+	// Further fields of EIP-1559 and later transactions, to rebuild one with other fees
+	ChainID              *hexutil.Big    `json:"chainId"`
+	To                   *common.Address `json:"to"`
+	Value                *hexutil.Big    `json:"value"`
+	Input                hexutil.Bytes   `json:"input"`
+	Gas                  hexutil.Uint64  `json:"gas"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas"`
+	AccessList           []AccessTuple   `json:"accessList"`
 }
 
 // CallTuple defines the parameters for each batched asset collection call.
@@ -48,28 +82,209 @@ type CallTuple struct {
 
 // SetAuthorizationRequest holds the request parameters for EIP-7702 authorization.
 type SetAuthorizationRequest struct {
-	UserEOAPrivateKey    *ecdsa.PrivateKey
+	UserEOAPrivateKey *ecdsa.PrivateKey
+
+	// Authorization is an optional tuple signed beforehand, carried as is
+	// instead of one signed with UserEOAPrivateKey. It must be for ChainId
+	// or chain id 0, TemplateAddress and UserEOANonce.
+	Authorization *AuthorizationTuple
+
 	UserEOANonce         uint64
 	RelayerEOAPrivateKey *ecdsa.PrivateKey
+	RelayerSigner        Signer // Optional, signs the transaction instead of RelayerEOAPrivateKey
 	RelayerNonce         uint64
 	TemplateAddress      common.Address
 	ChainId              *big.Int
-	GasTip               *big.Int // Optional, will use suggestion if nil
-	GasFeeCap            *big.Int // Optional, will use suggestion if nil
-	GasLimit             uint64   // Optional, will use suggestion if 0
+
+	// AuthChainId is the optional chain id of the authorization tuple,
+	// ChainId when nil. It must equal ChainId, or be 0 for a tuple valid on
+	// every chain; any other value fails with ErrChainIDMismatch before
+	// anything is signed.
+	AuthChainId *big.Int
+
+	GasTip    *big.Int // Optional, will use suggestion if nil
+	GasFeeCap *big.Int // Optional, will use suggestion if nil
+
+	// GasLimit is optional, AuthorizationGasLimit for every authorization if
+	// 0, or the transaction's IntrinsicGas when Data makes that higher. A
+	// limit below the IntrinsicGas fails with ErrGasLimitTooLow.
+	GasLimit uint64
+
+	// Data and Value are optional. With either set, the transaction calls the
+	// first authorized account instead of TemplateAddress, so an initializer
+	// runs with the new code in the same transaction as the delegation. Value
+	// is paid by the relayer, and the default GasLimit does not cover the call.
+	Data  []byte
+	Value *big.Int
+
+	// MoreAuthorizations are further accounts authorized to TemplateAddress in
+	// the same transaction, after the one of UserEOAPrivateKey or Authorization,
+	// so one relayer transaction sets or clears several accounts at once. Each
+	// account may only appear once in the transaction.
+	MoreAuthorizations []AccountAuthorization
+}
+
+// AccountAuthorization is a further account of a SetAuthorizationRequest: its
+// key and nonce, or an authorization it signed beforehand
+type AccountAuthorization struct {
+	PrivateKey    *ecdsa.PrivateKey
+	Authorization *AuthorizationTuple // Optional, signed beforehand, used instead of signing one with PrivateKey
+	Nonce         uint64
 }
 
-// readPrivateKey reads a private key from stdin without echoing the input
-func readPrivateKey() (string, error) {
-	privateKeyBytes, err := term.ReadPassword(int(syscall.Stdin))
+// errEmptyKey is returned by readPrivateKey when nothing was entered
+var errEmptyKey = errors.New("private key cannot be empty")
+
+// readPrivateKey reads a hex private key from stdin without echoing the input.
+//
+// The raw input and the decoded key bytes are overwritten as soon as the key
+// has been parsed. This is best effort only: Go's garbage collector may have
+// copied the buffers, and the parsed key itself must be wiped with zeroKey once
+// it is no longer needed.
+func readPrivateKey() (*ecdsa.PrivateKey, error) {
+	if len(demoKeys) > 0 {
+		return nextDemoKey()
+	}
+	input, err := readInput(readSecret)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	defer zeroBytes(input)
+	return parseHexKey(input)
+}
+
+// parseHexKey parses a hex private key with an optional 0x prefix, surrounding
+// whitespace ignored. The decoded bytes are wiped, input is left to the caller.
+func parseHexKey(input []byte) (*ecdsa.PrivateKey, error) {
+	keyHex := bytes.TrimPrefix(bytes.TrimSpace(input), []byte("0x"))
+	if len(keyHex) == 0 {
+		return nil, errEmptyKey
 	}
-	privateKey := strings.TrimSpace(string(privateKeyBytes))
-	if privateKey == "" {
-		return "", errors.New("private key cannot be empty")
+	if err := checkKeyShape(keyHex); err != nil {
+		return nil, err
 	}
-	return privateKey, nil
+
+	keyBytes := make([]byte, hex.DecodedLen(len(keyHex)))
+	defer zeroBytes(keyBytes)
+	if _, err := hex.Decode(keyBytes, keyHex); err != nil {
+		return nil, errors.New("invalid private key: not a hex string")
+	}
+
+	privateKey, err := crypto.ToECDSA(keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	return lockKey(privateKey), nil
+}
+
+// checkKeyShape recognises inputs that are obviously not a private key, such as
+// an address or a public key pasted by mistake, so the error can say so
+func checkKeyShape(keyHex []byte) error {
+	switch {
+	case len(keyHex) == 2*common.AddressLength:
+		return errors.New("invalid private key: this looks like an address, not a private key")
+	case len(keyHex) == 128, len(keyHex) == 130 && bytes.HasPrefix(keyHex, []byte("04")):
+		return errors.New("invalid private key: this looks like an uncompressed public key, not a private key")
+	case len(keyHex) == 66 && (bytes.HasPrefix(keyHex, []byte("02")) || bytes.HasPrefix(keyHex, []byte("03"))):
+		return errors.New("invalid private key: this looks like a compressed public key, not a private key")
+	case len(keyHex) != 64:
+		return fmt.Errorf("invalid private key: expected 64 hex characters, got %d", len(keyHex))
+	}
+	return nil
+}
+
+// zeroBytes overwrites a buffer that held key material
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// zeroKey overwrites the secret scalar of a private key once it is no longer
+// needed, and releases its locked memory when lockKey moved it there. Copies
+// made internally while signing cannot be reached and are left to the GC.
+func zeroKey(key *ecdsa.PrivateKey) {
+	if key == nil || key.D == nil {
+		return
+	}
+	words := key.D.Bits()
+	for i := range words {
+		words[i] = 0
+	}
+	key.D.SetInt64(0)
+	releaseLockedKey(key)
+}
+
+// PromptTimeout aborts an interactive prompt that receives no input within
+// this duration, 0 (the default) waits forever
+var PromptTimeout time.Duration
+
+// errPromptTimeout is returned when a prompt receives no input within PromptTimeout
+var errPromptTimeout = &TypedError{Type: ErrorTypeTimeout, Err: errors.New("no input received within --prompt-timeout, aborting")}
+
+// readInput runs a blocking read of user input, giving up after PromptTimeout.
+// A read that times out is left blocked on stdin, so the caller must abort the
+// operation rather than prompt again.
+func readInput(read func() ([]byte, error)) ([]byte, error) {
+	if PromptTimeout <= 0 {
+		return read()
+	}
+
+	type result struct {
+		input []byte
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		input, err := read()
+		done <- result{input, err}
+	}()
+
+	timer := time.NewTimer(PromptTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.input, r.err
+	case <-timer.C:
+		return nil, errPromptTimeout
+	}
+}
+
+// readSecret reads a line from the terminal without echoing it
+func readSecret() ([]byte, error) {
+	return term.ReadPassword(int(syscall.Stdin))
+}
+
+// readLine reads a single line of user input from stdin
+func readLine() (string, error) {
+	input, err := readInput(func() ([]byte, error) {
+		var input string
+		fmt.Scanln(&input)
+		return []byte(input), nil
+	})
+	return strings.TrimSpace(string(input)), err
+}
+
+// askConfirmation reads a y/n answer and reports whether the user agreed
+func askConfirmation() (bool, error) {
+	answer, err := readLine()
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes", nil
+}
+
+// confirmOrCancel reads a y/n answer and returns ErrCancelled unless the user agreed
+func confirmOrCancel() error {
+	agreed, err := askConfirmation()
+	if err != nil {
+		return err
+	}
+	if !agreed {
+		return ErrCancelled
+	}
+	return nil
 }
 
 // getChainID gets the chain ID from the RPC endpoint
@@ -97,16 +312,25 @@ func getChainID(rpcURL string) (*big.Int, error) {
 	chainID := new(big.Int)
 	chainID.SetString(result.Result[2:], 16) // Remove "0x" prefix and parse as hex
 
+	traceChainID(chainID)
+	if err := checkDemoChain(chainID); err != nil {
+		return nil, err
+	}
 	return chainID, nil
 }
 
 // getNonce gets the nonce for an address
 func getNonce(rpcURL, address string) (int64, error) {
+	return getNonceAt(rpcURL, address, "latest")
+}
+
+// getNonceAt gets the transaction count of an address at a block tag such as "pending"
+func getNonceAt(rpcURL, address, block string) (int64, error) {
 	body := map[string]interface{}{
 		"jsonrpc": "2.0",
 		"id":      1,
 		"method":  "eth_getTransactionCount",
-		"params":  []interface{}{address, "latest"},
+		"params":  []interface{}{address, block},
 	}
 
 	responseBody, err := makeRPCCall(rpcURL, body)
@@ -157,7 +381,7 @@ func getGasPrice(rpcURL string) (*big.Int, error) {
 }
 
 // getTransactionReceipt gets the receipt for a transaction
-func getTransactionReceipt(rpcURL, txHash string) (*TransactionReceipt, error) {
+func getTransactionReceipt(ctx context.Context, rpcURL, txHash string) (*TransactionReceipt, error) {
 	body := map[string]interface{}{
 		"jsonrpc": "2.0",
 		"id":      1,
@@ -165,7 +389,7 @@ func getTransactionReceipt(rpcURL, txHash string) (*TransactionReceipt, error) {
 		"params":  []interface{}{txHash},
 	}
 
-	responseBody, err := makeRPCCall(rpcURL, body)
+	responseBody, err := makeRPCCallContext(ctx, rpcURL, body)
 	if err != nil {
 		return nil, err
 	}
@@ -181,25 +405,100 @@ func getTransactionReceipt(rpcURL, txHash string) (*TransactionReceipt, error) {
 	return result.Result, nil
 }
 
-// makeRPCCall is a helper function to make RPC calls
-func makeRPCCall(rpcURL string, body map[string]interface{}) ([]byte, error) {
-	payload, err := json.Marshal(body)
+// getTransactionByHash gets a transaction by its hash, returning nil if the node doesn't know it
+func getTransactionByHash(ctx context.Context, rpcURL, txHash string) (*Transaction, error) {
+	body := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_getTransactionByHash",
+		"params":  []interface{}{txHash},
+	}
+
+	responseBody, err := makeRPCCallContext(ctx, rpcURL, body)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := http.Post(rpcURL, "application/json", bytes.NewReader(payload))
-	if err != nil {
+	var result struct {
+		Result *Transaction `json:"result"`
+	}
+
+	if err := json.Unmarshal(responseBody, &result); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	responseBody, err := io.ReadAll(resp.Body)
+	return result.Result, nil
+}
+
+// DefaultRPCTimeout bounds a single RPC call unless RPCTimeout is set
+const DefaultRPCTimeout = 15 * time.Second
+
+// RPCTimeout bounds every single RPC call, independently of any overall deadline
+var RPCTimeout time.Duration
+
+// rpcTimeout returns the per-call timeout, falling back to DefaultRPCTimeout
+func rpcTimeout() time.Duration {
+	if RPCTimeout <= 0 {
+		return DefaultRPCTimeout
+	}
+	return RPCTimeout
+}
+
+// UserAgent is sent with every RPC request, "eip7702cleaner/<version>" when empty
+var UserAgent string
+
+// userAgent returns the User-Agent header value
+func userAgent() string {
+	if UserAgent != "" {
+		return UserAgent
+	}
+	return "eip7702cleaner/" + Version
+}
+
+// newRPCRequest creates a JSON-RPC POST request carrying the User-Agent and a
+// unique X-Request-Id, so client and provider logs can be correlated
+func newRPCRequest(ctx context.Context, rpcURL string, payload []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rpcURL, bytes.NewReader(payload))
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent())
+	req.Header.Set("X-Request-Id", newRequestID())
+	return req, nil
+}
+
+// newRequestID returns a random 128-bit id in hex
+func newRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
+
+// makeRPCCall is a helper function to make RPC calls
+func makeRPCCall(rpcURL string, body map[string]interface{}) ([]byte, error) {
+	return makeRPCCallContext(context.Background(), rpcURL, body)
+}
 
-	return responseBody, nil
+// makeRPCCallContext is makeRPCCall bound to ctx, each attempt is abandoned when
+// ctx is done or after RPCTimeout, whichever comes first. Failing calls are
+// retried and failed over to RPCFallbackURLs as described by withFailover.
+func makeRPCCallContext(ctx context.Context, rpcURL string, body map[string]interface{}) ([]byte, error) {
+	return callEndpoints(ctx, rpcEndpoints(rpcURL), body)
+}
+
+// callEndpoints sends a JSON-RPC request to the first endpoint able to answer it
+func callEndpoints(ctx context.Context, endpoints []string, body map[string]interface{}) ([]byte, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	method, _ := body["method"].(string)
+	return withFailover(ctx, method, endpoints, func(ctx context.Context, endpoint string) ([]byte, error) {
+		return postRPC(ctx, endpoint, payload)
+	})
 }
 
 // getSuggestedGasFees queries the RPC for EIP-1559 gas fee suggestions.
@@ -305,33 +604,47 @@ func authTupleMessage(chainId *big.Int, addr common.Address, nonce uint64) []byt
 	return crypto.Keccak256(msg)
 }
 
+// signAuthTuple signs the authorization of the account of userPriv to delegate to contractAddr
+func signAuthTuple(chainId *big.Int, contractAddr common.Address, nonce uint64, userPriv *ecdsa.PrivateKey) (AuthorizationTuple, error) {
+	sig, err := crypto.Sign(authTupleMessage(chainId, contractAddr, nonce), userPriv)
+	if err != nil {
+		return AuthorizationTuple{}, err
+	}
+	return AuthorizationTuple{
+		ChainID: chainId,
+		Address: contractAddr,
+		Nonce:   nonce,
+		YParity: sig[64],
+		R:       new(big.Int).SetBytes(sig[:32]),
+		S:       new(big.Int).SetBytes(sig[32:64]),
+	}, nil
+}
+
+// build7702Tx encodes the unsigned type 4 transaction calling to with value
+// and txData, and the authorization list auths
 func build7702Tx(
 	chainId *big.Int,
-	userPriv *ecdsa.PrivateKey,
+	to common.Address,
+	auths []AuthorizationTuple,
 	relayerNonce uint64,
-	userNonce uint64,
 	gasTip *big.Int,
 	gasFeeCap *big.Int,
 	gasLimit uint64,
-	contractAddr common.Address,
+	value *big.Int,
 	txData []byte,
 ) (string, error) {
-
-	authMsg := authTupleMessage(chainId, contractAddr, userNonce)
-	sig, err := crypto.Sign(authMsg, userPriv)
-	if err != nil {
-		return "", err
+	if len(auths) == 0 {
+		return "", errors.New("a set code transaction needs at least one authorization")
+	}
+	authList := make([]interface{}, len(auths))
+	for i, auth := range auths {
+		authList[i] = []interface{}{auth.ChainID, auth.Address, auth.Nonce, auth.YParity, auth.R, auth.S}
 	}
-	r := new(big.Int).SetBytes(sig[:32])
-	s := new(big.Int).SetBytes(sig[32:64])
-	yParity := uint8(sig[64])
 
 	rawTx := []interface{}{
-		chainId, relayerNonce, gasTip, gasFeeCap, gasLimit, contractAddr, big.NewInt(0), txData,
+		chainId, relayerNonce, gasTip, gasFeeCap, gasLimit, to, value, txData,
 		[]interface{}{}, // access_list
-		[]interface{}{
-			[]interface{}{chainId, contractAddr, userNonce, yParity, r, s},
-		},
+		authList,
 	}
 	rlpPayload, err := rlp.EncodeToBytes(rawTx)
 	if err != nil {
@@ -341,32 +654,245 @@ func build7702Tx(
 	return hex.EncodeToString(finalTx), nil
 }
 
+// ErrGasLimitTooLow is returned when the gas limit of a request is below the
+// intrinsic gas of its transaction, which no node accepts
+var ErrGasLimitTooLow = errors.New("gas limit is below the intrinsic gas of the transaction")
+
+// ErrChainIDMismatch is returned when the authorization tuple would be signed
+// for a different chain than the transaction carrying it
+var ErrChainIDMismatch = errors.New("authorization chain id does not match transaction chain id")
+
+// authChainID returns the chain id the authorization tuple will be signed for,
+// refusing anything other than the transaction's own chain id or 0. A tuple for
+// another chain would be replayable there, while 0 is asked for explicitly to
+// be valid on every chain.
+func (req SetAuthorizationRequest) authChainID() (*big.Int, error) {
+	if req.ChainId == nil {
+		return nil, errors.New("chain id is required")
+	}
+	if req.AuthChainId == nil {
+		return req.ChainId, nil
+	}
+	if req.AuthChainId.Sign() != 0 && req.AuthChainId.Cmp(req.ChainId) != 0 {
+		return nil, fmt.Errorf("%w: authorization %s, transaction %s", ErrChainIDMismatch, req.AuthChainId, req.ChainId)
+	}
+	return req.AuthChainId, nil
+}
+
+// authorization returns the signed authorization tuple: Authorization once
+// checked to be for the request's chain, template and nonce, or else a tuple
+// signed with UserEOAPrivateKey. A pre-signed tuple may carry chain id 0, valid
+// on every chain, as the signature already exists either way.
+func (req SetAuthorizationRequest) authorization() (AuthorizationTuple, error) {
+	if req.Authorization == nil {
+		authChainId, err := req.authChainID()
+		if err != nil {
+			return AuthorizationTuple{}, err
+		}
+		if req.UserEOAPrivateKey == nil {
+			return AuthorizationTuple{}, errors.New("a user private key or signed authorization is required")
+		}
+		return signAuthTuple(authChainId, req.TemplateAddress, req.UserEOANonce, req.UserEOAPrivateKey)
+	}
+
+	auth := *req.Authorization
+	switch {
+	case req.ChainId == nil:
+		return AuthorizationTuple{}, errors.New("chain id is required")
+	case auth.ChainID == nil:
+		return AuthorizationTuple{}, errors.New("the signed authorization has no chain id")
+	case auth.ChainID.Sign() != 0 && auth.ChainID.Cmp(req.ChainId) != 0:
+		return AuthorizationTuple{}, fmt.Errorf("%w: authorization %s, transaction %s", ErrChainIDMismatch, auth.ChainID, req.ChainId)
+	case auth.Address != req.TemplateAddress:
+		return AuthorizationTuple{}, fmt.Errorf("the signed authorization delegates to %s, not to %s", auth.Address.Hex(), req.TemplateAddress.Hex())
+	case auth.Nonce != req.UserEOANonce:
+		return AuthorizationTuple{}, fmt.Errorf("the signed authorization is for nonce %d, not %d", auth.Nonce, req.UserEOANonce)
+	}
+	return auth, nil
+}
+
+// accounts returns the request for each account the transaction authorizes,
+// the first one's then one per MoreAuthorizations entry
+func (req SetAuthorizationRequest) accounts() []SetAuthorizationRequest {
+	accounts := []SetAuthorizationRequest{req}
+	for _, more := range req.MoreAuthorizations {
+		account := req
+		account.UserEOAPrivateKey = more.PrivateKey
+		account.Authorization = more.Authorization
+		account.UserEOANonce = more.Nonce
+		account.MoreAuthorizations = nil
+		accounts = append(accounts, account)
+	}
+	return accounts
+}
+
+// authorizations returns the signed authorization list of the transaction. An
+// account may only appear once: the authorizations after the first would be
+// for a nonce it no longer has, and skipped while the relayer pays for them.
+func (req SetAuthorizationRequest) authorizations() ([]AuthorizationTuple, error) {
+	var auths []AuthorizationTuple
+	seen := make(map[common.Address]int)
+	for i, account := range req.accounts() {
+		authority, err := account.authority()
+		if err != nil {
+			return nil, fmt.Errorf("authorization %d: %w", i+1, err)
+		}
+		if first, ok := seen[authority]; ok {
+			return nil, fmt.Errorf("authorizations %d and %d are both for %s", first, i+1, authority.Hex())
+		}
+		seen[authority] = i + 1
+		auth, err := account.authorization()
+		if err != nil {
+			return nil, fmt.Errorf("authorization %d: %w", i+1, err)
+		}
+		auths = append(auths, auth)
+	}
+	return auths, nil
+}
+
+// authority returns the address whose delegation the request changes, the
+// first one when there are MoreAuthorizations
+func (req SetAuthorizationRequest) authority() (common.Address, error) {
+	if req.Authorization != nil {
+		return req.Authorization.Authority()
+	}
+	if req.UserEOAPrivateKey == nil {
+		return common.Address{}, errors.New("a user private key or signed authorization is required")
+	}
+	return crypto.PubkeyToAddress(req.UserEOAPrivateKey.PublicKey), nil
+}
+
+// call returns the destination, value and calldata of the transaction: with
+// Data or Value, a call to the first authorized account, which runs with the
+// code it is delegated to by the transaction's own authorization list, and
+// otherwise an empty call to TemplateAddress
+func (req SetAuthorizationRequest) call() (common.Address, *big.Int, []byte, error) {
+	value := new(big.Int)
+	if req.Value != nil {
+		value = req.Value
+	}
+	if len(req.Data) == 0 && value.Sign() == 0 {
+		return req.TemplateAddress, value, []byte{}, nil
+	}
+	if value.Sign() < 0 {
+		return common.Address{}, nil, nil, errors.New("the call value cannot be negative")
+	}
+	to, err := req.authority()
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	data := req.Data
+	if data == nil {
+		data = []byte{}
+	}
+	return to, value, data, nil
+}
+
+// gasLimit returns the requested gas limit, or the default for the request's
+// authorizations and calldata
+func (req SetAuthorizationRequest) gasLimit(data []byte) uint64 {
+	if req.GasLimit == 0 {
+		return defaultGasLimit(1+len(req.MoreAuthorizations), data)
+	}
+	return req.GasLimit
+}
+
+// relayerSigner returns the signer of the outer transaction
+func (req SetAuthorizationRequest) relayerSigner() (Signer, error) {
+	if req.RelayerSigner != nil {
+		return req.RelayerSigner, nil
+	}
+	if req.RelayerEOAPrivateKey == nil {
+		return nil, errors.New("a relayer private key or signer is required")
+	}
+	return NewKeySigner(req.RelayerEOAPrivateKey), nil
+}
+
 // GenerateSet7702AuthTx generates an EIP-7702 authorization transaction.
-// Returns a hex string of the signed transaction ready for broadcast.
+// Returns a hex string of the signed transaction ready for broadcast. The
+// request is checked before anything is signed, following the rules given
+// on the fields of SetAuthorizationRequest.
 func GenerateSet7702AuthTx(req SetAuthorizationRequest) (string, error) {
+	auths, err := req.authorizations()
+	if err != nil {
+		return "", err
+	}
+	to, value, data, err := req.call()
+	if err != nil {
+		return "", err
+	}
+	if minimum := IntrinsicGas(len(auths), data, nil); req.gasLimit(data) < minimum {
+		return "", fmt.Errorf("%w: %d, at least %d needed", ErrGasLimitTooLow, req.gasLimit(data), minimum)
+	}
+	relayer, err := req.relayerSigner()
+	if err != nil {
+		return "", err
+	}
+
 	unsignedTxHex, err := build7702Tx(
 		req.ChainId,
-		req.UserEOAPrivateKey,
+		to,
+		auths,
 		req.RelayerNonce,
-		req.UserEOANonce,
 		req.GasTip,
 		req.GasFeeCap,
-		req.GasLimit,
-		req.TemplateAddress,
-		[]byte{},
+		req.gasLimit(data),
+		value,
+		data,
 	)
 	if err != nil {
 		return "", err
 	}
 
-	signedHex, err := signEIP7702Tx(unsignedTxHex, req.RelayerEOAPrivateKey)
+	signedHex, err := signEIP7702Tx(unsignedTxHex, relayer)
 	if err != nil {
 		return "", err
 	}
 	return signedHex, nil
 }
 
-func signEIP7702Tx(rawHex string, relayerPriv *ecdsa.PrivateKey) (string, error) {
+// BuildClearTx generates the signed EIP-7702 transaction that removes the
+// delegation of the user EOA, by authorizing the zero address. It refuses a
+// request with a non-zero TemplateAddress, which would re-delegate the account
+// instead of clearing it.
+func BuildClearTx(req SetAuthorizationRequest) (string, error) {
+	if req.TemplateAddress != (common.Address{}) {
+		return "", fmt.Errorf("clear transaction must target the zero address, got %s", req.TemplateAddress.Hex())
+	}
+	return GenerateSet7702AuthTx(req)
+}
+
+// BuildSetTx generates the signed EIP-7702 transaction that delegates the user
+// EOA to TemplateAddress. It refuses the zero address, use BuildClearTx to
+// remove a delegation.
+func BuildSetTx(req SetAuthorizationRequest) (string, error) {
+	if req.TemplateAddress == (common.Address{}) {
+		return "", errors.New("set transaction needs a non-zero template address, use BuildClearTx to clear a delegation")
+	}
+	return GenerateSet7702AuthTx(req)
+}
+
+// signTypedPayload signs an EIP-2718 signing payload, the type byte followed by
+// the RLP of the unsigned transaction, handing the payload itself to a
+// PayloadSigner, and returns the [R || S || V] signature
+func signTypedPayload(signer Signer, payload []byte) ([]byte, error) {
+	var sig []byte
+	var err error
+	if payloadSigner, ok := signer.(PayloadSigner); ok {
+		sig, err = payloadSigner.SignPayload(payload)
+	} else {
+		sig, err = signer.SignHash(crypto.Keccak256(payload))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(sig) != crypto.SignatureLength {
+		return nil, fmt.Errorf("signature has %d bytes, expected %d", len(sig), crypto.SignatureLength)
+	}
+	return sig, nil
+}
+
+func signEIP7702Tx(rawHex string, relayer Signer) (string, error) {
 	txBytes, err := hex.DecodeString(rawHex)
 	if err != nil {
 		return "", err
@@ -380,8 +906,7 @@ func signEIP7702Tx(rawHex string, relayerPriv *ecdsa.PrivateKey) (string, error)
 	if err := rlp.DecodeBytes(payload, &txRaw); err != nil {
 		return "", err
 	}
-	hash := crypto.Keccak256(append([]byte{0x04}, payload...))
-	sig, err := crypto.Sign(hash, relayerPriv)
+	sig, err := signTypedPayload(relayer, append([]byte{0x04}, payload...))
 	if err != nil {
 		return "", err
 	}
@@ -398,20 +923,46 @@ func signEIP7702Tx(rawHex string, relayerPriv *ecdsa.PrivateKey) (string, error)
 	return hex.EncodeToString(finalTx), nil
 }
 
-func broadcastRawTx(rawTxHex string, rpcUrl string) (string, error) {
+// alreadyKnownErrors are fragments of the errors nodes return for a
+// transaction that is already in their pool, e.g. when a retried submission
+// reached the node the first time and only the answer was lost
+var alreadyKnownErrors = []string{
+	"already known",      // geth
+	"known transaction",  // Older geth, Erigon
+	"already imported",   // Nethermind
+	"already exists",     // Besu
+	"already in mempool", // Others
+}
+
+// isAlreadyKnown reports whether a node error means the transaction is already in its pool
+func isAlreadyKnown(message string) bool {
+	message = strings.ToLower(message)
+	for _, fragment := range alreadyKnownErrors {
+		if strings.Contains(message, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// broadcastRawTx submits a signed transaction and returns its hash. Connection
+// failures are retried by the failover layer, within RPCRetries and
+// RPCMaxAttempts, errors reported by the node are returned immediately. A
+// node answering that it already knows the transaction counts as a success,
+// so a retry or a second submission of the same bytes is harmless.
+func broadcastRawTx(ctx context.Context, rawTxHex string, rpcUrl string) (string, error) {
 	body := map[string]interface{}{
 		"jsonrpc": "2.0",
 		"id":      1,
 		"method":  "eth_sendRawTransaction",
 		"params":  []string{"0x" + rawTxHex},
 	}
-	payload, _ := json.Marshal(body)
-	resp, err := http.Post(rpcUrl, "application/json", bytes.NewReader(payload))
+
+	bz, err := makeRPCCallContext(ctx, rpcUrl, body)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
-	bz, _ := io.ReadAll(resp.Body)
+
 	var result struct {
 		Result string `json:"result"`
 		Error  struct {
@@ -420,7 +971,59 @@ func broadcastRawTx(rawTxHex string, rpcUrl string) (string, error) {
 	}
 	json.Unmarshal(bz, &result)
 	if result.Error.Message != "" {
+		if isAlreadyKnown(result.Error.Message) {
+			return signedTxHash(rawTxHex), nil
+		}
+		if err := unsupportedTxTypeError(result.Error.Message); err != nil {
+			return "", err
+		}
 		return "", errors.New(result.Error.Message)
 	}
 	return result.Result, nil
 }
+
+// Broadcast submits a signed EIP-7702 (type 0x04) transaction built elsewhere,
+// given in hex with or without 0x, and returns its hash. The transaction is
+// checked first: type byte, canonical RLP that re-encodes to the same bytes,
+// a sender signature that recovers, and nonces the sender and authorities have
+// not used yet, ErrTxExpired being returned otherwise. Submission then goes
+// through the same path as the commands: connection retries within RPCRetries
+// and RPCMaxAttempts, --rpc-fallback-url style endpoints from
+// RPCFallbackURLs, and a node that already knows the transaction counts as a
+// success. An empty rpcURL means DefaultRPCURL.
+func Broadcast(rawHex, rpcURL string) (string, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(rawHex), "0x"))
+	if err != nil {
+		return "", invalidInput("invalid transaction hex: %v", err)
+	}
+	if len(raw) == 0 || raw[0] != SET_CODE_TX_TYPE {
+		return "", invalidInput("not an EIP-7702 transaction: expected type 0x%02x", SET_CODE_TX_TYPE)
+	}
+	var tx SetCodeTx
+	if err := rlp.DecodeBytes(raw[1:], &tx); err != nil {
+		return "", invalidInput("invalid transaction RLP: %v", err)
+	}
+	if encoded, err := rlp.EncodeToBytes(&tx); err != nil || !bytes.Equal(encoded, raw[1:]) {
+		return "", invalidInput("non-canonical transaction RLP: decoding and re-encoding does not give back the same bytes")
+	}
+	if _, err := tx.Sender(); err != nil {
+		return "", invalidInput("invalid transaction signature: %v", err)
+	}
+
+	if rpcURL == "" {
+		rpcURL = DefaultRPCURL
+	}
+	if err := checkNotExpired(rpcURL, &tx); err != nil {
+		return "", err
+	}
+	return broadcastRawTx(context.Background(), hex.EncodeToString(raw), rpcURL)
+}
+
+// signedTxHash returns the hash of a signed typed transaction in hex form
+func signedTxHash(rawTxHex string) string {
+	raw, err := hex.DecodeString(rawTxHex)
+	if err != nil {
+		return ""
+	}
+	return crypto.Keccak256Hash(raw).Hex()
+}
@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// testKey returns the well-known private key n, e.g. 1 for 0x7E5F...5Bdf
+func testKey(t *testing.T, n int) *KeySigner {
+	t.Helper()
+	key, err := crypto.HexToECDSA(fmt.Sprintf("%064x", n))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewKeySigner(key)
+}
+
+// countingSigner counts the digests it is asked to sign
+type countingSigner struct {
+	*KeySigner
+	calls int
+}
+
+func (s *countingSigner) SignHash(hash []byte) ([]byte, error) {
+	s.calls++
+	return s.KeySigner.SignHash(hash)
+}
+
+// testRequest returns a request delegating key 1 to a template, relayed by key 2
+func testRequest(t *testing.T, relayer Signer) SetAuthorizationRequest {
+	return SetAuthorizationRequest{
+		UserEOAPrivateKey: testKey(t, 1).key,
+		UserEOANonce:      7,
+		RelayerSigner:     relayer,
+		RelayerNonce:      3,
+		TemplateAddress:   common.HexToAddress("0x000000000000000000000000000000000000dEaD"),
+		ChainId:           big.NewInt(11155111),
+		GasTip:            big.NewInt(1e9),
+		GasFeeCap:         big.NewInt(3e9),
+	}
+}
+
+func TestGenerateSet7702AuthTxChainIDMismatch(t *testing.T) {
+	relayer := &countingSigner{KeySigner: testKey(t, 2)}
+	req := testRequest(t, relayer)
+	req.AuthChainId = big.NewInt(1)
+
+	signedTx, err := GenerateSet7702AuthTx(req)
+	if !errors.Is(err, ErrChainIDMismatch) {
+		t.Fatalf("error = %v, want ErrChainIDMismatch", err)
+	}
+	if signedTx != "" || relayer.calls != 0 {
+		t.Fatalf("the transaction was signed (%d relayer signatures, output %q)", relayer.calls, signedTx)
+	}
+}
+
+func TestGenerateSet7702AuthTxAuthChainID(t *testing.T) {
+	tests := []struct {
+		name        string
+		authChainID *big.Int
+		want        int64
+	}{
+		{"transaction chain by default", nil, 11155111},
+		{"same chain", big.NewInt(11155111), 11155111},
+		{"every chain", big.NewInt(0), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testRequest(t, testKey(t, 2))
+			req.AuthChainId = tt.authChainID
+			signedTx, err := GenerateSet7702AuthTx(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tx, err := DecodeSetCodeTx(signedTx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tx.AuthList[0].ChainID; got.Int64() != tt.want {
+				t.Fatalf("authorization chain id %s, want %d", got, tt.want)
+			}
+			if tx.ChainID.Int64() != 11155111 {
+				t.Fatalf("transaction chain id %s, want 11155111", tx.ChainID)
+			}
+		})
+	}
+}
+
+func TestBuildClearAndSetTx(t *testing.T) {
+	user := testKey(t, 1)
+	template := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	tests := []struct {
+		name        string
+		build       func(SetAuthorizationRequest) (string, error)
+		template    common.Address
+		selfSponsor bool
+	}{
+		{"clear", BuildClearTx, common.Address{}, false},
+		{"set", BuildSetTx, template, false},
+		{"clear self-sponsored", BuildClearTx, common.Address{}, true},
+		{"set self-sponsored", BuildSetTx, template, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testRequest(t, testKey(t, 2))
+			req.TemplateAddress = tt.template
+			if tt.selfSponsor {
+				// The sender's nonce is incremented before the authorization is applied
+				req.RelayerSigner = user
+				req.RelayerNonce, req.UserEOANonce = 7, 8
+			}
+			signedTx, err := tt.build(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tx, err := DecodeSetCodeTx(signedTx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tx.AuthList) != 1 {
+				t.Fatalf("%d authorizations, want 1", len(tx.AuthList))
+			}
+			auth := tx.AuthList[0]
+			if auth.Address != tt.template {
+				t.Errorf("authorization address %s, want %s", auth.Address.Hex(), tt.template.Hex())
+			}
+			if auth.ChainID.Cmp(req.ChainId) != 0 || tx.ChainID.Cmp(req.ChainId) != 0 {
+				t.Errorf("chain ids %s and %s, want %s", auth.ChainID, tx.ChainID, req.ChainId)
+			}
+			if auth.Nonce != req.UserEOANonce || tx.Nonce != req.RelayerNonce {
+				t.Errorf("nonces: authorization %d, transaction %d, want %d and %d", auth.Nonce, tx.Nonce, req.UserEOANonce, req.RelayerNonce)
+			}
+			if tt.selfSponsor && auth.Nonce != tx.Nonce+1 {
+				t.Errorf("self-sponsored authorization nonce %d, want the transaction nonce + 1 = %d", auth.Nonce, tx.Nonce+1)
+			}
+			if authority, err := auth.Authority(); err != nil || authority != user.Address() {
+				t.Errorf("authority %s (%v), want %s", authority.Hex(), err, user.Address().Hex())
+			}
+			if sender, err := tx.Sender(); err != nil || sender != req.RelayerSigner.Address() {
+				t.Errorf("sender %s (%v), want %s", sender.Hex(), err, req.RelayerSigner.Address().Hex())
+			}
+		})
+	}
+}
+
+func TestBuildClearAndSetTxRejectWrongTemplate(t *testing.T) {
+	req := testRequest(t, testKey(t, 2))
+	if _, err := BuildClearTx(req); err == nil {
+		t.Error("BuildClearTx accepted a non-zero template address")
+	}
+	req.TemplateAddress = common.Address{}
+	if _, err := BuildSetTx(req); err == nil {
+		t.Error("BuildSetTx accepted the zero address")
+	}
+}
+
+func TestGenerateSet7702AuthTxDefaultGasLimitCoversData(t *testing.T) {
+	req := testRequest(t, testKey(t, 2))
+	req.Data = bytes.Repeat([]byte{0xff}, 4096)
+
+	signedTx, err := GenerateSet7702AuthTx(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := DecodeSetCodeTx(signedTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if minimum := IntrinsicGas(1, req.Data, nil); tx.Gas < minimum {
+		t.Fatalf("gas limit %d, below the intrinsic gas %d", tx.Gas, minimum)
+	}
+	if opts := (TxOptions{CallData: req.Data}); opts.gasLimitFor(1) != tx.Gas {
+		t.Fatalf("gasLimitFor = %d, want the %d used by the transaction", opts.gasLimitFor(1), tx.Gas)
+	}
+}
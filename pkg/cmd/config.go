@@ -0,0 +1,316 @@
+package cmd
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ConfigPassphraseEnv is the environment variable holding the config passphrase
+const ConfigPassphraseEnv = "EIP7702_CONFIG_PASSPHRASE"
+
+// encryptedPrefix marks a config value encrypted with encryptValue
+const encryptedPrefix = "enc:v1:"
+
+// scrypt parameters for deriving the config encryption key
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptSalt   = 16
+	configKeyLen = 32
+)
+
+// Config holds defaults loaded from the configuration file. Flags always take precedence.
+type Config struct {
+	RPCURL   string `json:"rpc_url,omitempty"` // May contain a provider API key, can be stored encrypted
+	GasLimit uint64 `json:"gas_limit,omitempty"`
+
+	RegistryAddress string `json:"registry_address,omitempty"` // On-chain abuse registry used by check --use-onchain-registry
+}
+
+// DefaultConfigPath returns ~/.eip7702cleaner/config.json
+func DefaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".eip7702cleaner", "config.json")
+}
+
+// LoadConfig reads the configuration file. Encrypted values are left as they
+// are, to be decrypted only if used, e.g. with DecryptRPCURL.
+// A missing file yields an empty configuration.
+func LoadConfig(path string) (*Config, error) {
+	cfg, err := readConfigFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &Config{}, nil
+		}
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// RPCURLEncrypted reports whether rpc_url is stored encrypted
+func (c *Config) RPCURLEncrypted() bool {
+	return isEncrypted(c.RPCURL)
+}
+
+// DecryptRPCURL returns rpc_url, decrypted with the passphrase from
+// EIP7702_CONFIG_PASSPHRASE or an interactive prompt when it is encrypted
+func (c *Config) DecryptRPCURL() (string, error) {
+	if !c.RPCURLEncrypted() {
+		return c.RPCURL, nil
+	}
+	passphrase, err := configPassphrase(false)
+	if err != nil {
+		return "", err
+	}
+	value, err := decryptValue(c.RPCURL, passphrase)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt rpc_url: %w", err)
+	}
+	return value, nil
+}
+
+// EncryptConfig encrypts the sensitive values of the configuration file in place
+func EncryptConfig(path string) error {
+	cfg, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	if cfg.RPCURL == "" || isEncrypted(cfg.RPCURL) {
+		fmt.Println("Nothing to encrypt.")
+		return nil
+	}
+
+	passphrase, err := configPassphrase(true)
+	if err != nil {
+		return err
+	}
+	if cfg.RPCURL, err = encryptValue(cfg.RPCURL, passphrase); err != nil {
+		return err
+	}
+	if err := writeConfigFile(path, cfg); err != nil {
+		return err
+	}
+	fmt.Printf("Encrypted rpc_url in %s\n", path)
+	return nil
+}
+
+// DecryptConfig decrypts the encrypted values of the configuration file in place
+func DecryptConfig(path string) error {
+	cfg, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	if !isEncrypted(cfg.RPCURL) {
+		fmt.Println("Nothing to decrypt.")
+		return nil
+	}
+
+	passphrase, err := configPassphrase(false)
+	if err != nil {
+		return err
+	}
+	if cfg.RPCURL, err = decryptValue(cfg.RPCURL, passphrase); err != nil {
+		return fmt.Errorf("failed to decrypt rpc_url: %w", err)
+	}
+	if err := writeConfigFile(path, cfg); err != nil {
+		return err
+	}
+	fmt.Printf("Decrypted rpc_url in %s\n", path)
+	return nil
+}
+
+func readConfigFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// writeConfigFile writes the configuration to a temporary file next to it and
+// renames it into place: encrypting or decrypting rewrites the only copy of the
+// secret, which an interruption must not leave truncated
+func writeConfigFile(path string, cfg *Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// configPassphrase returns the passphrase from the environment or prompts for it.
+// When confirm is set the user is asked to type it twice.
+func configPassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv(ConfigPassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+
+	fmt.Fprintln(promptOutput, "Enter the config passphrase:")
+	passphrase, err := readInput(readSecret)
+	if err != nil {
+		return "", err
+	}
+	if len(passphrase) == 0 {
+		return "", errors.New("passphrase cannot be empty")
+	}
+
+	if confirm {
+		fmt.Fprintln(promptOutput, "Repeat the passphrase:")
+		repeated, err := readInput(readSecret)
+		if err != nil {
+			return "", err
+		}
+		if string(repeated) != string(passphrase) {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+	return string(passphrase), nil
+}
+
+func isEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// encryptValue encrypts a value with AES-256-GCM under a scrypt-derived key.
+// The result is "enc:v1:" followed by base64(salt || nonce || ciphertext).
+func encryptValue(plaintext, passphrase string) (string, error) {
+	salt := make([]byte, scryptSalt)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	aead, err := configAEAD(passphrase, salt)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nil, nonce, []byte(plaintext), nil)
+	blob := append(append(salt, nonce...), sealed...)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(blob), nil
+}
+
+// decryptValue reverses encryptValue
+func decryptValue(value, passphrase string) (string, error) {
+	blob, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	if len(blob) < scryptSalt {
+		return "", errors.New("malformed encrypted value")
+	}
+	aead, err := configAEAD(passphrase, blob[:scryptSalt])
+	if err != nil {
+		return "", err
+	}
+	blob = blob[scryptSalt:]
+	if len(blob) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	plaintext, err := aead.Open(nil, blob[:aead.NonceSize()], blob[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("wrong passphrase or corrupted value")
+	}
+	return string(plaintext), nil
+}
+
+func configAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, configKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// RedactURL hides the secrets an RPC URL may carry: the password of its user
+// info, query parameter values, and path segments that look like API keys
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "<redacted>"
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.UserPassword(u.User.Username(), "redacted")
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			query.Set(key, "redacted")
+		}
+		u.RawQuery = query.Encode()
+	}
+	segments := strings.Split(u.Path, "/")
+	for i, segment := range segments {
+		if looksLikeAPIKey(segment) {
+			segments[i] = "redacted"
+		}
+	}
+	u.Path = strings.Join(segments, "/")
+	return u.String()
+}
+
+// looksLikeAPIKey reports whether a URL path segment is long and random enough to be a key
+func looksLikeAPIKey(segment string) bool {
+	if len(segment) < 16 {
+		return false
+	}
+	digits := 0
+	for _, r := range segment {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}
+
+// ConfigPassphraseSet reports whether the config passphrase is provided by the environment
+func ConfigPassphraseSet() bool {
+	return os.Getenv(ConfigPassphraseEnv) != ""
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Prompts, explanations and progress are written to promptOutput (stderr), so
+// that resultOutput (stdout) only carries what a script wants to capture:
+// transaction hashes, --json-tx output and the verdicts of check and verify
+var (
+	promptOutput io.Writer = color.Error
+	resultOutput io.Writer = color.Output
+)
+
+// console prints the output of the set and clear commands. In quiet mode the
+// educational paragraphs and intermediate progress messages are suppressed,
+// while prompts, summaries and results are always shown.
+type console struct {
+	quiet bool
+}
+
+// info prints an explanatory or progress line unless quiet mode is enabled
+func (c console) info(a ...interface{}) {
+	if !c.quiet {
+		fmt.Fprintln(promptOutput, a...)
+	}
+}
+
+// infof prints a formatted explanatory or progress message unless quiet mode is enabled
+func (c console) infof(format string, a ...interface{}) {
+	if !c.quiet {
+		fmt.Fprintf(promptOutput, format, a...)
+	}
+}
+
+// notice prints a colored line to promptOutput, adding the trailing newline
+// like color.Green and friends do
+func notice(attr color.Attribute, format string, a ...interface{}) {
+	if !strings.HasSuffix(format, "\n") {
+		format += "\n"
+	}
+	color.New(attr).Fprintf(promptOutput, format, a...)
+}
+
+// printTxHash writes the hash of a broadcast transaction, alone on its line, to resultOutput
+func printTxHash(label, txHash string) {
+	notice(color.FgGreen, "%s", label)
+	fmt.Fprintln(resultOutput, txHash)
+}
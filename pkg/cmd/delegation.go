@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"bytes"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DelegationPrefix is the EIP-7702 delegation designator prefix (0xef0100)
+var DelegationPrefix = []byte{0xef, 0x01, 0x00}
+
+// CodeStatus classifies the code found at an address
+type CodeStatus int
+
+const (
+	StatusClean     CodeStatus = iota // No code, a plain EOA
+	StatusDelegated                   // EIP-7702 delegation designator
+	StatusHasCode                     // Other code, most likely a contract
+)
+
+// String returns a short name for the status
+func (s CodeStatus) String() string {
+	switch s {
+	case StatusClean:
+		return "clean"
+	case StatusDelegated:
+		return "delegated"
+	default:
+		return "has-code"
+	}
+}
+
+// CheckResult is the outcome of inspecting an address's code
+type CheckResult struct {
+	Address  common.Address
+	Code     []byte
+	Status   CodeStatus
+	Delegate common.Address // Delegation target, only set when Status is StatusDelegated
+}
+
+// IsDelegated reports whether code is an EIP-7702 delegation designator
+// (0xef0100 followed by a 20-byte address) and returns the delegate address
+func IsDelegated(code []byte) (bool, common.Address) {
+	if len(code) != len(DelegationPrefix)+common.AddressLength || !bytes.HasPrefix(code, DelegationPrefix) {
+		return false, common.Address{}
+	}
+	return true, common.BytesToAddress(code[len(DelegationPrefix):])
+}
+
+// ParseDelegation classifies an already-fetched code blob without any RPC calls
+func ParseDelegation(address common.Address, code []byte) *CheckResult {
+	result := &CheckResult{Address: address, Code: code}
+	switch delegated, delegate := IsDelegated(code); {
+	case len(code) == 0:
+		result.Status = StatusClean
+	case delegated:
+		result.Status = StatusDelegated
+		result.Delegate = delegate
+	default:
+		result.Status = StatusHasCode
+	}
+	return result
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/fatih/color"
+)
+
+// demoKeys are the keys given with --demo-key, handed out in order by
+// readPrivateKey instead of prompting, so demos and bug reports are reproducible
+var (
+	demoKeys     []*ecdsa.PrivateKey
+	demoKeysUsed int
+)
+
+// ErrDemoKeyMainnet is returned when a demo key would be used on Ethereum Mainnet
+var ErrDemoKeyMainnet = errors.New("--demo-key is refused on Ethereum Mainnet (chain ID 1)")
+
+// SetDemoKeys replaces interactive key entry with the given well-known keys,
+// one per prompt in order. Such keys are public, so any funds or delegation
+// they control can be taken by anyone; chain ID 1 is refused outright.
+func SetDemoKeys(keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	for _, key := range keys {
+		privateKey, err := crypto.HexToECDSA(strings.TrimPrefix(strings.TrimSpace(key), "0x"))
+		if err != nil {
+			return invalidInput("invalid --demo-key: %v", err)
+		}
+		demoKeys = append(demoKeys, privateKey)
+	}
+	notice(color.FgRed, "WARNING: --demo-key is INSECURE and meant for demos and bug reports only.")
+	notice(color.FgRed, "Keys passed on the command line are visible to other users and kept in the shell history,")
+	notice(color.FgRed, "and well-known test keys are drained by bots. Never use it with a key that holds value.")
+	return nil
+}
+
+// nextDemoKey returns the demo key for the current prompt
+func nextDemoKey() (*ecdsa.PrivateKey, error) {
+	if demoKeysUsed == len(demoKeys) {
+		// Like an empty line, which also ends a --batch
+		return nil, fmt.Errorf("%w: all %d --demo-key already used", errEmptyKey, len(demoKeys))
+	}
+	key := demoKeys[demoKeysUsed]
+	demoKeysUsed++
+	fmt.Fprintf(promptOutput, "Using demo key #%d (%s)\n", demoKeysUsed, crypto.PubkeyToAddress(key.PublicKey).Hex())
+	// readPrivateKey's callers wipe the key they get, keep the original intact
+	copied, err := crypto.ToECDSA(crypto.FromECDSA(key))
+	if err != nil {
+		return nil, err
+	}
+	return lockKey(copied), nil
+}
+
+// checkDemoChain refuses Ethereum Mainnet while demo keys are in use, as well
+// as chain ID 0, which would make an authorization valid on Mainnet too
+func checkDemoChain(chainID *big.Int) error {
+	if len(demoKeys) == 0 {
+		return nil
+	}
+	switch {
+	case chainID.Sign() == 0:
+		return WithErrorType(ErrorTypeInvalidInput, fmt.Errorf("%w: an authorization for chain ID 0 is valid there too", ErrDemoKeyMainnet))
+	case chainID.Cmp(big.NewInt(1)) == 0:
+		return WithErrorType(ErrorTypeInvalidInput, ErrDemoKeyMainnet)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/fatih/color"
+)
+
+// printDryRun prints every field of the signed transaction signedTx, decoded
+// with go-ethereum's transaction types rather than this package's own decoder,
+// along with the signers its signatures recover to. It is the output of
+// DryRun, which stops before anything is broadcast.
+func printDryRun(signedTx string) error {
+	raw, err := hex.DecodeString(signedTx)
+	if err != nil {
+		return fmt.Errorf("failed to decode the signed transaction: %w", err)
+	}
+	var tx types.Transaction
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return fmt.Errorf("failed to decode the signed transaction: %w", err)
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), &tx)
+	if err != nil {
+		return verificationFailure("the transaction signature does not recover: %v", err)
+	}
+	symbol := nativeSymbol(tx.ChainId())
+
+	fmt.Fprintln(resultOutput, "\nDry run, the signed transaction decoded field by field:")
+	fmt.Fprintf(resultOutput, "  Chain ID:                 %s\n", chainLabel(tx.ChainId()))
+	fmt.Fprintf(resultOutput, "  Type:                     0x%02x\n", tx.Type())
+	fmt.Fprintf(resultOutput, "  Sender (recovered):       %s\n", sender.Hex())
+	fmt.Fprintf(resultOutput, "  Nonce:                    %d\n", tx.Nonce())
+	fmt.Fprintf(resultOutput, "  Max priority fee per gas: %s wei (%.6f Gwei)\n", tx.GasTipCap(), weiToGwei(tx.GasTipCap()))
+	fmt.Fprintf(resultOutput, "  Max fee per gas:          %s wei (%.6f Gwei)\n", tx.GasFeeCap(), weiToGwei(tx.GasFeeCap()))
+	fmt.Fprintf(resultOutput, "  Gas limit:                %d\n", tx.Gas())
+	if to := tx.To(); to != nil {
+		fmt.Fprintf(resultOutput, "  To:                       %s\n", to.Hex())
+	} else {
+		fmt.Fprintf(resultOutput, "  To:                       (contract creation)\n")
+	}
+	fmt.Fprintf(resultOutput, "  Value:                    %s wei (%.9f %s)\n", tx.Value(), weiToEth(tx.Value()), symbol)
+	fmt.Fprintf(resultOutput, "  Data:                     %s\n", hexutil.Encode(tx.Data()))
+	fmt.Fprintf(resultOutput, "  Access list:              %d entries\n", len(tx.AccessList()))
+	for _, entry := range tx.AccessList() {
+		fmt.Fprintf(resultOutput, "    %s, %d storage keys\n", entry.Address.Hex(), len(entry.StorageKeys))
+	}
+	for i, auth := range tx.SetCodeAuthorizations() {
+		authority := "unrecoverable signature"
+		if address, err := auth.Authority(); err == nil {
+			authority = address.Hex()
+		}
+		chain := auth.ChainID.ToBig().String()
+		if auth.ChainID.IsZero() {
+			chain = "0 (valid on every chain)"
+		}
+		target := auth.Address.Hex()
+		if auth.Address == (common.Address{}) {
+			target += " (clears the delegation)"
+		}
+		fmt.Fprintf(resultOutput, "  Authorization #%d:\n", i+1)
+		fmt.Fprintf(resultOutput, "    Chain ID:               %s\n", chain)
+		fmt.Fprintf(resultOutput, "    Delegate to:            %s\n", target)
+		fmt.Fprintf(resultOutput, "    Nonce:                  %d\n", auth.Nonce)
+		fmt.Fprintf(resultOutput, "    Authority (recovered):  %s\n", authority)
+		fmt.Fprintf(resultOutput, "    Signature:              yParity %d, r %s, s %s\n", auth.V, hexutil.EncodeBig(auth.R.ToBig()), hexutil.EncodeBig(auth.S.ToBig()))
+	}
+	v, r, s := tx.RawSignatureValues()
+	fmt.Fprintf(resultOutput, "  Signature:                yParity %s, r %s, s %s\n", v, hexutil.EncodeBig(r), hexutil.EncodeBig(s))
+	maxCost := new(big.Int).Add(maxGasCost(tx.GasFeeCap(), tx.Gas()), tx.Value())
+	fmt.Fprintf(resultOutput, "  Max cost (gas + value):   %.9f %s\n", weiToEth(maxCost), symbol)
+	fmt.Fprintf(resultOutput, "  Size:                     %d bytes\n", len(raw))
+	fmt.Fprintf(resultOutput, "  Hash once broadcast:      %s\n", tx.Hash().Hex())
+	notice(color.FgCyan, "\nDry run: nothing was broadcast, and the signed transaction was not kept.")
+	return nil
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Error types reported by ErrorType, a stable classification of failures for
+// automated consumers of the --json error output
+const (
+	ErrorTypeCancelled    = "cancelled"     // The user declined a confirmation or interrupted the operation
+	ErrorTypeInvalidInput = "invalid_input" // A flag, argument, key or file is invalid
+	ErrorTypeRPC          = "rpc"           // The RPC endpoint could not be reached or read
+	ErrorTypeTimeout      = "timeout"       // A deadline expired
+	ErrorTypeVerification = "verification"  // The on-chain state is not the expected one
+	ErrorTypeInternal     = "internal"      // Anything else
+)
+
+// ErrCancelled is returned when the user declines a confirmation prompt
+var ErrCancelled = &TypedError{Type: ErrorTypeCancelled, Err: errors.New("operation cancelled by user")}
+
+// TypedError attaches one of the ErrorType* classifications to an error
+type TypedError struct {
+	Type string
+	Err  error
+}
+
+func (e *TypedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TypedError) Unwrap() error {
+	return e.Err
+}
+
+// WithErrorType classifies err, unless something it wraps is already classified
+func WithErrorType(errorType string, err error) error {
+	var typed *TypedError
+	if err == nil || errors.As(err, &typed) {
+		return err
+	}
+	return &TypedError{Type: errorType, Err: err}
+}
+
+// invalidInput returns a formatted error classified as ErrorTypeInvalidInput
+func invalidInput(format string, a ...interface{}) error {
+	return &TypedError{Type: ErrorTypeInvalidInput, Err: fmt.Errorf(format, a...)}
+}
+
+// ErrorType returns the classification of an error: the type of the outermost
+// TypedError it wraps, ErrorTypeTimeout for expired deadlines, ErrorTypeCancelled
+// for an interrupted operation, ErrorTypeInternal otherwise
+func ErrorType(err error) string {
+	var typed *TypedError
+	switch {
+	case errors.As(err, &typed):
+		return typed.Type
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorTypeTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrorTypeCancelled
+	default:
+		return ErrorTypeInternal
+	}
+}
+
+// rpcFailure classifies a failed RPC round trip, as a timeout when a deadline expired
+func rpcFailure(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return WithErrorType(ErrorTypeTimeout, err)
+	}
+	return WithErrorType(ErrorTypeRPC, err)
+}
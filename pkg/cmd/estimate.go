@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/fatih/color"
+)
+
+// rescueEstimate is the read-only analysis of what a clear + sweep would recover
+type rescueEstimate struct {
+	ChainID      *big.Int
+	Balance      *big.Int     // Victim's native balance
+	Tokens       []tokenSweep // Non-zero token balances and their transfer gas
+	ClearCost    *big.Int     // Max cost of the clear transaction, paid by the relayer
+	TokenGasCost *big.Int     // Max cost of the token transfers, paid by the victim
+	SweepGasCost *big.Int     // Max cost of the native sweep, paid by the victim
+}
+
+// TotalGasCost returns the worst case gas spent on the whole rescue
+func (e *rescueEstimate) TotalGasCost() *big.Int {
+	total := new(big.Int).Add(e.ClearCost, e.TokenGasCost)
+	return total.Add(total, e.SweepGasCost)
+}
+
+// NetRecoverable returns the native amount that would reach the safe address
+func (e *rescueEstimate) NetRecoverable() *big.Int {
+	net := new(big.Int).Sub(e.Balance, e.TokenGasCost)
+	net.Sub(net, e.SweepGasCost)
+	if net.Sign() < 0 {
+		return new(big.Int)
+	}
+	return net
+}
+
+// estimateRescue gathers balances and gas prices for a rescue of victim without signing anything
+func estimateRescue(victim common.Address, opts TxOptions) (*rescueEstimate, error) {
+	rpcURL := opts.rpcURLOrDefault()
+
+	chainID, err := getChainID(rpcURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chain ID: %w", err)
+	}
+	balance, err := getBalance(rpcURL, victim.Hex())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get victim balance: %w", err)
+	}
+	_, gasFeeCap, err := getSuggestedGasFees(rpcURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get suggested gas fees: %w", err)
+	}
+
+	// Transfers are estimated towards the safe address when known
+	recipient := victim
+	if common.IsHexAddress(opts.SafeAddress) {
+		recipient = common.HexToAddress(opts.SafeAddress)
+	}
+
+	var tokens []tokenSweep
+	var tokenGas uint64
+	for _, token := range opts.SweepTokens {
+		amount, err := getTokenBalance(rpcURL, token, victim)
+		if err != nil || amount.Sign() == 0 {
+			continue
+		}
+		gas, err := estimateGas(rpcURL, victim, token, tokenTransferData(recipient, amount))
+		if err != nil {
+			gas = defaultTokenTransferGas
+		}
+		tokens = append(tokens, tokenSweep{Token: token, Info: getTokenInfo(rpcURL, token), Amount: amount, GasUsed: gas})
+		tokenGas += gas
+	}
+
+	return &rescueEstimate{
+		ChainID:      chainID,
+		Balance:      balance,
+		Tokens:       tokens,
+		ClearCost:    maxGasCost(gasFeeCap, opts.gasLimitFor(1)),
+		TokenGasCost: maxGasCost(gasFeeCap, tokenGas),
+		SweepGasCost: maxGasCost(gasFeeCap, transferGasLimit),
+	}, nil
+}
+
+// checkRecoverable aborts a clear when the victim's net recoverable native value
+// is below opts.MinRecoverable, after printing the comparison. Token balances
+// cannot be valued, so any non-zero balance of a --sweep-tokens token lets the
+// rescue go ahead.
+func checkRecoverable(victim common.Address, opts TxOptions) error {
+	if opts.MinRecoverable == nil {
+		return nil
+	}
+	estimate, err := estimateRescue(victim, opts)
+	if err != nil {
+		return err
+	}
+	symbol := nativeSymbol(estimate.ChainID)
+	net := estimate.NetRecoverable()
+
+	fmt.Fprintf(promptOutput, "\nNet recoverable value: %.9f %s (balance %.9f, minus up to %.9f sweep gas)\n",
+		weiToEth(net), symbol, weiToEth(estimate.Balance), weiToEth(new(big.Int).Add(estimate.TokenGasCost, estimate.SweepGasCost)))
+	fmt.Fprintf(promptOutput, "Clear gas paid by the relayer: up to %.9f %s\n", weiToEth(estimate.ClearCost), symbol)
+	fmt.Fprintf(promptOutput, "Minimum to proceed (--abort-if-balance-below): %.9f %s\n", weiToEth(opts.MinRecoverable), symbol)
+
+	if net.Cmp(opts.MinRecoverable) >= 0 {
+		return nil
+	}
+	if len(estimate.Tokens) > 0 {
+		notice(color.FgYellow, "The native value is below the minimum, but %d token(s) have a balance to recover; continuing.", len(estimate.Tokens))
+		return nil
+	}
+	return fmt.Errorf("recoverable value %.9f %s is below the --abort-if-balance-below minimum of %.9f %s, rescue aborted",
+		weiToEth(net), symbol, weiToEth(opts.MinRecoverable), symbol)
+}
+
+// EstimateRescue reports the value a clear + sweep of victim would recover
+// against the gas it would cost. It is read-only and needs no private keys.
+func EstimateRescue(victim string, opts TxOptions) error {
+	if !common.IsHexAddress(victim) {
+		return invalidInput("invalid victim address format: %s", victim)
+	}
+	victimAddress := common.HexToAddress(victim)
+
+	estimate, err := estimateRescue(victimAddress, opts)
+	if err != nil {
+		return err
+	}
+
+	var price float64
+	if opts.Fiat != "" {
+		if price, err = fetchNativePrice(estimate.ChainID, opts.Fiat); err != nil {
+			color.Yellow("Fiat conversion unavailable: %v", err)
+		}
+	}
+	symbol := nativeSymbol(estimate.ChainID)
+	amount := func(wei *big.Int) string {
+		text := fmt.Sprintf("%.9f %s", weiToEth(wei), symbol)
+		if price > 0 {
+			text += fmt.Sprintf(" (%.2f %s)", weiToFiat(wei, price), opts.Fiat)
+		}
+		return text
+	}
+
+	color.Cyan("Rescue estimate for %s on %s", victimAddress.Hex(), chainLabel(estimate.ChainID))
+	fmt.Printf("Native balance: %s\n", amount(estimate.Balance))
+	for _, token := range estimate.Tokens {
+		fmt.Printf("Token %s: %s\n", token.Info.label(token.Token), token.Info.format(token.Amount))
+	}
+	fmt.Println("\nEstimated max gas costs:")
+	fmt.Printf("  Clear (relayer): %s\n", amount(estimate.ClearCost))
+	if len(estimate.Tokens) > 0 {
+		fmt.Printf("  Token transfers (victim): %s\n", amount(estimate.TokenGasCost))
+	}
+	fmt.Printf("  Native sweep (victim): %s\n", amount(estimate.SweepGasCost))
+	fmt.Printf("  Total: %s\n", amount(estimate.TotalGasCost()))
+
+	net := estimate.NetRecoverable()
+	fmt.Printf("\nNet recoverable native value: %s\n", amount(net))
+
+	if estimate.TotalGasCost().Cmp(net) > 0 {
+		if len(estimate.Tokens) > 0 {
+			color.Yellow("⚠ Gas exceeds the recoverable native value; the rescue is only economical if the tokens are worth more than the difference.")
+		} else {
+			color.Yellow("⚠ Gas exceeds the recoverable value; this rescue is not economical.")
+		}
+	} else {
+		color.Green("✓ The recoverable value exceeds the estimated gas cost.")
+	}
+	return nil
+}
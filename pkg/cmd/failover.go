@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RPCFallbackURLs are tried in order after the RPC URL of a call when it cannot be used
+var RPCFallbackURLs []string
+
+// RPCRetries is how many times a transient failure is retried on the same
+// endpoint before failing over to the next one
+var RPCRetries int
+
+// RPCMaxAttempts caps the attempts of a single call across all endpoints, 0 for no cap
+var RPCMaxAttempts int
+
+// rpcRetryDelay is the pause before the first retry on an endpoint, doubled on each retry
+const rpcRetryDelay = 500 * time.Millisecond
+
+// httpStatusError is an HTTP status that makes a response unusable
+type httpStatusError struct {
+	status int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("HTTP %d %s", e.status, http.StatusText(e.status))
+}
+
+// rpcAttempt sends one request to one endpoint
+type rpcAttempt func(ctx context.Context, endpoint string) ([]byte, error)
+
+// rpcEndpoints returns rpcURL followed by the fallback endpoints, without duplicates
+func rpcEndpoints(rpcURL string) []string {
+	endpoints := []string{rpcURL}
+	for _, fallback := range RPCFallbackURLs {
+		if fallback != "" && fallback != rpcURL {
+			endpoints = append(endpoints, fallback)
+		}
+	}
+	return endpoints
+}
+
+// endpointIsDead reports whether a failure means the endpoint cannot answer at
+// all, so retrying it is pointless: unresolvable host, refused connection,
+// rejected TLS handshake or an unusable URL
+func endpointIsDead(err error) bool {
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &dnsErr):
+		return !dnsErr.IsTemporary
+	case errors.As(err, &opErr) && opErr.Op == "dial" && !opErr.Timeout():
+		// Refused connections and unreachable hosts or networks, on every platform
+		return true
+	case strings.Contains(err.Error(), "tls:"), strings.Contains(err.Error(), "unsupported protocol scheme"):
+		return true
+	}
+	return false
+}
+
+var (
+	endpointMu       sync.Mutex
+	reportedEndpoint string
+)
+
+// reportEndpoint tells the user when calls start being answered by another
+// endpoint than the previous ones
+func reportEndpoint(endpoints []string, endpoint string) {
+	endpointMu.Lock()
+	defer endpointMu.Unlock()
+	if endpoint == reportedEndpoint || (reportedEndpoint == "" && endpoint == endpoints[0]) {
+		return
+	}
+	reportedEndpoint = endpoint
+	if endpoint == endpoints[0] {
+		notice(color.FgGreen, "RPC calls are answered by %s again", RedactURL(endpoint))
+		return
+	}
+	notice(color.FgYellow, "RPC endpoint %s failed, calls are now answered by %s", RedactURL(endpoints[0]), RedactURL(endpoint))
+}
+
+// withFailover runs attempt against the endpoints in order. A transient failure
+// is retried on the same endpoint up to RPCRetries times, with a growing pause,
+// while an endpoint found dead is abandoned at once. Attempts across all
+// endpoints stop at RPCMaxAttempts, and none is made once ctx is done. The call
+// is traced as one span named after method, with an event per failed attempt.
+func withFailover(ctx context.Context, method string, endpoints []string, attempt rpcAttempt) (result []byte, err error) {
+	ctx, span := startRPCSpan(ctx, method)
+	started := time.Now()
+	attempts := 0
+	defer func() {
+		span.SetAttributes(attribute.Int("rpc.attempts", attempts), attribute.Int64("rpc.latency_ms", time.Since(started).Milliseconds()))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	var failures []string
+	var lastErr error
+	exhausted := func() error {
+		switch {
+		case attempts == 1:
+			return rpcFailure(lastErr)
+		case len(endpoints) == 1:
+			return rpcFailure(fmt.Errorf("RPC call failed after %d attempts: %w", attempts, lastErr))
+		}
+		return rpcFailure(fmt.Errorf("all RPC endpoints failed after %d attempts (%s): %w", attempts, strings.Join(failures, "; "), lastErr))
+	}
+
+	for _, endpoint := range endpoints {
+		for retry := 0; retry <= RPCRetries; retry++ {
+			if RPCMaxAttempts > 0 && attempts >= RPCMaxAttempts {
+				if retry > 0 {
+					failures = append(failures, fmt.Sprintf("%s: %v", RedactURL(endpoint), lastErr))
+				}
+				failures = append(failures, fmt.Sprintf("stopped at --rpc-max-attempts %d", RPCMaxAttempts))
+				return nil, exhausted()
+			}
+			if retry > 0 {
+				select {
+				case <-ctx.Done():
+					return nil, exhausted()
+				case <-time.After(rpcRetryDelay << (retry - 1)):
+				}
+			}
+
+			attempts++
+			body, err := attempt(ctx, endpoint)
+			if err == nil {
+				if len(endpoints) > 1 {
+					reportEndpoint(endpoints, endpoint)
+				}
+				span.SetAttributes(attribute.String("server.address", RedactURL(endpoint)))
+				return body, nil
+			}
+			span.AddEvent("attempt failed", trace.WithAttributes(
+				attribute.String("server.address", RedactURL(endpoint)), attribute.String("error", err.Error())))
+			lastErr = err
+			if ctx.Err() != nil {
+				return nil, exhausted()
+			}
+			if endpointIsDead(err) {
+				break
+			}
+		}
+		failures = append(failures, fmt.Sprintf("%s: %v", RedactURL(endpoint), lastErr))
+	}
+	return nil, exhausted()
+}
+
+// postRPC sends a JSON-RPC payload to one endpoint within RPCTimeout and returns
+// the response body, failing the attempt on an unusable status
+func postRPC(ctx context.Context, endpoint string, payload []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, rpcTimeout())
+	defer cancel()
+
+	req, err := newRPCRequest(ctx, endpoint, payload)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := unusableStatus(resp.StatusCode, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// unusableStatus returns an httpStatusError for rate limiting and for server
+// errors, unless the body is a JSON-RPC error, which some nodes report with a 5xx
+func unusableStatus(status int, body []byte) error {
+	if status == http.StatusTooManyRequests {
+		return &httpStatusError{status: status}
+	}
+	if status < 500 {
+		return nil
+	}
+	var envelope rpcEnvelope
+	if json.Unmarshal(body, &envelope) == nil && len(envelope.Error) > 0 && string(envelope.Error) != "null" {
+		return nil
+	}
+	return &httpStatusError{status: status}
+}
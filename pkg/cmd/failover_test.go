@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestEndpointIsDead(t *testing.T) {
+	// A port that was just released refuses connections
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	_, refused := net.Dial("tcp", address)
+	if refused == nil {
+		t.Skip("the released port accepted a connection")
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"refused connection", refused, true},
+		{"unknown host", &net.DNSError{Err: "no such host", Name: "rpc.invalid", IsNotFound: true}, true},
+		{"temporary DNS failure", &net.DNSError{Err: "server misbehaving", Name: "rpc.example.com", IsTemporary: true}, false},
+		{"read failure", &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}, false},
+		{"deadline", context.DeadlineExceeded, false},
+		{"http status", &httpStatusError{status: 503}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := endpointIsDead(tt.err); got != tt.want {
+				t.Fatalf("endpointIsDead(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// feeHistoryBlocks is the number of recent blocks the congestion advisory looks at
+const feeHistoryBlocks = 20
+
+// feeHistory is the part of an eth_feeHistory response the advisory uses
+type feeHistory struct {
+	BaseFees      []*big.Int // Oldest first, the last entry is the next block's base fee
+	GasUsedRatios []float64
+}
+
+// getFeeHistory fetches the base fees and gas usage of the last blocks
+func getFeeHistory(rpcURL string, blocks int) (*feeHistory, error) {
+	body := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_feeHistory",
+		"params":  []interface{}{fmt.Sprintf("0x%x", blocks), "latest", []interface{}{}},
+	}
+
+	responseBody, err := makeRPCCall(rpcURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Result *struct {
+			BaseFeePerGas []string  `json:"baseFeePerGas"`
+			GasUsedRatio  []float64 `json:"gasUsedRatio"`
+		} `json:"result"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		return nil, err
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("eth_feeHistory failed: %s", result.Error.Message)
+	}
+	if result.Result == nil || len(result.Result.BaseFeePerGas) < 2 {
+		return nil, fmt.Errorf("eth_feeHistory returned no base fees")
+	}
+
+	history := &feeHistory{GasUsedRatios: result.Result.GasUsedRatio}
+	for _, fee := range result.Result.BaseFeePerGas {
+		value, ok := new(big.Int).SetString(strings.TrimPrefix(fee, "0x"), 16)
+		if !ok {
+			return nil, fmt.Errorf("invalid base fee %q", fee)
+		}
+		history.BaseFees = append(history.BaseFees, value)
+	}
+	return history, nil
+}
+
+// averageGwei returns the mean of base fees in Gwei
+func averageGwei(fees []*big.Int) float64 {
+	sum := new(big.Int)
+	for _, fee := range fees {
+		sum.Add(sum, fee)
+	}
+	avg, _ := weiToGwei(sum).Float64()
+	return avg / float64(len(fees))
+}
+
+// advisory summarizes the base fee trend: the older half of the window is
+// compared with the newer half, and blocks that are mostly full count as congestion
+func (h *feeHistory) advisory() (string, bool) {
+	half := len(h.BaseFees) / 2
+	older, newer := averageGwei(h.BaseFees[:half]), averageGwei(h.BaseFees[half:])
+	change := 0.0
+	if older > 0 {
+		change = (newer - older) / older * 100
+	}
+
+	fullness := 0.0
+	for _, ratio := range h.GasUsedRatios {
+		fullness += ratio
+	}
+	if len(h.GasUsedRatios) > 0 {
+		fullness = fullness / float64(len(h.GasUsedRatios)) * 100
+	}
+
+	first, _ := weiToGwei(h.BaseFees[0]).Float64()
+	last, _ := weiToGwei(h.BaseFees[len(h.BaseFees)-1]).Float64()
+	trend := fmt.Sprintf("%+.0f%% over the last %d blocks, %.3f → %.3f Gwei, blocks %.0f%% full on average",
+		change, len(h.BaseFees)-1, first, last, fullness)
+
+	switch {
+	case change > 10 || fullness > 90:
+		return fmt.Sprintf("congested, base fee trending up (%s). Non-urgent operations may be cheaper later.", trend), true
+	case change < -10:
+		return fmt.Sprintf("easing, base fee trending down (%s).", trend), false
+	default:
+		return fmt.Sprintf("calm, base fee stable (%s).", trend), false
+	}
+}
+
+// printFeeAdvisory prints a one-line congestion advisory from eth_feeHistory.
+// It is informational only: failures are reported and nothing is aborted.
+func printFeeAdvisory(rpcURL string) {
+	history, err := getFeeHistory(rpcURL, feeHistoryBlocks)
+	if err != nil {
+		notice(color.FgYellow, "Fee advisory unavailable: %v", err)
+		return
+	}
+	line, congested := history.advisory()
+	if congested {
+		notice(color.FgYellow, "Network: %s", line)
+	} else {
+		notice(color.FgGreen, "Network: %s", line)
+	}
+}
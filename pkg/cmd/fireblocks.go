@@ -0,0 +1,291 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/fatih/color"
+)
+
+// DefaultFireblocksURL is the Fireblocks API endpoint, overridden with FIREBLOCKS_API_URL
+const DefaultFireblocksURL = "https://api.fireblocks.io"
+
+// DefaultFireblocksAsset is the asset whose key signs, the Ethereum key of the vault account
+const DefaultFireblocksAsset = "ETH"
+
+// fireblocksApprovalTimeout bounds the wait for a RAW signing request to go
+// through the workspace policy, approvals included
+const fireblocksApprovalTimeout = 10 * time.Minute
+
+// fireblocksFailedStatuses are the final statuses of a request that will not be signed
+var fireblocksFailedStatuses = map[string]bool{
+	"REJECTED":  true,
+	"BLOCKED":   true,
+	"FAILED":    true,
+	"CANCELLED": true,
+}
+
+// FireblocksSigner is a Signer backed by the RAW signing operation of
+// Fireblocks, for relayer accounts held in a Fireblocks vault account. Every
+// signature is a transaction of the workspace: it goes through the Transaction
+// Authorization Policy, which must allow RAW signing from the vault account,
+// and any approval it requires. The API user is read from FIREBLOCKS_API_KEY
+// and its RSA secret key from the PEM file at FIREBLOCKS_API_SECRET_PATH.
+type FireblocksSigner struct {
+	baseURL string
+	apiKey  string
+	secret  *rsa.PrivateKey
+	vault   string
+	asset   string
+	note    string
+	address common.Address
+}
+
+// NewFireblocksSigner returns a Signer for the key of asset, DefaultFireblocksAsset
+// when empty, in the Fireblocks vault account vaultID. note is attached to
+// every signing request for the approvers and the audit trail.
+func NewFireblocksSigner(vaultID, asset, note string) (*FireblocksSigner, error) {
+	apiKey, secretPath := os.Getenv("FIREBLOCKS_API_KEY"), os.Getenv("FIREBLOCKS_API_SECRET_PATH")
+	if apiKey == "" || secretPath == "" {
+		return nil, invalidInput("FIREBLOCKS_API_KEY and FIREBLOCKS_API_SECRET_PATH must be set to sign with Fireblocks")
+	}
+	secretPEM, err := os.ReadFile(secretPath)
+	if err != nil {
+		return nil, invalidInput("failed to read the Fireblocks API secret: %v", err)
+	}
+	secret, err := parseRSAKey(secretPEM)
+	if err != nil {
+		return nil, invalidInput("invalid Fireblocks API secret %s: %v", secretPath, err)
+	}
+	baseURL := strings.TrimRight(os.Getenv("FIREBLOCKS_API_URL"), "/")
+	if baseURL == "" {
+		baseURL = DefaultFireblocksURL
+	}
+	if asset == "" {
+		asset = DefaultFireblocksAsset
+	}
+	signer := &FireblocksSigner{baseURL: baseURL, apiKey: apiKey, secret: secret, vault: vaultID, asset: asset, note: note}
+
+	var addresses struct {
+		Addresses []struct {
+			Address string `json:"address"`
+		} `json:"addresses"`
+	}
+	path := fmt.Sprintf("/v1/vault/accounts/%s/%s/addresses_paginated", url.PathEscape(vaultID), url.PathEscape(asset))
+	if err := signer.call(http.MethodGet, path, nil, &addresses); err != nil {
+		return nil, fmt.Errorf("failed to read the %s address of Fireblocks vault account %s: %w", asset, vaultID, err)
+	}
+	if len(addresses.Addresses) == 0 || !common.IsHexAddress(addresses.Addresses[0].Address) {
+		return nil, invalidInput("Fireblocks vault account %s has no %s address", vaultID, asset)
+	}
+	signer.address = common.HexToAddress(addresses.Addresses[0].Address)
+	return signer, nil
+}
+
+// Address returns the address of the vault account
+func (s *FireblocksSigner) Address() common.Address {
+	return s.address
+}
+
+// SignHash submits a RAW signing request for the digest, waits for it to be
+// approved and signed, and checks the signature recovers to the vault account
+func (s *FireblocksSigner) SignHash(hash []byte) ([]byte, error) {
+	externalID := make([]byte, 16)
+	if _, err := rand.Read(externalID); err != nil {
+		return nil, err
+	}
+	request := map[string]interface{}{
+		"operation": "RAW",
+		"assetId":   s.asset,
+		"source":    map[string]string{"type": "VAULT_ACCOUNT", "id": s.vault},
+		"note":      s.note,
+		// Lets a retried request be recognised instead of signed twice
+		"externalTxId": "eip7702cleaner-" + hex.EncodeToString(externalID),
+		"extraParameters": map[string]interface{}{
+			"rawMessageData": map[string]interface{}{
+				"messages": []map[string]string{{"content": hex.EncodeToString(hash)}},
+			},
+		},
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := s.call(http.MethodPost, "/v1/transactions", request, &created); err != nil {
+		return nil, fmt.Errorf("failed to submit the Fireblocks signing request: %w", err)
+	}
+	notice(color.FgCyan, "Fireblocks signing request %s submitted, waiting for the workspace policy and approvals...", created.ID)
+
+	deadline := time.Now().Add(fireblocksApprovalTimeout)
+	lastStatus := ""
+	for {
+		var tx struct {
+			Status         string `json:"status"`
+			SubStatus      string `json:"subStatus"`
+			SignedMessages []struct {
+				Signature struct {
+					R string `json:"r"`
+					S string `json:"s"`
+					V int    `json:"v"`
+				} `json:"signature"`
+			} `json:"signedMessages"`
+		}
+		if err := s.call(http.MethodGet, "/v1/transactions/"+url.PathEscape(created.ID), nil, &tx); err != nil {
+			return nil, fmt.Errorf("failed to read Fireblocks signing request %s: %w", created.ID, err)
+		}
+		if tx.Status != lastStatus {
+			fmt.Fprintf(promptOutput, "Fireblocks request %s: %s\n", created.ID, tx.Status)
+			lastStatus = tx.Status
+		}
+		switch {
+		case tx.Status == "COMPLETED":
+			if len(tx.SignedMessages) != 1 {
+				return nil, fmt.Errorf("Fireblocks request %s completed with %d signatures", created.ID, len(tx.SignedMessages))
+			}
+			return s.checkSignature(hash, tx.SignedMessages[0].Signature.R, tx.SignedMessages[0].Signature.S, tx.SignedMessages[0].Signature.V)
+		case fireblocksFailedStatuses[tx.Status]:
+			return nil, fmt.Errorf("Fireblocks request %s was not signed: %s %s", created.ID, tx.Status, tx.SubStatus)
+		case time.Now().After(deadline):
+			return nil, fmt.Errorf("Fireblocks request %s is still %s after %s, cancel it in the console", created.ID, tx.Status, fireblocksApprovalTimeout)
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// checkSignature assembles a Fireblocks signature and checks it recovers to the vault account
+func (s *FireblocksSigner) checkSignature(hash []byte, rHex, sHex string, v int) ([]byte, error) {
+	r, errR := hex.DecodeString(strings.TrimPrefix(rHex, "0x"))
+	sBytes, errS := hex.DecodeString(strings.TrimPrefix(sHex, "0x"))
+	if errR != nil || errS != nil || len(r) > 32 || len(sBytes) > 32 || v < 0 {
+		return nil, errors.New("Fireblocks returned an invalid signature")
+	}
+	if v >= 27 {
+		v -= 27
+	}
+	sig := append(common.LeftPadBytes(r, 32), common.LeftPadBytes(sBytes, 32)...)
+	sig = append(sig, byte(v))
+	pub, err := ethcrypto.SigToPub(hash, sig)
+	if err != nil {
+		return nil, fmt.Errorf("Fireblocks returned an unrecoverable signature: %w", err)
+	}
+	if signer := ethcrypto.PubkeyToAddress(*pub); signer != s.address {
+		return nil, fmt.Errorf("the Fireblocks signature recovers to %s instead of %s, check --fireblocks-asset", signer.Hex(), s.address.Hex())
+	}
+	return sig, nil
+}
+
+// call makes a Fireblocks API call, authenticated with a JWT signed by the API
+// secret that covers the path and a hash of the body, and decodes the answer
+func (s *FireblocksSigner) call(method, path string, request, result interface{}) error {
+	var payload []byte
+	if request != nil {
+		var err error
+		if payload, err = json.Marshal(request); err != nil {
+			return err
+		}
+	}
+	token, err := s.jwt(path, payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-API-Key", s.apiKey)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		var failure struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &failure) == nil && failure.Message != "" {
+			return fmt.Errorf("%w: %s", &httpStatusError{status: resp.StatusCode}, failure.Message)
+		}
+		return &httpStatusError{status: resp.StatusCode}
+	}
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("invalid Fireblocks response: %w", err)
+	}
+	return nil
+}
+
+// jwt returns the RS256 token Fireblocks requires on every call
+func (s *FireblocksSigner) jwt(path string, body []byte) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	bodyHash := sha256.Sum256(body)
+	now := time.Now().Unix()
+	claims, err := json.Marshal(map[string]interface{}{
+		"uri":      path,
+		"nonce":    hex.EncodeToString(nonce),
+		"iat":      now,
+		"exp":      now + 25, // Fireblocks accepts at most 30 seconds
+		"sub":      s.apiKey,
+		"bodyHash": hex.EncodeToString(bodyHash[:]),
+	})
+	if err != nil {
+		return "", err
+	}
+	encoding := base64.RawURLEncoding
+	unsigned := encoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`)) + "." + encoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.secret, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign the Fireblocks API token: %w", err)
+	}
+	return unsigned + "." + encoding.EncodeToString(signature), nil
+}
+
+// parseRSAKey parses a PEM RSA private key in the PKCS #8 or PKCS #1 form
+func parseRSAKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA key")
+	}
+	return key, nil
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/fatih/color"
+)
+
+// redelegation is a transaction that carried an authorization from the victim
+// to a contract after the victim's clear was mined
+type redelegation struct {
+	TxHash string         `json:"txHash"`
+	Block  uint64         `json:"block"`
+	From   string         `json:"from"`
+	Target common.Address `json:"target"`
+}
+
+// getBlockTransactions returns the transactions of a block in order, nil if the block does not exist yet
+func getBlockTransactions(ctx context.Context, rpcURL string, number uint64) ([]Transaction, error) {
+	body := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_getBlockByNumber",
+		"params":  []interface{}{fmt.Sprintf("0x%x", number), true},
+	}
+
+	responseBody, err := makeRPCCallContext(ctx, rpcURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Result *struct {
+			Transactions []Transaction `json:"transactions"`
+		} `json:"result"`
+	}
+
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		return nil, err
+	}
+	if result.Result == nil {
+		return nil, nil
+	}
+	return result.Result.Transactions, nil
+}
+
+// findRedelegations scans the block of the clear, after the clear itself, and
+// the following blocks up to last for authorizations signed by victim
+func findRedelegations(ctx context.Context, rpcURL string, victim common.Address, clearTxHash string, first, last uint64) ([]redelegation, error) {
+	var found []redelegation
+	for number := first; number <= last; number++ {
+		txs, err := getBlockTransactions(ctx, rpcURL, number)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read block %d: %w", number, err)
+		}
+
+		// In the clear's own block only what comes after the clear can undo it
+		afterClear := number != first
+		for _, tx := range txs {
+			if strings.EqualFold(tx.Hash, clearTxHash) {
+				afterClear = true
+				continue
+			}
+			if !afterClear {
+				continue
+			}
+			for _, auth := range tx.AuthorizationList {
+				authority, err := auth.Authority()
+				if err != nil || authority != victim || auth.Address == (common.Address{}) {
+					continue
+				}
+				found = append(found, redelegation{TxHash: tx.Hash, Block: number, From: tx.From, Target: auth.Address})
+			}
+		}
+	}
+	return found, nil
+}
+
+// reportFrontRun watches the blocks following a mined clear and reports, and
+// returns, any transaction that re-delegated the victim, which means an
+// attacker is racing the rescue
+func reportFrontRun(result *authResult, opts TxOptions) []redelegation {
+	if opts.FrontRunBlocks <= 0 || result.Receipt == nil {
+		return nil
+	}
+	rpcURL := opts.rpcURLOrDefault()
+	out := opts.console()
+
+	mined, ok := new(big.Int).SetString(strings.TrimPrefix(result.Receipt.BlockNumber, "0x"), 16)
+	if !ok {
+		return nil
+	}
+	first := mined.Uint64()
+	last := first + uint64(opts.FrontRunBlocks)
+
+	ctx, cancel := result.context()
+	defer cancel()
+	ctx, cancelWait := context.WithTimeout(ctx, opts.confirmTimeout())
+	defer cancelWait()
+
+	out.infof("\nWatching blocks %d to %d for a re-delegation of %s...\n", first, last, result.User.Hex())
+	reached := first
+wait:
+	for {
+		if head, err := getBlockNumber(ctx, rpcURL); err == nil && head.Uint64() > reached {
+			reached = head.Uint64()
+		}
+		if reached >= last {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			notice(color.FgYellow, "Stopped watching for a re-delegation before block %d was produced.", last)
+			last = reached
+			break wait
+		case <-time.After(opts.pollInterval()):
+		}
+	}
+
+	// The wait may have used up ctx, the scan itself gets a fresh per-call budget
+	found, err := findRedelegations(context.Background(), rpcURL, result.User, result.TxHash, first, last)
+	if err != nil {
+		notice(color.FgYellow, "Could not check for a re-delegation: %v", err)
+		return nil
+	}
+	if len(found) == 0 {
+		out.infof("No re-delegation of %s in blocks %d to %d.\n", result.User.Hex(), first, last)
+		return nil
+	}
+
+	notice(color.FgRed, "\n⚠ The clear was followed by a re-delegation of %s:", result.User.Hex())
+	for _, r := range found {
+		notice(color.FgRed, "  block %d: %s delegated it to %s (sent by %s)", r.Block, r.TxHash, r.Target.Hex(), r.From)
+	}
+	notice(color.FgRed, "Someone holding the key is racing your rescue and watching the public mempool.")
+	notice(color.FgYellow, "Check the current state with: eip7702cleaner verify %s --rpc-url %s", result.User.Hex(), rpcURL)
+	notice(color.FgYellow, "Then clear again, preferably through a private transaction relay (set --rpc-url to a private RPC endpoint) so the clear is not visible before it is mined.")
+	return found
+}
@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// Gas limit of an EIP-7702 transaction when none is given: a base covering the
+// 21000 intrinsic gas and the call, plus the EIP-7702 per-authorization cost
+// (PER_EMPTY_ACCOUNT_COST) for every authorization it carries
+const (
+	AuthorizationBaseGas uint64 = 75000
+	PerAuthorizationGas  uint64 = 25000
+)
+
+// AuthorizationGasLimit returns the default gas limit of a transaction carrying count authorizations
+func AuthorizationGasLimit(count int) uint64 {
+	if count < 1 {
+		count = 1
+	}
+	return AuthorizationBaseGas + PerAuthorizationGas*uint64(count)
+}
+
+// defaultGasLimit returns AuthorizationGasLimit, raised to the IntrinsicGas of
+// a transaction carrying data when that is higher, as with a large calldata
+func defaultGasLimit(count int, data []byte) uint64 {
+	return max(AuthorizationGasLimit(count), IntrinsicGas(count, data, nil))
+}
+
+// Intrinsic gas of a type 4 transaction, from EIP-2028, EIP-2930, EIP-7623 and
+// EIP-7702. PER_EMPTY_ACCOUNT_COST is charged up front for every authorization,
+// the difference with PER_AUTH_BASE_COST (12500) being refunded afterwards for
+// an account that already exists.
+const (
+	txBaseGas                 uint64 = 21000
+	txDataZeroGas             uint64 = 4
+	txDataNonZeroGas          uint64 = 16
+	txAccessListAddressGas    uint64 = 2400
+	txAccessListStorageKeyGas uint64 = 1900
+	txCostFloorPerToken       uint64 = 10
+	perEmptyAccountCost       uint64 = 25000
+)
+
+// IntrinsicGas returns the least gas limit a node accepts for a type 4
+// transaction with the given number of authorizations, calldata and access
+// list: the intrinsic cost, or the EIP-7623 calldata floor when higher. The
+// call itself may need more.
+func IntrinsicGas(authorizations int, data []byte, accessList []AccessTuple) uint64 {
+	var zeroBytes, nonZeroBytes uint64
+	for _, b := range data {
+		if b == 0 {
+			zeroBytes++
+		} else {
+			nonZeroBytes++
+		}
+	}
+	gas := txBaseGas + zeroBytes*txDataZeroGas + nonZeroBytes*txDataNonZeroGas
+	for _, tuple := range accessList {
+		gas += txAccessListAddressGas + txAccessListStorageKeyGas*uint64(len(tuple.StorageKeys))
+	}
+	gas += perEmptyAccountCost * uint64(authorizations)
+
+	floor := txBaseGas + txCostFloorPerToken*(zeroBytes+4*nonZeroBytes)
+	return max(gas, floor)
+}
+
+var (
+	weiPerGwei = new(big.Float).SetFloat64(1000000000)          // 1 Gwei = 10^9 Wei
+	weiPerEth  = new(big.Float).SetFloat64(1000000000000000000) // 1 ETH = 10^18 Wei
+)
+
+// weiToGwei converts a Wei amount to Gwei for display
+func weiToGwei(wei *big.Int) *big.Float {
+	return new(big.Float).Quo(new(big.Float).SetInt(wei), weiPerGwei)
+}
+
+// weiToEth converts a Wei amount to ETH for display
+func weiToEth(wei *big.Int) *big.Float {
+	return new(big.Float).Quo(new(big.Float).SetInt(wei), weiPerEth)
+}
+
+// gweiToWei parses a decimal Gwei amount (e.g. "1.5") into Wei
+func gweiToWei(gwei string) (*big.Int, error) {
+	value, ok := new(big.Float).SetString(gwei)
+	if !ok || value.Sign() < 0 {
+		return nil, invalidInput("invalid Gwei amount: %s", gwei)
+	}
+	wei, _ := value.Mul(value, weiPerGwei).Int(nil)
+	return wei, nil
+}
+
+// ParseEther parses a decimal ETH (or native token) amount (e.g. "0.01") into Wei
+func ParseEther(eth string) (*big.Int, error) {
+	value, ok := new(big.Float).SetString(eth)
+	if !ok || value.Sign() < 0 {
+		return nil, invalidInput("invalid amount: %s", eth)
+	}
+	wei, _ := value.Mul(value, weiPerEth).Int(nil)
+	return wei, nil
+}
+
+// ParseGwei parses a decimal Gwei amount (e.g. "1.5") into Wei
+func ParseGwei(gwei string) (*big.Int, error) {
+	return gweiToWei(gwei)
+}
+
+// maxGasCost returns the worst case cost of a transaction in Wei
+func maxGasCost(gasFeeCap *big.Int, gasLimit uint64) *big.Int {
+	return new(big.Int).Mul(gasFeeCap, new(big.Int).SetUint64(gasLimit))
+}
+
+// printGasInfo displays the fee parameters and the estimated maximum cost
+func printGasInfo(gasTip, gasFeeCap *big.Int, gasLimit uint64) {
+	fmt.Fprintf(promptOutput, "\nGas Information:\n")
+	fmt.Fprintf(promptOutput, "Max fee per gas: %.6f Gwei\n", weiToGwei(gasFeeCap))
+	fmt.Fprintf(promptOutput, "Priority fee: %.6f Gwei\n", weiToGwei(gasTip))
+	fmt.Fprintf(promptOutput, "Gas limit: %d\n", gasLimit)
+	fmt.Fprintf(promptOutput, "Estimated max gas cost: %.9f ETH\n", weiToEth(maxGasCost(gasFeeCap, gasLimit)))
+}
+
+// bumpFee multiplies a fee by the given factor
+func bumpFee(fee *big.Int, factor float64) *big.Int {
+	bumped, _ := new(big.Float).Mul(new(big.Float).SetInt(fee), big.NewFloat(factor)).Int(nil)
+	return bumped
+}
+
+// tuneGasInteractively lets the user keep the suggested fees, bump them by a
+// factor or enter custom values, re-displaying the estimated cost after each change
+func tuneGasInteractively(gasTip, gasFeeCap *big.Int, gasLimit uint64) (*big.Int, *big.Int, error) {
+	for {
+		fmt.Fprintln(promptOutput, "\nHow would you like to set the gas fees?")
+		fmt.Fprintln(promptOutput, "  1) Use the fees shown above")
+		fmt.Fprintln(promptOutput, "  2) Bump the fees by a factor")
+		fmt.Fprintln(promptOutput, "  3) Enter custom fees")
+
+		choice, err := readLine()
+		if err != nil {
+			return nil, nil, err
+		}
+		switch choice {
+		case "1", "":
+			return gasTip, gasFeeCap, nil
+		case "2":
+			fmt.Fprintln(promptOutput, "Enter the bump factor (e.g. 1.25 for +25%):")
+			input, err := readLine()
+			if err != nil {
+				return nil, nil, err
+			}
+			factor, err := strconv.ParseFloat(input, 64)
+			if err != nil || factor <= 0 {
+				fmt.Fprintln(promptOutput, "Invalid factor, it must be a positive number.")
+				continue
+			}
+			gasTip = bumpFee(gasTip, factor)
+			gasFeeCap = bumpFee(gasFeeCap, factor)
+		case "3":
+			tip, feeCap, err := readCustomFees()
+			if errors.Is(err, errPromptTimeout) {
+				return nil, nil, err
+			}
+			if err != nil {
+				fmt.Fprintf(promptOutput, "Invalid fees: %v\n", err)
+				continue
+			}
+			gasTip, gasFeeCap = tip, feeCap
+		default:
+			fmt.Fprintln(promptOutput, "Please choose 1, 2 or 3.")
+			continue
+		}
+
+		printGasInfo(gasTip, gasFeeCap, gasLimit)
+	}
+}
+
+// readCustomFees prompts for a priority fee and a max fee per gas in Gwei
+func readCustomFees() (*big.Int, *big.Int, error) {
+	fmt.Fprintln(promptOutput, "Enter the priority fee in Gwei:")
+	input, err := readLine()
+	if err != nil {
+		return nil, nil, err
+	}
+	gasTip, err := gweiToWei(input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fmt.Fprintln(promptOutput, "Enter the max fee per gas in Gwei:")
+	if input, err = readLine(); err != nil {
+		return nil, nil, err
+	}
+	gasFeeCap, err := gweiToWei(input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if gasFeeCap.Cmp(gasTip) < 0 {
+		return nil, nil, errors.New("max fee per gas must not be lower than the priority fee")
+	}
+	return gasTip, gasFeeCap, nil
+}
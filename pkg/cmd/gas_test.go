@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestIntrinsicGas(t *testing.T) {
+	tests := []struct {
+		name           string
+		authorizations int
+		data           []byte
+		accessList     []AccessTuple
+		want           uint64
+	}{
+		{"one authorization", 1, nil, nil, 46000},
+		{"two authorizations", 2, nil, nil, 71000},
+		{"zero and non-zero bytes", 1, []byte{0x00, 0x01}, nil, 46020},
+		{"calldata floor", 0, bytes.Repeat([]byte{0xff}, 1000), nil, 61000},
+		{"authorizations above the floor", 1, bytes.Repeat([]byte{0xff}, 1000), nil, 62000},
+		{"access list", 1, nil, []AccessTuple{{Address: common.HexToAddress("0x01"), StorageKeys: make([]common.Hash, 2)}}, 52200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IntrinsicGas(tt.authorizations, tt.data, tt.accessList); got != tt.want {
+				t.Fatalf("IntrinsicGas = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultGasLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		count int
+		data  []byte
+		want  uint64
+	}{
+		{"one authorization", 1, nil, 100000},
+		{"no authorization counts as one", 0, nil, 100000},
+		{"three authorizations", 3, nil, 150000},
+		{"small calldata", 1, []byte{0x12, 0x34}, 100000},
+		{"large calldata", 1, bytes.Repeat([]byte{0xff}, 4096), 184840},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := defaultGasLimit(tt.count, tt.data); got != tt.want {
+				t.Fatalf("defaultGasLimit = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizationGasLimit(t *testing.T) {
+	tests := []struct {
+		count int
+		want  uint64
+	}{
+		{-1, 100000},
+		{0, 100000},
+		{1, 100000},
+		{2, 125000},
+		{10, 325000},
+	}
+	for _, tt := range tests {
+		if got := AuthorizationGasLimit(tt.count); got != tt.want {
+			t.Errorf("AuthorizationGasLimit(%d) = %d, want %d", tt.count, got, tt.want)
+		}
+	}
+}
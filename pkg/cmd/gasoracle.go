@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Fee tiers of a gas oracle, selected with --priority
+const (
+	GasPriorityStandard = "standard"
+	GasPriorityFast     = "fast"
+	GasPriorityInstant  = "instant"
+)
+
+// maxOracleFeeFactor bounds the max fee per gas taken from an oracle to this
+// multiple of the node's suggestion, so a broken oracle cannot drain the relayer
+const maxOracleFeeFactor = 10
+
+// ParseGasPriority validates a --priority value, empty meaning GasPriorityStandard
+func ParseGasPriority(value string) (string, error) {
+	switch value {
+	case "":
+		return GasPriorityStandard, nil
+	case GasPriorityStandard, GasPriorityFast, GasPriorityInstant:
+		return value, nil
+	}
+	return "", invalidInput("invalid --priority value %q (expected standard, fast or instant)", value)
+}
+
+// gasOracleTier is one tier of a gas oracle response, in Gwei given as JSON
+// numbers or strings. Oracles answer with an object holding one such tier per
+// name, e.g. {"standard": {"maxPriorityFee": 1.5, "maxFee": 30}, "fast": ...},
+// the shape of the Polygon gas station.
+type gasOracleTier struct {
+	MaxPriorityFee json.RawMessage `json:"maxPriorityFee"`
+	MaxFee         json.RawMessage `json:"maxFee"`
+}
+
+// getOracleGasFees fetches the priority fee and max fee per gas of a tier from a gas oracle
+func getOracleGasFees(oracleURL, tier string) (*big.Int, *big.Int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, oracleURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// The URL may carry an API key, keep it out of the message
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, nil, fmt.Errorf("request to %s failed: %w", RedactURL(oracleURL), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, &httpStatusError{status: resp.StatusCode}
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var tiers map[string]gasOracleTier
+	if err := json.Unmarshal(body, &tiers); err != nil {
+		return nil, nil, fmt.Errorf("invalid oracle response: %w", err)
+	}
+	fees, ok := tiers[tier]
+	if !ok {
+		return nil, nil, fmt.Errorf("the oracle has no %q tier", tier)
+	}
+	gasTip, err := oracleGwei(fees.MaxPriorityFee)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid maxPriorityFee of the %s tier: %w", tier, err)
+	}
+	gasFeeCap, err := oracleGwei(fees.MaxFee)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid maxFee of the %s tier: %w", tier, err)
+	}
+	return gasTip, gasFeeCap, nil
+}
+
+// oracleGwei parses a Gwei amount given as a JSON number or string into Wei
+func oracleGwei(raw json.RawMessage) (*big.Int, error) {
+	value := strings.Trim(strings.TrimSpace(string(raw)), `"`)
+	if value == "" || value == "null" {
+		return nil, fmt.Errorf("missing")
+	}
+	return gweiToWei(value)
+}
+
+// checkOracleFees rejects oracle fees that are inconsistent, could not be
+// included with the next block's base fee, or are far above the node's suggestion
+func checkOracleFees(rpcURL string, gasTip, gasFeeCap, nodeFeeCap *big.Int) error {
+	switch {
+	case gasFeeCap.Sign() <= 0:
+		return fmt.Errorf("max fee of %.6f Gwei is not positive", weiToGwei(gasFeeCap))
+	case gasTip.Cmp(gasFeeCap) > 0:
+		return fmt.Errorf("priority fee of %.6f Gwei is above the max fee of %.6f Gwei", weiToGwei(gasTip), weiToGwei(gasFeeCap))
+	case gasFeeCap.Cmp(new(big.Int).Mul(nodeFeeCap, big.NewInt(maxOracleFeeFactor))) > 0:
+		return fmt.Errorf("max fee of %.6f Gwei is more than %d times the node's %.6f Gwei", weiToGwei(gasFeeCap), maxOracleFeeFactor, weiToGwei(nodeFeeCap))
+	}
+	if history, err := getFeeHistory(rpcURL, 1); err == nil {
+		if baseFee := history.BaseFees[len(history.BaseFees)-1]; gasFeeCap.Cmp(baseFee) < 0 {
+			return fmt.Errorf("max fee of %.6f Gwei is below the next base fee of %.6f Gwei", weiToGwei(gasFeeCap), weiToGwei(baseFee))
+		}
+	}
+	return nil
+}
+
+// gasFees returns the fees of a new transaction: GasTip and GasFeeCap when
+// pinned, those of the selected tier of GasOracleURL when set and sane, the
+// node's suggestion otherwise. The chain's minimum priority fee applies to the
+// last two.
+func (o TxOptions) gasFees(chainID *big.Int) (*big.Int, *big.Int, error) {
+	if o.feesPinned() {
+		return o.pinnedGasFees(chainID)
+	}
+	rpcURL := o.rpcURLOrDefault()
+	gasTip, gasFeeCap, err := networkGasFees(rpcURL, chainID)
+	if err != nil || o.GasOracleURL == "" {
+		return gasTip, gasFeeCap, err
+	}
+
+	tier, err := ParseGasPriority(o.GasPriority)
+	if err != nil {
+		return nil, nil, err
+	}
+	oracleTip, oracleFeeCap, err := getOracleGasFees(o.GasOracleURL, tier)
+	if err == nil {
+		oracleTip, oracleFeeCap = applyMinPriorityFee(chainID, oracleTip, oracleFeeCap)
+		err = checkOracleFees(rpcURL, oracleTip, oracleFeeCap, gasFeeCap)
+	}
+	if err != nil {
+		notice(color.FgYellow, "Gas oracle not used (%v), falling back to the node's suggestion", err)
+		return gasTip, gasFeeCap, nil
+	}
+	fmt.Fprintf(promptOutput, "Gas fees from the %s tier of the gas oracle\n", tier)
+	return oracleTip, oracleFeeCap, nil
+}
+
+// feesPinned reports whether a fee per gas was given instead of being fetched
+func (o TxOptions) feesPinned() bool {
+	return o.GasTip != nil || o.GasFeeCap != nil
+}
+
+// pinnedGasFees returns GasTip and GasFeeCap, derived from the node's
+// suggestion for the one not given: a pinned tip keeps the suggested headroom
+// for the base fee on top of it, and a pinned max fee caps the suggested tip
+func (o TxOptions) pinnedGasFees(chainID *big.Int) (*big.Int, *big.Int, error) {
+	gasTip, gasFeeCap := o.GasTip, o.GasFeeCap
+	if gasTip == nil || gasFeeCap == nil {
+		suggestedTip, suggestedFeeCap, err := networkGasFees(o.rpcURLOrDefault(), chainID)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case gasTip == nil && suggestedTip.Cmp(gasFeeCap) > 0:
+			gasTip = new(big.Int).Set(gasFeeCap)
+		case gasTip == nil:
+			gasTip = suggestedTip
+		default:
+			gasFeeCap = new(big.Int).Add(new(big.Int).Sub(suggestedFeeCap, suggestedTip), gasTip)
+		}
+	}
+	if gasFeeCap.Cmp(gasTip) < 0 {
+		return nil, nil, invalidInput("max fee of %.6f Gwei is below the priority fee of %.6f Gwei", weiToGwei(gasFeeCap), weiToGwei(gasTip))
+	}
+	return gasTip, gasFeeCap, nil
+}
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/fatih/color"
+)
+
+// delegationChange is a block in which the delegation of an account changed
+type delegationChange struct {
+	Block  uint64
+	Before *CheckResult // State at the end of the previous block
+	After  *CheckResult // State at the end of this block
+	TxHash string       // Transaction carrying the authorization, empty if not found
+}
+
+// describe summarizes the change for the history table
+func (c delegationChange) describe() string {
+	switch c.After.Status {
+	case StatusDelegated:
+		if c.Before.Status == StatusDelegated {
+			return fmt.Sprintf("re-set from %s to %s", c.Before.Delegate.Hex(), c.After.Delegate.Hex())
+		}
+		return fmt.Sprintf("set to %s", c.After.Delegate.Hex())
+	case StatusClean:
+		return fmt.Sprintf("cleared (was %s)", c.Before.Delegate.Hex())
+	default:
+		return "code that is not an EIP-7702 delegation"
+	}
+}
+
+// historyScanner reads an account's historical state, which needs an archive node
+type historyScanner struct {
+	ctx     context.Context
+	rpcURL  string
+	address common.Address
+	calls   int
+}
+
+// stateAt calls an account state method (eth_getCode, eth_getTransactionCount) at a block
+func (s *historyScanner) stateAt(method string, block uint64) (string, error) {
+	s.calls++
+	body := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  method,
+		"params":  []interface{}{s.address.Hex(), fmt.Sprintf("0x%x", block)},
+	}
+
+	responseBody, err := makeRPCCallContext(s.ctx, s.rpcURL, body)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Result *string `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		return "", err
+	}
+	if result.Error != nil {
+		return "", fmt.Errorf("%s at block %d failed (historical state needs an archive node): %s", method, block, result.Error.Message)
+	}
+	if result.Result == nil {
+		return "", fmt.Errorf("%s at block %d returned no result", method, block)
+	}
+	return *result.Result, nil
+}
+
+// nonceAt returns the account nonce at the end of a block
+func (s *historyScanner) nonceAt(block uint64) (uint64, error) {
+	result, err := s.stateAt("eth_getTransactionCount", block)
+	if err != nil {
+		return 0, err
+	}
+	nonce, ok := new(big.Int).SetString(strings.TrimPrefix(result, "0x"), 16)
+	if !ok {
+		return 0, fmt.Errorf("invalid nonce %q", result)
+	}
+	return nonce.Uint64(), nil
+}
+
+// codeAt returns the delegation state of the account at the end of a block
+func (s *historyScanner) codeAt(block uint64) (*CheckResult, error) {
+	result, err := s.stateAt("eth_getCode", block)
+	if err != nil {
+		return nil, err
+	}
+	code, err := hex.DecodeString(strings.TrimPrefix(result, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid code at block %d: %w", block, err)
+	}
+	return ParseDelegation(s.address, code), nil
+}
+
+// nonceBlocks calls visit, in order, with every block in (lo, hi] at the end
+// of which the nonce is higher than at the end of the block before. Every
+// applied authorization increments the authority's nonce, so these blocks
+// include all the delegation changes. Ranges with an unchanged nonce are skipped
+// whole, which keeps the number of calls proportional to the account's activity.
+func (s *historyScanner) nonceBlocks(lo, hi, nonceLo, nonceHi uint64, visit func(block uint64) error) error {
+	if nonceLo == nonceHi {
+		return nil
+	}
+	if hi == lo+1 {
+		return visit(hi)
+	}
+	mid := lo + (hi-lo)/2
+	nonceMid, err := s.nonceAt(mid)
+	if err != nil {
+		return err
+	}
+	if err := s.nonceBlocks(lo, mid, nonceLo, nonceMid, visit); err != nil {
+		return err
+	}
+	return s.nonceBlocks(mid, hi, nonceMid, nonceHi, visit)
+}
+
+// authorizationTx finds the transaction of a block that carried an authorization signed by the account
+func (s *historyScanner) authorizationTx(block uint64, target common.Address) string {
+	txs, err := getBlockTransactions(s.ctx, s.rpcURL, block)
+	if err != nil {
+		return ""
+	}
+	// The last matching authorization of the block is the one that took effect
+	var found string
+	for _, tx := range txs {
+		for _, auth := range tx.AuthorizationList {
+			if authority, err := auth.Authority(); err == nil && authority == s.address && auth.Address == target {
+				found = tx.Hash
+			}
+		}
+	}
+	return found
+}
+
+// delegationHistory reconstructs the delegation changes of an account between two blocks
+func delegationHistory(ctx context.Context, rpcURL string, address common.Address, from, to uint64) ([]delegationChange, int, error) {
+	s := &historyScanner{ctx: ctx, rpcURL: rpcURL, address: address}
+
+	nonceFrom, err := s.nonceAt(from)
+	if err != nil {
+		return nil, s.calls, err
+	}
+	nonceTo, err := s.nonceAt(to)
+	if err != nil {
+		return nil, s.calls, err
+	}
+	state, err := s.codeAt(from)
+	if err != nil {
+		return nil, s.calls, err
+	}
+
+	var changes []delegationChange
+	err = s.nonceBlocks(from, to, nonceFrom, nonceTo, func(block uint64) error {
+		after, err := s.codeAt(block)
+		if err != nil {
+			return err
+		}
+		if string(after.Code) != string(state.Code) {
+			change := delegationChange{Block: block, Before: state, After: after}
+			change.TxHash = s.authorizationTx(block, after.Delegate)
+			changes = append(changes, change)
+		}
+		state = after
+		return nil
+	})
+	return changes, s.calls, err
+}
+
+// AuditHistory prints the chronological timeline of the delegation changes of
+// an address between fromBlock and toBlock (0 for the latest block). It reads
+// historical state, so the RPC endpoint must be an archive node.
+func AuditHistory(address string, fromBlock, toBlock uint64, opts CheckOptions) error {
+	if !common.IsHexAddress(address) {
+		return invalidInput("invalid Ethereum address format: %s", address)
+	}
+	account := common.HexToAddress(address)
+	rpcURL := opts.rpcURLOrDefault()
+	ctx := context.Background()
+
+	if toBlock == 0 {
+		head, err := getBlockNumber(ctx, rpcURL)
+		if err != nil {
+			return fmt.Errorf("failed to get the latest block: %w", err)
+		}
+		toBlock = head.Uint64()
+	}
+	if fromBlock >= toBlock {
+		return invalidInput("--from-block %d must be lower than --to-block %d", fromBlock, toBlock)
+	}
+
+	notice(color.FgCyan, "Delegation history of %s, blocks %d to %d", account.Hex(), fromBlock, toBlock)
+	changes, calls, err := delegationHistory(ctx, rpcURL, account, fromBlock, toBlock)
+	if err != nil {
+		return fmt.Errorf("history scan failed after %d calls: %w", calls, err)
+	}
+	if opts.Debug {
+		fmt.Fprintf(promptOutput, "Debug - History scan used %d state calls\n", calls)
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("No delegation changes in this range.")
+		return nil
+	}
+	fmt.Printf("%-10s  %-66s  %s\n", "Block", "Transaction", "Change")
+	for _, change := range changes {
+		txHash := change.TxHash
+		if txHash == "" {
+			txHash = "(not found)"
+		}
+		fmt.Printf("%-10d  %-66s  %s\n", change.Block, txHash, change.describe())
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/fatih/color"
+)
+
+// maxKeystoreSize bounds the keystore files read, real ones are well below 1 KiB
+const maxKeystoreSize = 64 << 10
+
+// maxKeyFileSize bounds the hex key files read, a key with its 0x prefix and a
+// trailing newline being 67 bytes
+const maxKeyFileSize = 1 << 10
+
+// keySource is where a private key is loaded from
+type keySource interface {
+	// load returns the key, prompting the user as needed; prompt introduces a hex key entry
+	load(c color.Attribute, prompt string) (*ecdsa.PrivateKey, error)
+}
+
+// userKeySource returns where the account key comes from: an environment
+// variable, a key file, a keystore file, a mnemonic, or else the prompt
+func (o TxOptions) userKeySource() keySource {
+	switch {
+	case o.KeyEnv != "":
+		return envKey{name: o.KeyEnv}
+	case o.KeyFile != "":
+		return hexKeyFile{path: o.KeyFile}
+	case o.Keystore != "":
+		return keystoreFile{path: o.Keystore}
+	case o.Mnemonic:
+		return mnemonicKey{path: o.HDPath}
+	}
+	return promptedKey{}
+}
+
+// relayerKeySource returns where a relayer key loaded in memory comes from,
+// the remote signers being set up by readRelayer
+func (o TxOptions) relayerKeySource() keySource {
+	switch {
+	case o.RelayerKeyEnv != "":
+		return envKey{name: o.RelayerKeyEnv}
+	case o.RelayerVaultPath != "":
+		return vaultSecret{path: o.RelayerVaultPath, field: o.RelayerVaultField}
+	case o.RelayerKeystore != "":
+		return keystoreFile{path: o.RelayerKeystore}
+	case o.RelayerMnemonic:
+		return mnemonicKey{path: o.RelayerHDPath}
+	}
+	return promptedKey{}
+}
+
+// promptedKey is a hex private key typed without echo
+type promptedKey struct{}
+
+func (promptedKey) load(c color.Attribute, prompt string) (*ecdsa.PrivateKey, error) {
+	notice(c, "%s", prompt)
+	return readPrivateKey()
+}
+
+// keystoreFile is a Web3 Secret Storage (UTC/JSON V3) file as exported by geth
+// or MetaMask, for which only the passphrase is prompted for
+type keystoreFile struct {
+	path string
+}
+
+func (k keystoreFile) load(c color.Attribute, prompt string) (*ecdsa.PrivateKey, error) {
+	file, err := os.Open(k.path)
+	if err != nil {
+		return nil, invalidInput("failed to open keystore: %v", err)
+	}
+	defer file.Close()
+	keyJSON, err := io.ReadAll(io.LimitReader(file, maxKeystoreSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore: %w", err)
+	}
+	var header struct {
+		Address string `json:"address"`
+	}
+	if err := json.Unmarshal(keyJSON, &header); err != nil {
+		return nil, invalidInput("%s is not a JSON keystore file: %v", k.path, err)
+	}
+
+	account := k.path
+	if common.IsHexAddress(header.Address) {
+		account = fmt.Sprintf("%s (%s)", common.HexToAddress(header.Address).Hex(), k.path)
+	}
+	notice(c, "Please enter the passphrase of the keystore of %s:", account)
+	passphrase, err := readInput(readSecret)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(passphrase)
+
+	fmt.Fprintln(promptOutput, "Decrypting the keystore...")
+	key, err := keystore.DecryptKey(keyJSON, string(passphrase))
+	if err != nil {
+		return nil, invalidInput("failed to decrypt keystore %s: %v", k.path, err)
+	}
+	return lockKey(key.PrivateKey), nil
+}
+
+// envKey is a hex private key in an environment variable, for unattended runs.
+// The variable is unset once read so that child processes do not inherit it; the
+// copy Go made of the environment at start-up cannot be wiped.
+type envKey struct {
+	name string
+}
+
+func (e envKey) load(c color.Attribute, prompt string) (*ecdsa.PrivateKey, error) {
+	value, ok := os.LookupEnv(e.name)
+	if !ok || value == "" {
+		return nil, invalidInput("environment variable %s is not set, or was already read for the other key", e.name)
+	}
+	os.Unsetenv(e.name)
+	input := []byte(value)
+	defer zeroBytes(input)
+	key, err := parseHexKey(input)
+	if err != nil {
+		return nil, fmt.Errorf("environment variable %s: %w", e.name, err)
+	}
+	fmt.Fprintf(promptOutput, "Using the key of %s from environment variable %s\n", crypto.PubkeyToAddress(key.PublicKey).Hex(), e.name)
+	return key, nil
+}
+
+// hexKeyFile is a file holding a hex private key, for unattended runs
+type hexKeyFile struct {
+	path string
+}
+
+func (k hexKeyFile) load(c color.Attribute, prompt string) (*ecdsa.PrivateKey, error) {
+	file, err := os.Open(k.path)
+	if err != nil {
+		return nil, invalidInput("failed to open key file: %v", err)
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err == nil && runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		notice(color.FgYellow, "Warning: key file %s is readable by other users (mode %04o), restrict it with chmod 600", k.path, info.Mode().Perm())
+	}
+	input, err := io.ReadAll(io.LimitReader(file, maxKeyFileSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	defer zeroBytes(input)
+	key, err := parseHexKey(input)
+	if err != nil {
+		return nil, fmt.Errorf("key file %s: %w", k.path, err)
+	}
+	fmt.Fprintf(promptOutput, "Using the key of %s from %s\n", crypto.PubkeyToAddress(key.PublicKey).Hex(), k.path)
+	return key, nil
+}
@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/fatih/color"
+	"github.com/karalabe/hid"
+)
+
+// ledgerVendorID is the USB vendor ID of Ledger devices
+const ledgerVendorID = 0x2c97
+
+// APDU instructions of the Ledger Ethereum app
+const (
+	ledgerInsGetAddress      = 0x02
+	ledgerInsSignTransaction = 0x04
+	ledgerP1FirstChunk       = 0x00
+	ledgerP1NextChunk        = 0x80
+	ledgerP1SilentAddress    = 0x00 // Return the address without showing it
+	ledgerP1ShowAddress      = 0x01 // Show the address on the device and wait for its approval
+	ledgerMaxChunk           = 255  // APDU payloads carry at most 255 bytes
+)
+
+// ledgerStatusErrors explains the status words the Ethereum app answers with
+var ledgerStatusErrors = map[uint16]string{
+	0x5515: "the Ledger is locked, unlock it and retry",
+	0x6985: "the request was rejected on the Ledger",
+	0x6a80: "the Ethereum app cannot parse the transaction, update it to a version supporting EIP-7702 (type 4) transactions",
+	0x6d00: "the Ethereum app is not open on the Ledger",
+	0x6e00: "the Ethereum app is not open on the Ledger",
+	0x6511: "the Ethereum app is not open on the Ledger",
+}
+
+// LedgerSigner is a Signer backed by the Ethereum app of a Ledger connected
+// over USB. The device shows the transaction and the user approves it there, so
+// it signs whole transactions with SignPayload and refuses raw digests.
+type LedgerSigner struct {
+	mu      sync.Mutex
+	device  hid.Device
+	path    []uint32
+	address common.Address
+}
+
+// OpenLedgerSigner opens the first Ledger connected over USB and reads the
+// account at the BIP-32 derivation path, DefaultDerivationPath when empty. The
+// address is shown on the device and must be approved there, so the account is
+// checked on a screen the computer cannot tamper with. The Ethereum app must be
+// open on the device. Close releases the device.
+func OpenLedgerSigner(derivationPath string) (*LedgerSigner, error) {
+	if derivationPath == "" {
+		derivationPath = DefaultDerivationPath
+	}
+	path, err := parseDerivationPath(derivationPath)
+	if err != nil {
+		return nil, err
+	}
+	if !hid.Supported() {
+		return nil, errors.New("this build has no USB support, build eip7702cleaner with cgo enabled on the host to use a Ledger")
+	}
+	infos, err := hid.Enumerate(ledgerVendorID, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list USB devices: %w", err)
+	}
+	var device hid.Device
+	for _, info := range infos {
+		// Ledgers expose several interfaces, the app talks on the first one
+		if info.UsagePage != 0xffa0 && info.Interface != 0 {
+			continue
+		}
+		if device, err = info.Open(); err != nil {
+			return nil, fmt.Errorf("failed to open the Ledger: %w", err)
+		}
+		break
+	}
+	if device == nil {
+		return nil, errors.New("no Ledger found, connect and unlock it, then open the Ethereum app")
+	}
+
+	signer := &LedgerSigner{device: device, path: path}
+	if signer.address, err = signer.readAddress(ledgerP1SilentAddress); err != nil {
+		device.Close()
+		return nil, err
+	}
+	notice(color.FgCyan, "Ledger account %s at %s, check that the device shows the same address and approve it...", signer.address.Hex(), derivationPath)
+	if _, err := signer.readAddress(ledgerP1ShowAddress); err != nil {
+		device.Close()
+		return nil, err
+	}
+	return signer, nil
+}
+
+// readAddress reads the address of the account, p1 choosing whether the device
+// shows it for approval, and checks it is the one read before if any
+func (s *LedgerSigner) readAddress(p1 byte) (common.Address, error) {
+	reply, err := s.exchange(ledgerInsGetAddress, p1, ledgerPathBytes(s.path))
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to read the Ledger account: %w", err)
+	}
+	// Reply: public key length, public key, address length, address as hex text
+	if len(reply) < 1 || len(reply) < 1+int(reply[0]) {
+		return common.Address{}, errors.New("the Ledger returned a malformed address")
+	}
+	pub, err := crypto.UnmarshalPubkey(reply[1 : 1+int(reply[0])])
+	if err != nil {
+		return common.Address{}, fmt.Errorf("the Ledger returned an invalid public key: %w", err)
+	}
+	address := crypto.PubkeyToAddress(*pub)
+	if s.address != (common.Address{}) && address != s.address {
+		return common.Address{}, fmt.Errorf("the Ledger returned %s, then %s", s.address.Hex(), address.Hex())
+	}
+	return address, nil
+}
+
+// Address returns the Ledger account
+func (s *LedgerSigner) Address() common.Address {
+	return s.address
+}
+
+// SignHash always fails: the Ethereum app only signs data it can show
+func (s *LedgerSigner) SignHash(hash []byte) ([]byte, error) {
+	return nil, errors.New("a Ledger signs transactions, not raw digests")
+}
+
+// SignPayload has the Ledger sign an EIP-2718 signing payload, the type byte
+// followed by the RLP of the unsigned transaction, once approved on the device.
+// The signature is checked to recover to the Ledger account.
+func (s *LedgerSigner) SignPayload(payload []byte) ([]byte, error) {
+	notice(color.FgCyan, "Review and approve the transaction on the Ledger...")
+	data := append(ledgerPathBytes(s.path), payload...)
+	var reply []byte
+	for p1 := byte(ledgerP1FirstChunk); len(data) > 0; p1 = ledgerP1NextChunk {
+		chunk := min(len(data), ledgerMaxChunk)
+		var err error
+		if reply, err = s.exchange(ledgerInsSignTransaction, p1, data[:chunk]); err != nil {
+			return nil, err
+		}
+		data = data[chunk:]
+	}
+	if len(reply) != crypto.SignatureLength {
+		return nil, fmt.Errorf("the Ledger returned a %d-byte signature", len(reply))
+	}
+	// The app answers [V || R || S], typed transactions having V 0 or 1
+	sig := append(reply[1:], reply[0])
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pub, err := crypto.SigToPub(crypto.Keccak256(payload), sig)
+	if err != nil {
+		return nil, fmt.Errorf("the Ledger returned an unrecoverable signature: %w", err)
+	}
+	if signer := crypto.PubkeyToAddress(*pub); signer != s.address {
+		return nil, fmt.Errorf("the Ledger signature recovers to %s instead of %s", signer.Hex(), s.address.Hex())
+	}
+	return sig, nil
+}
+
+// Close releases the device
+func (s *LedgerSigner) Close() error {
+	return s.device.Close()
+}
+
+// ledgerPathBytes encodes a derivation path the way the Ethereum app expects it
+func ledgerPathBytes(path []uint32) []byte {
+	encoded := []byte{byte(len(path))}
+	for _, index := range path {
+		encoded = binary.BigEndian.AppendUint32(encoded, index)
+	}
+	return encoded
+}
+
+// exchange sends an APDU to the Ethereum app and returns its answer without the
+// status word. APDUs are framed into 64-byte HID reports on channel 0x0101, the
+// first report carrying the APDU length.
+func (s *LedgerSigner) exchange(ins, p1 byte, data []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	apdu := binary.BigEndian.AppendUint16(nil, uint16(5+len(data)))
+	apdu = append(apdu, 0xe0, ins, p1, 0x00, byte(len(data)))
+	apdu = append(apdu, data...)
+	for seq := uint16(0); len(apdu) > 0; seq++ {
+		report := binary.BigEndian.AppendUint16([]byte{0x01, 0x01, 0x05}, seq)
+		n := min(len(apdu), 64-len(report))
+		report = append(report, apdu[:n]...)
+		apdu = apdu[n:]
+		if _, err := s.device.Write(report); err != nil {
+			return nil, fmt.Errorf("failed to write to the Ledger: %w", err)
+		}
+	}
+
+	var reply []byte
+	size := -1
+	report := make([]byte, 64)
+	for size < 0 || len(reply) < size {
+		if _, err := io.ReadFull(s.device, report); err != nil {
+			return nil, fmt.Errorf("failed to read from the Ledger: %w", err)
+		}
+		if report[0] != 0x01 || report[1] != 0x01 || report[2] != 0x05 {
+			return nil, fmt.Errorf("unexpected report from the Ledger: %s", hex.EncodeToString(report[:5]))
+		}
+		chunk := report[5:]
+		if size < 0 {
+			size = int(binary.BigEndian.Uint16(chunk))
+			chunk = chunk[2:]
+		}
+		reply = append(reply, chunk...)
+	}
+	reply = reply[:size]
+	if len(reply) < 2 {
+		return nil, errors.New("the Ledger returned an empty answer")
+	}
+	status := binary.BigEndian.Uint16(reply[len(reply)-2:])
+	if status != 0x9000 {
+		if reason, ok := ledgerStatusErrors[status]; ok {
+			return nil, errors.New(reason)
+		}
+		return nil, fmt.Errorf("the Ledger answered with status 0x%04x", status)
+	}
+	return reply[:len(reply)-2], nil
+}
@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/fatih/color"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+)
+
+// DefaultDerivationPath is the BIP-44 path of the first Ethereum account, as used by MetaMask and most wallets
+const DefaultDerivationPath = "m/44'/60'/0'/0/0"
+
+// hardenedOffset marks a hardened BIP-32 child index
+const hardenedOffset = 1 << 31
+
+// parseDerivationPath parses a BIP-32 path such as m/44'/60'/0'/0/0, hardened
+// indexes being marked with ' or h
+func parseDerivationPath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if len(parts) < 2 || parts[0] != "m" {
+		return nil, invalidInput("invalid derivation path %q: expected m/ followed by indexes, e.g. %s", path, DefaultDerivationPath)
+	}
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h") || strings.HasSuffix(part, "H")
+		if hardened {
+			part = part[:len(part)-1]
+		}
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || index >= hardenedOffset {
+			return nil, invalidInput("invalid derivation path %q: bad index %q", path, part)
+		}
+		if hardened {
+			index += hardenedOffset
+		}
+		indexes = append(indexes, uint32(index))
+	}
+	return indexes, nil
+}
+
+// bip39English is the BIP-39 English word list, one word per line in index order
+//
+//go:embed bip39_english.txt
+var bip39English string
+
+// bip39Words maps each word of the BIP-39 English word list to its index
+var bip39Words = func() map[string]int {
+	words := strings.Fields(bip39English)
+	indexes := make(map[string]int, len(words))
+	for i, word := range words {
+		indexes[word] = i
+	}
+	return indexes
+}()
+
+// checkMnemonic checks the words against the BIP-39 English word list and the
+// checksum they end with, so a mistyped word is refused instead of deriving a
+// valid but different wallet. The words are never echoed in errors.
+func checkMnemonic(words []string) error {
+	switch len(words) {
+	case 12, 15, 18, 21, 24:
+	default:
+		return invalidInput("invalid mnemonic: expected 12, 15, 18, 21 or 24 words, got %d", len(words))
+	}
+	// Each word carries 11 bits: the entropy, then one checksum bit per 32 bits of it
+	bits := new(big.Int)
+	for i, word := range words {
+		index, ok := bip39Words[word]
+		if !ok {
+			return invalidInput("invalid mnemonic: word #%d is not in the BIP-39 English word list", i+1)
+		}
+		bits.Lsh(bits, 11).Or(bits, big.NewInt(int64(index)))
+	}
+	checksumBits := uint(len(words) * 11 / 33)
+	checksum := new(big.Int).And(bits, big.NewInt(1<<checksumBits-1))
+	entropy := bits.Rsh(bits, checksumBits).FillBytes(make([]byte, checksumBits*4))
+	defer zeroBytes(entropy)
+	defer bits.SetInt64(0)
+
+	hash := sha256.Sum256(entropy)
+	if uint64(hash[0]>>(8-checksumBits)) != checksum.Uint64() {
+		return invalidInput("invalid mnemonic: the checksum does not match, a word is mistyped or out of order")
+	}
+	return nil
+}
+
+// mnemonicSeed turns a BIP-39 mnemonic and optional passphrase into the
+// 64-byte seed, once the words are checked by checkMnemonic
+func mnemonicSeed(mnemonic, passphrase []byte) ([]byte, error) {
+	words := strings.Fields(string(mnemonic))
+	if err := checkMnemonic(words); err != nil {
+		return nil, err
+	}
+	phrase := norm.NFKD.Bytes([]byte(strings.Join(words, " ")))
+	defer zeroBytes(phrase)
+	salt := append([]byte("mnemonic"), norm.NFKD.Bytes(passphrase)...)
+	defer zeroBytes(salt)
+	return pbkdf2.Key(phrase, salt, 2048, 64, sha512.New), nil
+}
+
+// deriveKey derives the private key at a BIP-32 path from a seed
+func deriveKey(seed []byte, path []uint32) (*ecdsa.PrivateKey, error) {
+	n := crypto.S256().Params().N
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	defer zeroBytes(sum)
+	key, chainCode := new(big.Int).SetBytes(sum[:32]), append([]byte(nil), sum[32:]...)
+	defer zeroBytes(chainCode)
+	if key.Sign() == 0 || key.Cmp(n) >= 0 {
+		return nil, errors.New("invalid master key, use another mnemonic")
+	}
+
+	for _, index := range path {
+		var data []byte
+		if index >= hardenedOffset {
+			data = append([]byte{0}, paddedKey(key)...)
+		} else {
+			privateKey, err := crypto.ToECDSA(paddedKey(key))
+			if err != nil {
+				return nil, err
+			}
+			data = crypto.CompressPubkey(&privateKey.PublicKey)
+		}
+		data = binary.BigEndian.AppendUint32(data, index)
+
+		mac := hmac.New(sha512.New, chainCode)
+		mac.Write(data)
+		zeroBytes(data)
+		sum := mac.Sum(nil)
+		tweak := new(big.Int).SetBytes(sum[:32])
+		if tweak.Cmp(n) >= 0 {
+			return nil, fmt.Errorf("invalid child key at index %d, use another path", index)
+		}
+		key.Add(key, tweak).Mod(key, n)
+		if key.Sign() == 0 {
+			return nil, fmt.Errorf("invalid child key at index %d, use another path", index)
+		}
+		copy(chainCode, sum[32:])
+		zeroBytes(sum)
+	}
+
+	keyBytes := paddedKey(key)
+	defer zeroBytes(keyBytes)
+	key.SetInt64(0)
+	privateKey, err := crypto.ToECDSA(keyBytes)
+	if err != nil {
+		return nil, err
+	}
+	return lockKey(privateKey), nil
+}
+
+// paddedKey returns a key as 32 big-endian bytes
+func paddedKey(key *big.Int) []byte {
+	return key.FillBytes(make([]byte, 32))
+}
+
+// mnemonicKey is a key derived from a BIP-39 mnemonic typed without echo, with
+// an optional BIP-39 passphrase, at a BIP-32 derivation path
+type mnemonicKey struct {
+	path string
+}
+
+func (m mnemonicKey) load(c color.Attribute, prompt string) (*ecdsa.PrivateKey, error) {
+	if m.path == "" {
+		m.path = DefaultDerivationPath
+	}
+	path, err := parseDerivationPath(m.path)
+	if err != nil {
+		return nil, err
+	}
+	notice(c, "%s", strings.Replace(prompt, "private key", "mnemonic phrase", 1))
+	mnemonic, err := readInput(readSecret)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(mnemonic)
+	fmt.Fprintln(promptOutput, "BIP-39 passphrase (press Enter if none):")
+	passphrase, err := readInput(readSecret)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(passphrase)
+
+	seed, err := mnemonicSeed(bytes.ToLower(bytes.TrimSpace(mnemonic)), bytes.TrimRight(passphrase, "\r\n"))
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(seed)
+	key, err := deriveKey(seed, path)
+	if err != nil {
+		return nil, err
+	}
+	// A valid phrase or path may still be the wrong one: show which account it gives
+	fmt.Fprintf(promptOutput, "Derived %s at %s; check that it is the expected account\n", crypto.PubkeyToAddress(key.PublicKey).Hex(), m.path)
+	return key, nil
+}
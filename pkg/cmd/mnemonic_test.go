@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// BIP-39 test vectors, from the reference implementation with passphrase TREZOR
+var bip39Vectors = []struct {
+	mnemonic string
+	seed     string
+}{
+	{
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+	},
+	{
+		"legal winner thank year wave sausage worth useful legal winner thank yellow",
+		"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+	},
+	{
+		"letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+		"d71de856f81a8acc65e6fc851a38d4d7ec216fd0796d0a6827a3ad6ed5511a30fa280f12eb2e47ed2ac03b5c462a0358d18d69fe4f985ec81778c1b370b652a8",
+	},
+	{
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+		"ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+	},
+}
+
+func TestMnemonicSeed(t *testing.T) {
+	for _, v := range bip39Vectors {
+		seed, err := mnemonicSeed([]byte(v.mnemonic), []byte("TREZOR"))
+		if err != nil {
+			t.Fatalf("%q: %v", v.mnemonic, err)
+		}
+		if got := hex.EncodeToString(seed); got != v.seed {
+			t.Errorf("%q: seed %s, want %s", v.mnemonic, got, v.seed)
+		}
+	}
+}
+
+func TestMnemonicSeedRejectsTypos(t *testing.T) {
+	tests := []struct {
+		name     string
+		mnemonic string
+		err      string
+	}{
+		{"unknown word", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandonn about", "word #11 is not in the BIP-39 English word list"},
+		{"bad checksum", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon", "the checksum does not match"},
+		{"swapped words", "legal winner thank year wave sausage worth useful legal winner yellow thank", "the checksum does not match"},
+		{"word count", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "expected 12, 15, 18, 21 or 24 words, got 11"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := mnemonicSeed([]byte(tt.mnemonic), nil)
+			var typed *TypedError
+			if err == nil || !strings.Contains(err.Error(), tt.err) || !errors.As(err, &typed) || typed.Type != ErrorTypeInvalidInput {
+				t.Fatalf("error = %v, want an input error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	tests := []struct {
+		mnemonic string
+		path     string
+		address  string
+	}{
+		{"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", DefaultDerivationPath, "0x9858EfFD232B4033E47d90003D41EC34EcaEda94"},
+		{"test test test test test test test test test test test junk", DefaultDerivationPath, "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"},
+		{"test test test test test test test test test test test junk", "m/44'/60'/0'/0/1", "0x70997970C51812dc3A010C7d01b50e0d17dc79C8"},
+	}
+	for _, tt := range tests {
+		seed, err := mnemonicSeed([]byte(tt.mnemonic), nil)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.mnemonic, err)
+		}
+		path, err := parseDerivationPath(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := deriveKey(seed, path)
+		if err != nil {
+			t.Fatalf("%q at %s: %v", tt.mnemonic, tt.path, err)
+		}
+		if got := crypto.PubkeyToAddress(key.PublicKey).Hex(); got != tt.address {
+			t.Errorf("%q at %s: %s, want %s", tt.mnemonic, tt.path, got, tt.address)
+		}
+	}
+}
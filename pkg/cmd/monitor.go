@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+// DefaultMonitorInterval is the refresh period of the monitor dashboard
+const DefaultMonitorInterval = 30 * time.Second
+
+// DefaultMonitorConcurrency is the number of addresses the monitor reads at once
+const DefaultMonitorConcurrency = 4
+
+// monitorRow is the last known state of a monitored address
+type monitorRow struct {
+	Address     common.Address
+	Result      *CheckResult
+	Balance     *big.Int
+	Err         error
+	LastChanged time.Time // When the delegation last changed during the session, zero if it has not
+	NewlySet    bool      // The address became delegated, or changed delegate, during the session
+}
+
+// sameDelegation reports whether two check results describe the same delegation state
+func sameDelegation(a, b *CheckResult) bool {
+	return a.Status == b.Status && a.Delegate == b.Delegate
+}
+
+// MonitorAddresses shows a table of the delegation state and native balance of
+// each address, refreshed every interval until the process is stopped. Reads
+// run concurrency at a time. Addresses that become delegated, or change
+// delegate, while monitoring are highlighted for the rest of the session.
+func MonitorAddresses(addresses []string, interval time.Duration, concurrency int, opts CheckOptions) error {
+	if len(addresses) == 0 {
+		return fmt.Errorf("no addresses to monitor")
+	}
+	if interval <= 0 {
+		interval = DefaultMonitorInterval
+	}
+	if concurrency < 1 {
+		concurrency = DefaultMonitorConcurrency
+	}
+	rows := make([]*monitorRow, len(addresses))
+	for i, address := range addresses {
+		if !common.IsHexAddress(address) {
+			return invalidInput("invalid Ethereum address format: %s", address)
+		}
+		rows[i] = &monitorRow{Address: common.HexToAddress(address)}
+	}
+
+	rpcURL := opts.rpcURLOrDefault()
+	chain := "unknown chain"
+	if chainID, err := getChainID(rpcURL); err == nil {
+		chain = chainLabel(chainID)
+	}
+	interactive := term.IsTerminal(int(os.Stdout.Fd()))
+
+	for refresh := 0; ; refresh++ {
+		refreshRows(rows, rpcURL, concurrency, refresh > 0, opts)
+		if interactive {
+			// Redraw in place
+			fmt.Print("\033[H\033[2J")
+		} else if refresh > 0 {
+			fmt.Println()
+		}
+		printMonitorTable(rows, chain, interval)
+		time.Sleep(interval)
+	}
+}
+
+// refreshRows reads the state of every row, concurrency at a time. Changes are
+// only tracked once a first state is known (track false on the first refresh).
+func refreshRows(rows []*monitorRow, rpcURL string, concurrency int, track bool, opts CheckOptions) {
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+	for _, row := range rows {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(row *monitorRow) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			result, err := CheckAddress(row.Address.Hex(), opts)
+			if err != nil {
+				// Keep the last known state, shown along with the error
+				row.Err = err
+				return
+			}
+			row.Err = nil
+			if track && row.Result != nil && !sameDelegation(row.Result, result) {
+				row.LastChanged = time.Now()
+				row.NewlySet = result.Status == StatusDelegated
+			}
+			row.Result = result
+			if balance, err := getBalance(rpcURL, row.Address.Hex()); err == nil {
+				row.Balance = balance
+			}
+		}(row)
+	}
+	wg.Wait()
+}
+
+// printMonitorTable prints one line per address, newly delegated ones in red
+func printMonitorTable(rows []*monitorRow, chain string, interval time.Duration) {
+	delegated, newly := 0, 0
+	for _, row := range rows {
+		if row.Result != nil && row.Result.Status == StatusDelegated {
+			delegated++
+		}
+		if row.NewlySet {
+			newly++
+		}
+	}
+
+	color.Cyan("Monitoring %d addresses on %s, refreshed %s, every %s (Ctrl+C to stop)",
+		len(rows), chain, time.Now().Format("15:04:05"), interval)
+	fmt.Printf("%d delegated, %d newly delegated since monitoring started\n\n", delegated, newly)
+	fmt.Printf("%-42s  %-56s  %-19s  %s\n", "Address", "Status", "Last changed", "Balance")
+	for _, row := range rows {
+		status := "unknown"
+		if row.Result != nil {
+			switch row.Result.Status {
+			case StatusClean:
+				status = "clean"
+			case StatusDelegated:
+				status = "delegated to " + row.Result.Delegate.Hex()
+			default:
+				status = "code that is not a delegation"
+			}
+		}
+		if row.NewlySet {
+			status = "NEW " + status
+		}
+		changed := "-"
+		if !row.LastChanged.IsZero() {
+			changed = row.LastChanged.Format("2006-01-02 15:04:05")
+		}
+		balance := "-"
+		if row.Balance != nil {
+			balance = fmt.Sprintf("%.6f", weiToEth(row.Balance))
+		}
+		line := fmt.Sprintf("%-42s  %-56s  %-19s  %s", row.Address.Hex(), status, changed, balance)
+		if row.Err != nil {
+			line += fmt.Sprintf("  (refresh failed: %v)", row.Err)
+		}
+
+		switch {
+		case row.NewlySet:
+			color.New(color.FgRed, color.Bold).Println(line)
+		case row.Result != nil && row.Result.Status == StatusDelegated:
+			color.Yellow(line)
+		case row.Err != nil:
+			color.Magenta(line)
+		default:
+			fmt.Println(line)
+		}
+	}
+}
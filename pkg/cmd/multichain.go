@@ -0,0 +1,329 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/fatih/color"
+)
+
+// chainTarget is one chain of a multi-chain clear
+type chainTarget struct {
+	Label  string // Network name or redacted RPC URL, until the chain ID is known
+	RPCURL string
+}
+
+// resolveChainTargets maps each entry of --networks to an RPC endpoint: the
+// rpc_url of a --network-file network of that name, or the entry itself when
+// it is an http(s) URL
+func resolveChainTargets(entries []string) ([]chainTarget, error) {
+	var targets []chainTarget
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		target := chainTarget{Label: entry, RPCURL: entry}
+		if u, err := url.Parse(entry); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+			target.Label = RedactURL(entry)
+		} else {
+			network, ok := LookupNetworkByName(entry)
+			if !ok {
+				return nil, invalidInput("unknown network %q (use a name from --network-file or an RPC URL)", entry)
+			}
+			if network.RPCURL == "" {
+				return nil, invalidInput("network %q has no rpc_url in --network-file", entry)
+			}
+			target.Label, target.RPCURL = network.Name, network.RPCURL
+		}
+		if seen[target.RPCURL] {
+			continue
+		}
+		seen[target.RPCURL] = true
+		targets = append(targets, target)
+	}
+	if len(targets) == 0 {
+		return nil, invalidInput("no networks to clear")
+	}
+	return targets, nil
+}
+
+// ChainClearResult is the result of a multi-chain clear on one chain
+type ChainClearResult struct {
+	Chain   string   // Chain name and ID, or the network as given when the chain could not be reached
+	Result  string   // What happened, e.g. "cleared" or the reason for a skip or failure
+	TxHash  string   // Clear transaction, empty when nothing was broadcast
+	Cost    *big.Int // Gas paid in the chain's native coin, nil when nothing was mined
+	Symbol  string   // Native coin of the chain
+	Failed  bool
+	Skipped bool
+}
+
+// ClearAllChains clears the delegation of the same victim on every chain of
+// networks, with the victim and relayer keys entered once. Each chain is
+// checked first: chains where the victim has no delegation are skipped, and so
+// are chains where the relayer cannot pay the maximum gas cost, since relayer
+// funds are per chain. A failure on one chain does not stop the others. With
+// Parallel, the clears are confirmed and broadcast chain after chain, then all
+// of them are waited for at once. The per-chain results and the gas paid per
+// native coin are summarized at the end.
+func ClearAllChains(networks []string, opts TxOptions) error {
+	if len(RPCFallbackURLs) > 0 {
+		return invalidInput("--rpc-fallback-url cannot be combined with clear-all-chains, its endpoints would be used for every chain")
+	}
+	if opts.SelfSponsor && opts.RelayerSignerURL != "" {
+		return invalidInput("--relayer-same-as-user cannot be combined with --relayer-signer-url")
+	}
+	opts.warnGasLimit(1)
+	targets, err := resolveChainTargets(networks)
+	if err != nil {
+		return err
+	}
+
+	labels := make([]string, len(targets))
+	for i, target := range targets {
+		labels[i] = target.Label
+	}
+	notice(color.FgCyan, "Clearing on %d chains: %s", len(targets), strings.Join(labels, ", "))
+	fmt.Fprintln(promptOutput, "The victim and relayer keys are entered once and used on every chain.")
+	fmt.Fprintln(promptOutput)
+
+	notice(color.FgRed, "Please enter the private key of the address with malicious contract authorization:")
+	victimPrivateKey, err := readPrivateKey()
+	if err != nil {
+		return fmt.Errorf("error reading victim private key: %w", err)
+	}
+	defer zeroKey(victimPrivateKey)
+	relayer, release, err := readRelayer(victimPrivateKey, "\nPlease enter the private key of the address that will pay for gas fees:", opts)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	results := make([]ChainClearResult, len(targets))
+	finishes := make([]func() ChainClearResult, len(targets))
+	for i, target := range targets {
+		notice(color.FgCyan, "\n=== %s ===", target.Label)
+		chainOpts := opts
+		chainOpts.RPCURL = target.RPCURL
+		results[i], finishes[i] = startClearOnChain(victimPrivateKey, newRelayerSession(target.RPCURL, relayer.signer), target, chainOpts)
+		if finishes[i] != nil && !opts.Parallel {
+			results[i] = finishes[i]()
+		}
+		reportChainResult(results[i])
+	}
+	if opts.Parallel {
+		notice(color.FgCyan, "\nWaiting for the clears on all chains at once...")
+		var wg sync.WaitGroup
+		for i, finish := range finishes {
+			if finish == nil {
+				continue
+			}
+			wg.Add(1)
+			go func(i int, finish func() ChainClearResult) {
+				defer wg.Done()
+				results[i] = finish()
+				reportChainResult(results[i])
+			}(i, finish)
+		}
+		wg.Wait()
+	}
+
+	return summarizeChains(crypto.PubkeyToAddress(victimPrivateKey.PublicKey).Hex(), results)
+}
+
+// ChainClear is one operation of ClearConcurrently
+type ChainClear struct {
+	RPCURL     string            // Endpoint of the chain
+	VictimKey  *ecdsa.PrivateKey // Key of the delegated account
+	RelayerKey *ecdsa.PrivateKey // Key paying for gas, nil for the victim to pay itself
+	Options    TxOptions         // Settings of the clear; RPCURL is taken from the operation and the confirmation is skipped
+}
+
+// ClearConcurrently runs several independent clears at once, typically on
+// different chains, with the same or different keys, and returns their results
+// in the order of ops. Each operation runs the checks and the clear of
+// clear-all-chains without any prompt, with its own nonces and --max-wait
+// deadline; a failure only affects its own result. Two operations may not use
+// the same endpoint, as their relayer nonces could collide.
+func ClearConcurrently(ops []ChainClear) ([]ChainClearResult, error) {
+	seen := map[string]bool{}
+	for _, op := range ops {
+		if op.VictimKey == nil {
+			return nil, invalidInput("every operation needs a victim key")
+		}
+		if seen[op.RPCURL] {
+			return nil, invalidInput("two operations use the same RPC URL %s", RedactURL(op.RPCURL))
+		}
+		seen[op.RPCURL] = true
+	}
+
+	results := make([]ChainClearResult, len(ops))
+	var wg sync.WaitGroup
+	for i, op := range ops {
+		wg.Add(1)
+		go func(i int, op ChainClear) {
+			defer wg.Done()
+			relayerKey := op.RelayerKey
+			if relayerKey == nil {
+				relayerKey = op.VictimKey
+			}
+			opts := op.Options
+			opts.RPCURL, opts.Yes = op.RPCURL, true
+			target := chainTarget{Label: RedactURL(op.RPCURL), RPCURL: op.RPCURL}
+			var finish func() ChainClearResult
+			results[i], finish = startClearOnChain(op.VictimKey, newRelayerSession(op.RPCURL, NewKeySigner(relayerKey)), target, opts)
+			if finish != nil {
+				results[i] = finish()
+			}
+		}(i, op)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// reportChainResult prints a line as soon as the clear on a chain fails or is skipped
+func reportChainResult(result ChainClearResult) {
+	switch {
+	case result.Failed:
+		notice(color.FgRed, "✗ %s: %s", result.Chain, result.Result)
+	case result.Skipped:
+		notice(color.FgYellow, "- %s: %s", result.Chain, result.Result)
+	}
+}
+
+// startClearOnChain runs the pre-flight checks and broadcasts the clear on one
+// chain. It returns the result so far and, when a clear was broadcast, the
+// step that waits for it and returns the final result.
+func startClearOnChain(victimPrivateKey *ecdsa.PrivateKey, relayer *relayerSession, target chainTarget, opts TxOptions) (ChainClearResult, func() ChainClearResult) {
+	outcome := ChainClearResult{Chain: target.Label}
+	failed := func(format string, a ...interface{}) (ChainClearResult, func() ChainClearResult) {
+		outcome.Failed = true
+		outcome.Result = fmt.Sprintf(format, a...)
+		return outcome, nil
+	}
+	skipped := func(format string, a ...interface{}) (ChainClearResult, func() ChainClearResult) {
+		outcome.Skipped = true
+		outcome.Result = fmt.Sprintf(format, a...)
+		return outcome, nil
+	}
+
+	chainID, err := getChainID(target.RPCURL)
+	if err != nil {
+		return failed("failed to get chain ID: %v", err)
+	}
+	outcome.Chain = chainLabel(chainID)
+	outcome.Symbol = nativeSymbol(chainID)
+	if err := checkEIP7702Support(chainID); err != nil {
+		return skipped("%v", err)
+	}
+
+	victim := crypto.PubkeyToAddress(victimPrivateKey.PublicKey)
+	state, err := CheckAddress(victim.Hex(), CheckOptions{RPCURL: target.RPCURL})
+	if err != nil {
+		return failed("failed to read the victim's code: %v", err)
+	}
+	switch state.Status {
+	case StatusClean:
+		return skipped("no delegation, nothing to clear")
+	case StatusHasCode:
+		return skipped("the account has contract code that is not an EIP-7702 delegation")
+	}
+	fmt.Fprintf(promptOutput, "%s: delegated to %s\n", outcome.Chain, state.Delegate.Hex())
+
+	// Relayer funds are per chain, an unfunded relayer only skips its chain
+	_, gasFeeCap, err := opts.gasFees(chainID)
+	if err != nil {
+		return failed("failed to get suggested gas fees: %v", err)
+	}
+	balance, err := getBalance(target.RPCURL, relayer.Address().Hex())
+	if err != nil {
+		return failed("failed to get relayer balance: %v", err)
+	}
+	if needed := maxGasCost(gasFeeCap, opts.gasLimitFor(1)); balance.Cmp(needed) < 0 {
+		return skipped("relayer %s is unfunded: balance %.9f %s, the clear may cost up to %.9f %s",
+			relayer.Address().Hex(), weiToEth(balance), outcome.Symbol, weiToEth(needed), outcome.Symbol)
+	}
+
+	result, err := broadcastAuthorization(clearAction, keyAuthorizer(victimPrivateKey), relayer, opts)
+	if err != nil {
+		return failed("%v", err)
+	}
+	outcome.TxHash = result.TxHash
+	outcome.Result = "broadcast"
+	return outcome, func() ChainClearResult {
+		err := awaitAuthorization(clearAction, result, opts)
+		if result.Receipt != nil {
+			outcome.Cost = result.Receipt.cost()
+		}
+		switch {
+		case err != nil:
+			outcome.Failed = true
+			outcome.Result = err.Error()
+		case result.Proposed:
+			outcome.Result = "signed, not broadcast (--dry-run)"
+		case result.Receipt == nil:
+			outcome.Result = "broadcast, not confirmed yet"
+		default:
+			outcome.Result = "cleared"
+		}
+		return outcome
+	}
+}
+
+// summarizeChains prints the per-chain results and the gas paid per native
+// coin, and returns an error when any chain failed
+func summarizeChains(victim string, outcomes []ChainClearResult) error {
+	fmt.Printf("\nMulti-chain clear of %s\n", victim)
+	totals := map[string]*big.Int{}
+	failures := 0
+	for _, outcome := range outcomes {
+		cost := "-"
+		if outcome.Cost != nil {
+			cost = fmt.Sprintf("%.9f %s", weiToEth(outcome.Cost), outcome.Symbol)
+			if totals[outcome.Symbol] == nil {
+				totals[outcome.Symbol] = new(big.Int)
+			}
+			totals[outcome.Symbol].Add(totals[outcome.Symbol], outcome.Cost)
+		}
+		line := fmt.Sprintf("  %-32s %-40s %s", outcome.Chain, outcome.Result, cost)
+		if outcome.TxHash != "" {
+			line += "  " + outcome.TxHash
+		}
+		switch {
+		case outcome.Failed:
+			failures++
+			color.Red(line)
+		case outcome.Skipped:
+			color.Yellow(line)
+		default:
+			color.Green(line)
+		}
+	}
+
+	symbols := make([]string, 0, len(totals))
+	for symbol := range totals {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	spent := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		spent[i] = fmt.Sprintf("%.9f %s", weiToEth(totals[symbol]), symbol)
+	}
+	if len(spent) == 0 {
+		spent = []string{"nothing"}
+	}
+	fmt.Printf("Total gas paid: %s\n", strings.Join(spent, ", "))
+
+	if failures > 0 {
+		return fmt.Errorf("the clear failed on %d of %d chains", failures, len(outcomes))
+	}
+	return nil
+}
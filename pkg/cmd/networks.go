@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Network describes a known EVM chain
+type Network struct {
+	Name     string
+	ChainID  uint64
+	Explorer string // Block explorer base URL, empty if unknown
+	Symbol   string // Native coin symbol, ETH when empty
+	PriceID  string // Price API id of the native coin, "ethereum" when empty
+	RPCURL   string // RPC endpoint selected by --network, empty if none
+
+	MinPriorityFee  *big.Int // Lowest priority fee the chain accepts in Wei, nil for no floor
+	EIP7702Disabled bool     // The chain does not accept EIP-7702 transactions
+}
+
+// builtinNetworks is the registry of chains the tool knows by name
+var builtinNetworks = []Network{
+	{Name: "Ethereum Mainnet", ChainID: 1, Explorer: "https://etherscan.io"},
+	{Name: "OP Mainnet", ChainID: 10, Explorer: "https://optimistic.etherscan.io"},
+	{Name: "BNB Smart Chain", ChainID: 56, Explorer: "https://bscscan.com", Symbol: "BNB", PriceID: "binancecoin"},
+	{Name: "Gnosis", ChainID: 100, Explorer: "https://gnosisscan.io", Symbol: "xDAI", PriceID: "xdai"},
+	{Name: "Unichain", ChainID: 130, Explorer: "https://uniscan.xyz"},
+	{Name: "Polygon", ChainID: 137, Explorer: "https://polygonscan.com", Symbol: "POL", PriceID: "polygon-ecosystem-token"},
+	{Name: "Base", ChainID: 8453, Explorer: "https://basescan.org"},
+	{Name: "Holesky", ChainID: 17000, Explorer: "https://holesky.etherscan.io"},
+	{Name: "Arbitrum One", ChainID: 42161, Explorer: "https://arbiscan.io"},
+	{Name: "Base Sepolia", ChainID: 84532, Explorer: "https://sepolia.basescan.org"},
+	{Name: "Arbitrum Sepolia", ChainID: 421614, Explorer: "https://sepolia.arbiscan.io"},
+	{Name: "Scroll", ChainID: 534352, Explorer: "https://scrollscan.com"},
+	{Name: "Hoodi", ChainID: 560048, Explorer: "https://hoodi.etherscan.io"},
+	{Name: "Sepolia", ChainID: 11155111, Explorer: "https://sepolia.etherscan.io"},
+	{Name: "OP Sepolia", ChainID: 11155420, Explorer: "https://sepolia-optimism.etherscan.io"},
+}
+
+// networks is the registry consulted by chain ID and by name: the built-in
+// networks, preceded by the ones loaded with LoadNetworkFile
+var networks = builtinNetworks
+
+// networkFileEntry is a network as written in a --network-file
+type networkFileEntry struct {
+	Name           string `json:"name"`
+	RPCURL         string `json:"rpc_url"`
+	ChainID        uint64 `json:"chain_id"`
+	Explorer       string `json:"explorer"`
+	Symbol         string `json:"symbol"`
+	PriceID        string `json:"price_id"`
+	MinPriorityFee string `json:"min_priority_fee"` // In Gwei
+	EIP7702        *bool  `json:"eip7702"`          // Defaults to true
+}
+
+// network validates the entry and converts it to a registry entry
+func (e networkFileEntry) network() (Network, error) {
+	if strings.TrimSpace(e.Name) == "" {
+		return Network{}, fmt.Errorf("missing name")
+	}
+	if e.ChainID == 0 {
+		return Network{}, fmt.Errorf("missing or zero chain_id")
+	}
+	for field, value := range map[string]string{"rpc_url": e.RPCURL, "explorer": e.Explorer} {
+		if value == "" {
+			continue
+		}
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return Network{}, fmt.Errorf("invalid %s %q (expected an http or https URL)", field, value)
+		}
+	}
+
+	network := Network{
+		Name:     e.Name,
+		ChainID:  e.ChainID,
+		Explorer: strings.TrimSuffix(e.Explorer, "/"),
+		Symbol:   e.Symbol,
+		PriceID:  e.PriceID,
+		RPCURL:   e.RPCURL,
+	}
+	if e.MinPriorityFee != "" {
+		fee, err := ParseGwei(e.MinPriorityFee)
+		if err != nil {
+			return Network{}, fmt.Errorf("invalid min_priority_fee: %w", err)
+		}
+		network.MinPriorityFee = fee
+	}
+	if e.EIP7702 != nil {
+		network.EIP7702Disabled = !*e.EIP7702
+	}
+	return network, nil
+}
+
+// LoadNetworkFile adds the networks defined in a JSON file (an array of
+// entries) to the registry. They take precedence over the built-in networks,
+// and one with the same name as a built-in network replaces it.
+func LoadNetworkFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var entries []networkFileEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("invalid network file %s: %w", path, err)
+	}
+
+	custom := make([]Network, 0, len(entries))
+	names := make(map[string]bool)
+	for i, entry := range entries {
+		network, err := entry.network()
+		if err != nil {
+			return fmt.Errorf("network file %s, entry #%d: %w", path, i+1, err)
+		}
+		key := strings.ToLower(network.Name)
+		if names[key] {
+			return fmt.Errorf("network file %s, entry #%d: duplicate name %q", path, i+1, network.Name)
+		}
+		names[key] = true
+		custom = append(custom, network)
+	}
+
+	merged := custom
+	for _, network := range builtinNetworks {
+		if names[strings.ToLower(network.Name)] {
+			notice(color.FgYellow, "Warning: network %q from %s overrides the built-in definition", network.Name, path)
+			continue
+		}
+		merged = append(merged, network)
+	}
+	networks = merged
+	return nil
+}
+
+// LookupNetworkByName returns the registry entry with the given name, ignoring case
+func LookupNetworkByName(name string) (Network, bool) {
+	for _, network := range networks {
+		if strings.EqualFold(network.Name, name) {
+			return network, true
+		}
+	}
+	return Network{}, false
+}
+
+// explorerTxURL returns the block explorer page of a transaction, empty when the chain's explorer is unknown
+func explorerTxURL(chainID *big.Int, txHash string) string {
+	network, ok := LookupNetwork(chainID)
+	if !ok || network.Explorer == "" || txHash == "" {
+		return ""
+	}
+	return network.Explorer + "/tx/" + txHash
+}
+
+// LookupNetwork returns the registry entry for a chain ID
+func LookupNetwork(chainID *big.Int) (Network, bool) {
+	if chainID == nil || !chainID.IsUint64() {
+		return Network{}, false
+	}
+	for _, network := range networks {
+		if network.ChainID == chainID.Uint64() {
+			return network, true
+		}
+	}
+	return Network{}, false
+}
+
+// chainLabel returns "Name (id)" for known chains and just the id otherwise
+func chainLabel(chainID *big.Int) string {
+	if network, ok := LookupNetwork(chainID); ok {
+		return fmt.Sprintf("%s (%d)", network.Name, chainID)
+	}
+	return chainID.String()
+}
+
+// nativeSymbol returns the symbol of the chain's native coin, ETH unless the registry says otherwise
+func nativeSymbol(chainID *big.Int) string {
+	if network, ok := LookupNetwork(chainID); ok && network.Symbol != "" {
+		return network.Symbol
+	}
+	return "ETH"
+}
+
+// checkEIP7702Support refuses chains the registry marks as not supporting EIP-7702
+func checkEIP7702Support(chainID *big.Int) error {
+	if network, ok := LookupNetwork(chainID); ok && network.EIP7702Disabled {
+		return fmt.Errorf("%s does not support EIP-7702 transactions", chainLabel(chainID))
+	}
+	return nil
+}
+
+// ErrEIP7702Unsupported is returned when the node rejects a transaction for its
+// type, because the chain has not activated EIP-7702
+var ErrEIP7702Unsupported = errors.New("this chain does not support EIP-7702 (type 0x04) transactions")
+
+// unsupportedTxTypeErrors are fragments of the lowercased errors clients
+// return on eth_sendRawTransaction for a transaction type they do not accept
+var unsupportedTxTypeErrors = []string{
+	"transaction type not supported", // geth, reth, erigon
+	"tx type not supported",          // erigon txpool
+	"unsupported transaction type",   // erigon decoding
+	"unsupported tx type",
+	"unknown transaction type", // nethermind decoding
+	"invalidtxtype",            // nethermind
+	"invalid transaction type", // besu
+	"transaction type 4 not supported",
+}
+
+// unsupportedTxTypeError translates a node error rejecting the transaction type
+// into ErrEIP7702Unsupported with guidance, and returns nil for any other error
+func unsupportedTxTypeError(message string) error {
+	lower := strings.ToLower(message)
+	for _, fragment := range unsupportedTxTypeErrors {
+		if strings.Contains(lower, fragment) {
+			return &TypedError{Type: ErrorTypeInvalidInput, Err: fmt.Errorf(
+				"%w: the node rejected the transaction type (%q). Check that --rpc-url or --network points at the intended chain, and that the chain and this node have activated EIP-7702 (Pectra or its equivalent); nothing was broadcast",
+				ErrEIP7702Unsupported, message)}
+		}
+	}
+	return nil
+}
+
+// networkGasFees returns the suggested fees, raised to the chain's minimum priority fee when the registry has one
+func networkGasFees(rpcURL string, chainID *big.Int) (*big.Int, *big.Int, error) {
+	gasTip, gasFeeCap, err := getSuggestedGasFees(rpcURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	gasTip, gasFeeCap = applyMinPriorityFee(chainID, gasTip, gasFeeCap)
+	return gasTip, gasFeeCap, nil
+}
+
+// applyMinPriorityFee raises a priority fee below the chain's minimum to it, and the max fee by as much
+func applyMinPriorityFee(chainID *big.Int, gasTip, gasFeeCap *big.Int) (*big.Int, *big.Int) {
+	network, ok := LookupNetwork(chainID)
+	if !ok || network.MinPriorityFee == nil || gasTip.Cmp(network.MinPriorityFee) >= 0 {
+		return gasTip, gasFeeCap
+	}
+	gasFeeCap = new(big.Int).Add(gasFeeCap, new(big.Int).Sub(network.MinPriorityFee, gasTip))
+	return new(big.Int).Set(network.MinPriorityFee), gasFeeCap
+}
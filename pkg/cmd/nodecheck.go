@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// eip7702ClientVersions are the first releases of the execution clients with
+// Prague support, which brings EIP-7702, by the lowercased client name that
+// starts web3_clientVersion
+var eip7702ClientVersions = map[string][3]int{
+	"geth":       {1, 15, 0},
+	"nethermind": {1, 31, 0},
+	"besu":       {25, 2, 0},
+	"erigon":     {3, 0, 0},
+	"reth":       {1, 2, 0},
+}
+
+// pragueHeaderChains are the L1 chains whose blocks carry a requestsHash once
+// Prague is active. L2 headers do not follow the L1 forks, so they are not checked.
+var pragueHeaderChains = map[uint64]bool{1: true, 17000: true, 560048: true, 11155111: true}
+
+var clientVersionPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)`)
+
+// getClientVersion returns the web3_clientVersion of the node, e.g. "Geth/v1.15.11-stable/linux-amd64/go1.24.2"
+func getClientVersion(rpcURL string) (string, error) {
+	body := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "web3_clientVersion",
+		"params":  []interface{}{},
+	}
+	responseBody, err := makeRPCCall(rpcURL, body)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		Result string `json:"result"`
+	}
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		return "", err
+	}
+	return result.Result, nil
+}
+
+// clientPredatesEIP7702 reports whether a web3_clientVersion names a known
+// client released before its EIP-7702 support. Unknown clients and
+// unparseable versions are given the benefit of the doubt.
+func clientPredatesEIP7702(clientVersion string) (bool, string) {
+	parts := strings.Split(clientVersion, "/")
+	if len(parts) < 2 {
+		return false, ""
+	}
+	minimum, ok := eip7702ClientVersions[strings.ToLower(parts[0])]
+	if !ok {
+		return false, ""
+	}
+	match := clientVersionPattern.FindStringSubmatch(parts[1])
+	if match == nil {
+		return false, ""
+	}
+	for i := 0; i < 3; i++ {
+		n, _ := strconv.Atoi(match[i+1])
+		if n != minimum[i] {
+			return n < minimum[i], fmt.Sprintf("%s %d.%d.%d", parts[0], minimum[0], minimum[1], minimum[2])
+		}
+	}
+	return false, ""
+}
+
+// latestBlockHasRequestsHash reports whether the latest block header carries
+// the requestsHash field Prague introduced
+func latestBlockHasRequestsHash(rpcURL string) (bool, error) {
+	body := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_getBlockByNumber",
+		"params":  []interface{}{"latest", false},
+	}
+	responseBody, err := makeRPCCall(rpcURL, body)
+	if err != nil {
+		return false, err
+	}
+	var result struct {
+		Result *struct {
+			RequestsHash string `json:"requestsHash"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(responseBody, &result); err != nil || result.Result == nil {
+		return false, fmt.Errorf("no latest block")
+	}
+	return result.Result.RequestsHash != "", nil
+}
+
+// nodeSupportAdvisories is a best-effort look at whether the node and chain
+// accept EIP-7702 transactions: the client version against the first release
+// supporting it, and on L1 chains whether the latest block shows Prague is
+// active. It returns the reasons for doubt, none when the checks pass or cannot run.
+func nodeSupportAdvisories(rpcURL string, chainID *big.Int) []string {
+	var advisories []string
+	if version, err := getClientVersion(rpcURL); err == nil {
+		if old, minimum := clientPredatesEIP7702(version); old {
+			advisories = append(advisories, fmt.Sprintf("the node runs %s, older than %s, the first release supporting EIP-7702", version, minimum))
+		}
+	}
+	if chainID.IsUint64() && pragueHeaderChains[chainID.Uint64()] {
+		if prague, err := latestBlockHasRequestsHash(rpcURL); err == nil && !prague {
+			advisories = append(advisories, fmt.Sprintf("the latest block of %s has no requestsHash, the node does not seem to have activated Prague", chainLabel(chainID)))
+		}
+	}
+	return advisories
+}
@@ -0,0 +1,232 @@
+package cmd
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	gmath "github.com/ethereum/go-ethereum/common/math"
+	"github.com/fatih/color"
+)
+
+// OfflineParams are the values a node would otherwise provide, for building
+// and signing the transaction on a machine without network access
+type OfflineParams struct {
+	ChainID      *big.Int
+	Nonce        uint64 // Nonce of the authorizing account, also the transaction's when self-sponsored
+	RelayerNonce uint64 // Nonce of the relayer, unused when self-sponsored
+	GasTip       *big.Int
+	GasFeeCap    *big.Int
+}
+
+// ParseOfflineParams parses the --offline flags: the chain ID and nonces in
+// decimal or 0x hex, the fees in Gwei. relayerNonce must be empty when
+// selfSponsor is set, as the account's own nonce is used for the transaction.
+func ParseOfflineParams(chainID, nonce, relayerNonce, gasTip, gasFeeCap string, selfSponsor bool) (*OfflineParams, error) {
+	var missing []string
+	for _, flag := range []struct{ name, value string }{
+		{"--chain-id", chainID},
+		{"--nonce", nonce},
+		{"--max-priority-fee", gasTip},
+		{"--max-fee", gasFeeCap},
+	} {
+		if flag.value == "" {
+			missing = append(missing, flag.name)
+		}
+	}
+	switch {
+	case selfSponsor && relayerNonce != "":
+		return nil, invalidInput("--relayer-nonce cannot be combined with --relayer-same-as-user, the account's --nonce is used for the transaction")
+	case !selfSponsor && relayerNonce == "":
+		missing = append(missing, "--relayer-nonce")
+	}
+	if len(missing) > 0 {
+		return nil, invalidInput("--offline requires %s, as no node is contacted", strings.Join(missing, ", "))
+	}
+
+	params := &OfflineParams{}
+	var ok bool
+	if params.ChainID, ok = gmath.ParseBig256(chainID); !ok || params.ChainID.Sign() <= 0 {
+		return nil, invalidInput("invalid chain ID %q", chainID)
+	}
+	if params.Nonce, ok = gmath.ParseUint64(nonce); !ok {
+		return nil, invalidInput("invalid nonce %q", nonce)
+	}
+	if relayerNonce != "" {
+		if params.RelayerNonce, ok = gmath.ParseUint64(relayerNonce); !ok {
+			return nil, invalidInput("invalid relayer nonce %q", relayerNonce)
+		}
+	}
+	var err error
+	if params.GasTip, err = ParseGwei(gasTip); err != nil {
+		return nil, invalidInput("invalid --max-priority-fee: %v", err)
+	}
+	if params.GasFeeCap, err = ParseGwei(gasFeeCap); err != nil {
+		return nil, invalidInput("invalid --max-fee: %v", err)
+	}
+	if params.GasFeeCap.Cmp(params.GasTip) < 0 {
+		return nil, invalidInput("--max-fee %s Gwei is below --max-priority-fee %s Gwei", gasFeeCap, gasTip)
+	}
+	return params, nil
+}
+
+// validateOffline rejects the options that need a node or a network service,
+// which Offline rules out
+func (o TxOptions) validateOffline() error {
+	var conflicts []string
+	for _, option := range []struct {
+		flag string
+		set  bool
+	}{
+		{"--batch", o.Batch},
+		{"--bundle-out", o.BundleOut != ""},
+		{"--broadcast-at", !o.BroadcastAt.IsZero()},
+		{"--safe-address", o.SafeAddress != ""},
+		{"--report-file", o.ReportFile != ""},
+		{"--expected-code-hash", o.ExpectedCodeHash != (common.Hash{})},
+		{"--max-cost-usd", o.MaxCostUSD > 0},
+		{"--gas-oracle-url", o.GasOracleURL != ""},
+		{"--fee-advisory", o.FeeAdvisory},
+		{"--relayer-signer-url", o.RelayerSignerURL != ""},
+		{"--relayer-azure-key", o.RelayerAzureKey != ""},
+		{"--relayer-fireblocks-vault", o.FireblocksVault != ""},
+		{"--relayer-vault-path", o.RelayerVaultPath != ""},
+	} {
+		if option.set {
+			conflicts = append(conflicts, option.flag)
+		}
+	}
+	if len(conflicts) > 0 {
+		return invalidInput("--offline cannot be combined with %s, which need the network", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// signOffline builds and signs the authorization transaction from
+// opts.Offline without any RPC call, and prints the raw signed transaction on
+// stdout for broadcast from another machine. Nothing about the chain can be
+// checked, the delegation target's code included.
+func signOffline(action authAction, opts TxOptions) error {
+	params := opts.Offline
+	out := opts.console()
+	for _, validate := range []func() error{opts.validateOffline, opts.validateTxOut, opts.validateKeystores, opts.validateExpectAddress} {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+	if err := checkEIP7702Support(params.ChainID); err != nil {
+		return err
+	}
+	notice(color.FgCyan, "Offline mode: no node is contacted, the chain ID, nonces and fees are the ones given.")
+	notice(color.FgCyan, "Chain: %s", chainLabel(params.ChainID))
+	if action.Template != (common.Address{}) {
+		notice(color.FgYellow, "The code of %s cannot be checked offline, check it on the online machine before broadcasting.", action.Template.Hex())
+	}
+
+	user, err := opts.loadAuthorizer(action.Template, strings.ToLower(action.UserLabel), color.FgYellow, "Please enter the private key of the authorizing address:")
+	if err != nil {
+		return err
+	}
+	defer zeroKey(user.key)
+	if err := checkExpectedAccount(action, user, opts); err != nil {
+		return err
+	}
+	relayer, release, err := readRelayer(user.key, "\nPlease enter the private key of the address that will pay for gas fees:", opts)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	relayerNonce, authNonce := params.RelayerNonce, params.Nonce
+	if opts.SelfSponsor {
+		// The sender's nonce is incremented before the authorization list is processed
+		relayerNonce, authNonce = params.Nonce, params.Nonce+1
+		out.infof("Self-sponsored: transaction nonce %d, authorization nonce %d\n", relayerNonce, authNonce)
+	} else {
+		out.infof("%s nonce: %d\n", action.UserLabel, authNonce)
+		out.infof("Relayer nonce: %d\n", relayerNonce)
+	}
+	authNonce = overrideAuthNonce(authNonce, opts)
+	if user.signed != nil && user.signed.Nonce != authNonce {
+		return invalidInput("the signed authorization is for nonce %d, not the %s nonce %d given", user.signed.Nonce, strings.ToLower(action.UserLabel), authNonce)
+	}
+	authChain, err := authChainID(action, user, opts)
+	if err != nil {
+		return err
+	}
+
+	gasTip, gasFeeCap := params.GasTip, params.GasFeeCap
+	opts.warnGasLimit(1)
+	gasLimit := opts.gasLimitFor(1)
+	printGasInfo(gasTip, gasFeeCap, gasLimit)
+	if opts.InteractiveGas {
+		if gasTip, gasFeeCap, err = tuneGasInteractively(gasTip, gasFeeCap, gasLimit); err != nil {
+			return err
+		}
+	}
+	if err := checkCostCeiling(params.ChainID, maxGasCost(gasFeeCap, gasLimit), opts); err != nil {
+		return err
+	}
+
+	req := SetAuthorizationRequest{
+		UserEOAPrivateKey: user.key,
+		Authorization:     user.signed,
+		UserEOANonce:      authNonce,
+		RelayerSigner:     relayer.signer,
+		RelayerNonce:      relayerNonce,
+		TemplateAddress:   action.Template,
+		ChainId:           params.ChainID,
+		AuthChainId:       authChain,
+		GasTip:            gasTip,
+		GasFeeCap:         gasFeeCap,
+		GasLimit:          gasLimit,
+		Data:              opts.CallData,
+		Value:             opts.CallValue,
+	}
+	out.infof("\n%s\n", action.Generating)
+	signedTx, err := GenerateSet7702AuthTx(req)
+	if err != nil {
+		return fmt.Errorf("failed to generate transaction: %w", err)
+	}
+	summary, err := summarizeSignedTx(signedTx)
+	if err != nil {
+		return fmt.Errorf("failed to decode the signed transaction: %w", err)
+	}
+	summary.print()
+	if summary.Authorizations[0].Authority != user.address() {
+		return verificationFailure("signed authorization does not recover to %s, aborting", user.address().Hex())
+	}
+	if opts.DryRun {
+		if err := verifySignedTx(signedTx, req); err != nil {
+			return err
+		}
+		return printDryRun(signedTx)
+	}
+	if opts.Paranoid {
+		if err := verifySignedTx(signedTx, req); err != nil {
+			return err
+		}
+		notice(color.FgGreen, "Paranoid check passed: the signed transaction matches what was confirmed")
+	}
+
+	if opts.Yes {
+		out.info("\nConfirmation skipped (--yes)")
+	} else {
+		fmt.Fprintf(promptOutput, "\nPrint the signed transaction? Anyone holding it can broadcast it. (y/n)\n")
+		if err := confirmOrCancel(); err != nil {
+			return err
+		}
+	}
+	if opts.JSONTx {
+		if err := printTxJSON(signedTx); err != nil {
+			return err
+		}
+	}
+	if err := writeSignedTx(signedTx, opts); err != nil {
+		return err
+	}
+	fmt.Fprintln(promptOutput, "On the online machine, check it with `eip7702cleaner validate-tx <hex>`, then broadcast it, e.g. with `cast publish <hex>`.")
+	fmt.Fprintln(promptOutput, "It only goes through while both nonces are still the ones signed for.")
+	return nil
+}
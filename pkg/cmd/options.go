@@ -0,0 +1,450 @@
+package cmd
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	gmath "github.com/ethereum/go-ethereum/common/math"
+	"github.com/fatih/color"
+)
+
+// CheckOptions holds the settings for the check command
+type CheckOptions struct {
+	RPCURL     string
+	Debug      bool
+	CodeMethod string // RPC method used to fetch the account code, defaults to eth_getCode
+	ChainName  bool   // Look up the chain and show its name alongside the result
+	OnlyTarget string // Batch check: only report addresses delegated to this contract
+	Strict     bool   // Treat code that is not an EIP-7702 delegation as an error
+
+	OutputAddresses string // Batch check: only print the addresses in this state (delegated, clean, has-code), one per line
+
+	UseOnchainRegistry bool   // Ask an on-chain abuse registry whether delegation targets are flagged
+	RegistryAddress    string // Registry contract implementing isFlagged(address) returns (bool)
+}
+
+// baseContext returns Context, or context.Background() when it is not set
+func (o TxOptions) baseContext() context.Context {
+	if o.Context == nil {
+		return context.Background()
+	}
+	return o.Context
+}
+
+// rpcURLOrDefault returns the configured RPC URL, falling back to DefaultRPCURL
+func (o CheckOptions) rpcURLOrDefault() string {
+	if o.RPCURL == "" {
+		return DefaultRPCURL
+	}
+	return o.RPCURL
+}
+
+// TxOptions holds the settings shared by the set and clear commands
+type TxOptions struct {
+	Context         context.Context // Cancelled to abort broadcasts and waits, e.g. on Ctrl+C; nil for none
+	RPCURL          string
+	GasLimit        uint64
+	InteractiveGas  bool // Let the user review and adjust the suggested fees before confirming
+	JSONTx          bool // Print the signed transaction in EIP-2718 typed transaction JSON form
+	PollViaTxLookup bool // Detect inclusion with eth_getTransactionByHash before fetching the receipt
+	Paranoid        bool // Re-decode and verify the signed transaction before broadcasting
+	Quiet           bool // Only show the gas summary, the confirmation prompt and the final result
+	Yes             bool // Skip the confirmation prompt
+
+	MaxCostUSD         float64  // Abort before signing when the max gas cost exceeds this many US dollars, 0 for no cap
+	MaxCost            *big.Int // Abort before signing when the max gas cost exceeds this many Wei, nil for no cap
+	IgnorePriceFailure bool     // Go ahead without the USD cap when no price can be fetched and MaxCost is not set
+	FeeAdvisory        bool     // Before key entry, advise whether the network is congested, from eth_feeHistory
+	SkipNodeCheck      bool     // Skip the best-effort check, before key entry, that the node supports EIP-7702
+	GasOracleURL       string   // Take the fees from this gas oracle instead of the node, falling back to the node on failure
+	GasPriority        string   // Tier of the gas oracle, GasPriorityStandard (default), GasPriorityFast or GasPriorityInstant
+	GasTip             *big.Int // Max priority fee per gas in Wei instead of the suggested one, nil to fetch it
+	GasFeeCap          *big.Int // Max fee per gas in Wei instead of the suggested one, nil to fetch it
+	SelfSponsor        bool     // Use the user key to pay for gas too, prompting for a single key
+	Keystore           string   // Encrypted keystore file of the user key, prompting for its passphrase instead of the hex key
+	RelayerKeystore    string   // Encrypted keystore file of the relayer key
+	Mnemonic           bool     // Derive the user key from a BIP-39 mnemonic prompted for instead of the hex key
+	HDPath             string   // BIP-32 path of the user key in the mnemonic, DefaultDerivationPath when empty
+	RelayerMnemonic    bool     // Derive the relayer key from a BIP-39 mnemonic
+	RelayerHDPath      string   // BIP-32 path of the relayer key in its mnemonic or on the Ledger, DefaultDerivationPath when empty
+	RelayerLedger      bool     // Sign the transaction with the relayer account of a Ledger connected over USB
+	RelayerVaultPath   string   // Read the relayer key from this HashiCorp Vault KV secret, using VAULT_ADDR and VAULT_TOKEN
+	RelayerVaultField  string   // Field of the Vault secret holding the hex key, DefaultVaultField when empty
+	RelayerAzureKey    string   // Sign the transaction with this Azure Key Vault P-256K key URL
+	KeyEnv             string   // Read the user key from this environment variable, for unattended runs
+	KeyFile            string   // Read the user key from this file holding it in hex, for unattended runs
+	RelayerKeyEnv      string   // Read the relayer key from this environment variable
+	AuthFile           string   // Use the authorization signed in this JSON file, as printed by sign-authorization, instead of the user key
+
+	RelayerSignerURL     string // JSON-RPC endpoint of a remote signer holding the relayer key, instead of prompting for it
+	RelayerSignerAddress string // Relayer account of the remote signer, required when it manages several
+	FireblocksVault      string // Sign the transaction with the RAW signing API of this Fireblocks vault account
+	FireblocksAsset      string // Asset of the vault account whose key signs, DefaultFireblocksAsset when empty
+	FireblocksNote       string // Note attached to the Fireblocks signing requests
+	ExpectAddress        string // Abort right after key entry unless the user key is for this address
+
+	BundleOut         string        // Write the signed transaction and its artifacts to this file instead of broadcasting
+	TxOut             string        // Write the raw signed transaction to this file instead of broadcasting
+	NoBroadcast       bool          // Print the raw signed transaction on stdout instead of broadcasting
+	DryRun            bool          // Sign locally and print every field of the decoded transaction instead of broadcasting it
+	BroadcastAt       time.Time     // Sign now, then broadcast at this time once both nonces are found unchanged; zero to broadcast at once
+	Batch             bool          // Read the relayer key once, then process authority keys until an empty one
+	BatchSize         int           // Batch: transactions broadcast before waiting for their receipts, defaults to 1
+	BatchDelay        time.Duration // Batch: pause between two broadcasts
+	CheckpointFile    string        // Batch: record each account's progress in this file and skip the ones already processed
+	OnError           string        // Batch: what a failed broadcast does, OnErrorSkip (default), OnErrorHalt or OnErrorReuseNonce
+	Parallel          bool          // clear-all-chains: wait for the clears of all chains at once instead of one after the other
+	AssumeYesForClean bool          // clear: succeed without signing anything when the account has no delegation
+	AllowEmptyTarget  bool          // set: allow delegating to an address without contract code
+	AllowSelfTarget   bool          // set: allow delegating an account to its own address
+	ExpectedCodeHash  common.Hash   // set: keccak256 the target's code must have, zero to skip the check
+	CallData          []byte        // set: calldata of a call to the authorized account with its new code, e.g. an initializer; estimated with the authorization list when GasLimit is 0
+	CallValue         *big.Int      // set: value sent by the relayer to the authorized account with CallData, nil for none
+
+	NoWait          bool          // Return once the transaction is broadcast, without waiting for it to be mined
+	Confirmations   uint64        // Blocks the transaction must be buried under, defaults to 1
+	ConfirmTimeout  time.Duration // How long to wait for confirmation, defaults to DefaultConfirmTimeout
+	ConfirmAttempts int           // Number of status checks to wait for confirmation, overrides ConfirmTimeout when set
+	PollInterval    time.Duration // Delay between two status checks, defaults to DefaultPollInterval
+	MaxWait         time.Duration // Overall deadline from the first broadcast to the last confirmation, 0 for none
+	BumpSchedule    []float64     // Fee increases in percent over the original fees for successive resubmissions, empty to never resubmit
+	MaxFeeCap       *big.Int      // Upper bound of the max fee per gas of resubmissions, nil for none
+	Bump            float64       // speedup, cancel: fee increase in percent over the pending transaction, DefaultReplacementBump when 0
+
+	ReportFile     string   // clear: write a rescue report here once the clear completes, Markdown for .md files, JSON otherwise
+	MinRecoverable *big.Int // clear: abort when the net recoverable native value is below this amount, nil to disable
+	FrontRunBlocks int      // clear: blocks after the clear to scan for a re-delegation of the victim, 0 to skip
+
+	SafeAddress string           // clear: sweep the victim's remaining ETH here after a successful clear
+	SweepTokens []common.Address // clear: ERC-20 tokens to sweep to SafeAddress before the ETH
+
+	Address          string // Address whose delegation will change, for steps that run before key entry
+	VerifyOnly       bool   // Only verify that Address is in the state the command would produce
+	EstimateOnly     bool   // clear: only report the recoverable value against the rescue's gas cost
+	DryRunDiff       bool   // clear: only show the account state before and after the clear
+	SimulateOverride bool   // clear: simulate the sweep with the victim's code overridden to empty, without broadcasting
+	Fiat             string // Fiat currency (e.g. usd) for value displays, empty to disable
+
+	VictimNonce  *uint64 // Nonce of the authorizing account instead of the node's, nil to read it
+	RelayerNonce *uint64 // Nonce of the (first) relayer transaction instead of the node's pending count, nil to read it
+	AuthNonce    *uint64 // Sign the authorization for this nonce instead of the one derived from the account's, nil to derive it
+
+	AllChainsAuth bool // Sign the authorization for chain ID 0, valid on every chain where the account's nonce matches
+
+	Offline *OfflineParams // Sign with these parameters and print the raw transaction without contacting a node, nil to run online
+}
+
+// ParseNonce parses the value of a nonce flag, in decimal or 0x hex
+func ParseNonce(flag, value string) (*uint64, error) {
+	nonce, ok := gmath.ParseUint64(value)
+	if !ok {
+		return nil, invalidInput("invalid %s %q", flag, value)
+	}
+	return &nonce, nil
+}
+
+// gasLimitFor returns the gas limit of a transaction carrying count
+// authorizations: GasLimit when set, raised to the intrinsic gas the
+// transaction cannot do with, defaultGasLimit otherwise
+func (o TxOptions) gasLimitFor(count int) uint64 {
+	if o.GasLimit != 0 {
+		return max(o.GasLimit, IntrinsicGas(count, o.CallData, nil))
+	}
+	return defaultGasLimit(count, o.CallData)
+}
+
+// warnGasLimit warns that GasLimit is raised by gasLimitFor, as a node would
+// reject the transaction outright
+func (o TxOptions) warnGasLimit(count int) {
+	if minimum := IntrinsicGas(count, o.CallData, nil); o.GasLimit != 0 && o.GasLimit < minimum {
+		notice(color.FgYellow, "Warning: --gas-limit %d is below the intrinsic gas of %d of this transaction, which nodes would reject; using %d", o.GasLimit, minimum, minimum)
+	}
+}
+
+// rpcURLOrDefault returns the configured RPC URL, falling back to DefaultRPCURL
+func (o TxOptions) rpcURLOrDefault() string {
+	if o.RPCURL == "" {
+		return DefaultRPCURL
+	}
+	return o.RPCURL
+}
+
+// confirmations returns the number of confirmations to wait for, at least 1
+func (o TxOptions) confirmations() uint64 {
+	if o.Confirmations == 0 {
+		return 1
+	}
+	return o.Confirmations
+}
+
+// confirmTimeout returns the confirmation timeout: ConfirmAttempts poll intervals
+// when set, otherwise ConfirmTimeout, falling back to DefaultConfirmTimeout
+func (o TxOptions) confirmTimeout() time.Duration {
+	if o.ConfirmAttempts > 0 {
+		return time.Duration(o.ConfirmAttempts) * o.pollInterval()
+	}
+	if o.ConfirmTimeout <= 0 {
+		return DefaultConfirmTimeout
+	}
+	return o.ConfirmTimeout
+}
+
+// pollInterval returns the delay between two status checks, falling back to DefaultPollInterval
+func (o TxOptions) pollInterval() time.Duration {
+	if o.PollInterval <= 0 {
+		return DefaultPollInterval
+	}
+	return o.PollInterval
+}
+
+// validateBundleOut rejects the options that cannot be combined with BundleOut
+func (o TxOptions) validateBundleOut() error {
+	switch {
+	case o.BundleOut == "":
+		return nil
+	case o.Batch:
+		return invalidInput("--bundle-out cannot be combined with --batch")
+	case o.SafeAddress != "":
+		return invalidInput("--bundle-out cannot be combined with --safe-address: the sweep needs the clear to be broadcast first")
+	}
+	return nil
+}
+
+// validateTxOut rejects the options that cannot be combined with TxOut and
+// NoBroadcast, which stop once the transaction is signed
+func (o TxOptions) validateTxOut() error {
+	if err := o.validateDryRun(); err != nil {
+		return err
+	}
+	var flag string
+	switch {
+	case o.TxOut != "":
+		flag = "--out"
+	case o.NoBroadcast:
+		flag = "--no-broadcast"
+	default:
+		return nil
+	}
+	switch {
+	case o.Batch:
+		return invalidInput("%s cannot be combined with --batch", flag)
+	case o.BundleOut != "":
+		return invalidInput("%s cannot be combined with --bundle-out, which writes the signed transaction too", flag)
+	case !o.BroadcastAt.IsZero():
+		return invalidInput("%s cannot be combined with --broadcast-at", flag)
+	case o.SafeAddress != "":
+		return invalidInput("%s cannot be combined with --safe-address: the sweep needs the clear to be broadcast first", flag)
+	}
+	return nil
+}
+
+// validateDryRun rejects the options that keep or send the signed
+// transaction, which DryRun discards once printed, and --batch, which records
+// and chains what it sends
+func (o TxOptions) validateDryRun() error {
+	if !o.DryRun {
+		return nil
+	}
+	switch {
+	case o.TxOut != "":
+		return invalidInput("--dry-run cannot be combined with --out, it keeps nothing")
+	case o.NoBroadcast:
+		return invalidInput("--dry-run cannot be combined with --no-broadcast, use one of them")
+	case o.BundleOut != "":
+		return invalidInput("--dry-run cannot be combined with --bundle-out, it keeps nothing")
+	case !o.BroadcastAt.IsZero():
+		return invalidInput("--dry-run cannot be combined with --broadcast-at, it broadcasts nothing")
+	case o.SafeAddress != "":
+		return invalidInput("--dry-run cannot be combined with --safe-address: the sweep needs the clear to be broadcast first")
+	case o.Batch:
+		// Items would be checkpointed as done, and signed with the same unused relayer nonce
+		return invalidInput("--dry-run cannot be combined with --batch")
+	}
+	return nil
+}
+
+// validateBroadcastAt rejects the options that cannot be combined with
+// BroadcastAt. Nonce overrides are among them: the nonces are checked against
+// the node's just before the broadcast, so any override differing from them
+// would only fail once the scheduled time has come.
+func (o TxOptions) validateBroadcastAt() error {
+	switch {
+	case o.BroadcastAt.IsZero():
+		return nil
+	case o.Batch:
+		return invalidInput("--broadcast-at cannot be combined with --batch")
+	case o.BundleOut != "":
+		return invalidInput("--broadcast-at cannot be combined with --bundle-out, broadcast the bundle when due instead")
+	case o.VictimNonce != nil, o.RelayerNonce != nil, o.AuthNonce != nil:
+		return invalidInput("--broadcast-at cannot be combined with --victim-nonce, --relayer-nonce or --auth-nonce: the nonces are checked against the node's before the broadcast")
+	}
+	return nil
+}
+
+// relayerKeySources returns the flags selecting where the relayer key comes from
+func (o TxOptions) relayerKeySources() []string {
+	var sources []string
+	for _, source := range []struct {
+		flag string
+		set  bool
+	}{
+		{"--relayer-same-as-user", o.SelfSponsor},
+		{"--relayer-signer-url", o.RelayerSignerURL != ""},
+		{"--relayer-keystore", o.RelayerKeystore != ""},
+		{"--relayer-mnemonic", o.RelayerMnemonic},
+		{"--relayer-ledger", o.RelayerLedger},
+		{"--relayer-vault-path", o.RelayerVaultPath != ""},
+		{"--relayer-azure-key", o.RelayerAzureKey != ""},
+		{"--relayer-key-env", o.RelayerKeyEnv != ""},
+		{"--relayer-fireblocks-vault", o.FireblocksVault != ""},
+	} {
+		if source.set {
+			sources = append(sources, source.flag)
+		}
+	}
+	return sources
+}
+
+// userKeySources returns the flags selecting where the user key comes from,
+// the prompt being used when there is none
+func (o TxOptions) userKeySources() []string {
+	var sources []string
+	for _, source := range []struct {
+		flag string
+		set  bool
+	}{
+		{"--keystore", o.Keystore != ""},
+		{"--mnemonic", o.Mnemonic},
+		{"--victim-key-env", o.KeyEnv != ""},
+		{"--key-file", o.KeyFile != ""},
+		{"--auth-file", o.AuthFile != ""},
+	} {
+		if source.set {
+			sources = append(sources, source.flag)
+		}
+	}
+	return sources
+}
+
+// validateKeystores rejects key sources combined with another source of the
+// same key, and checks the derivation paths before any prompt
+func (o TxOptions) validateKeystores() error {
+	if sources := o.relayerKeySources(); len(sources) > 1 {
+		return invalidInput("%s cannot be combined, the relayer key has a single source", strings.Join(sources, " and "))
+	}
+	sources := o.userKeySources()
+	switch {
+	case len(sources) > 1:
+		return invalidInput("%s cannot be combined, the account key has a single source", strings.Join(sources, " and "))
+	case len(sources) == 1 && o.Batch:
+		return invalidInput("%s cannot be combined with --batch, which reads several account keys", sources[0])
+	case o.AuthFile != "" && o.SelfSponsor:
+		return invalidInput("--auth-file cannot be combined with --relayer-same-as-user, which pays with the account key")
+	case o.AuthFile == "-" && !stdinIsTerminal() && (!o.Yes || o.relayerPrompts()):
+		return invalidInput("reading the authorization from a pipe leaves no input for prompts: add --yes and a relayer key source that does not prompt, such as --relayer-key-env")
+	case o.AuthFile != "" && o.SafeAddress != "":
+		return invalidInput("--auth-file cannot be combined with --safe-address, the sweep is signed with the victim key")
+	case o.HDPath != "" && !o.Mnemonic:
+		return invalidInput("--derivation-path requires --mnemonic")
+	case (o.FireblocksAsset != "" || o.FireblocksNote != "") && o.FireblocksVault == "":
+		return invalidInput("--fireblocks-asset and --fireblocks-note require --relayer-fireblocks-vault")
+	case o.RelayerVaultField != "" && o.RelayerVaultPath == "":
+		return invalidInput("--relayer-vault-field requires --relayer-vault-path")
+	case o.RelayerHDPath != "" && !o.RelayerMnemonic && !o.RelayerLedger:
+		return invalidInput("--relayer-derivation-path requires --relayer-mnemonic or --relayer-ledger")
+	}
+	for _, path := range []string{o.HDPath, o.RelayerHDPath} {
+		if path == "" {
+			continue
+		}
+		if _, err := parseDerivationPath(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateExpectAddress checks --expect-address, which only applies to a single account key
+func (o TxOptions) validateExpectAddress() error {
+	switch {
+	case o.ExpectAddress == "":
+		return nil
+	case !common.IsHexAddress(o.ExpectAddress):
+		return invalidInput("invalid --expect-address %s", o.ExpectAddress)
+	case o.Batch:
+		return invalidInput("--expect-address cannot be combined with --batch, which reads several account keys")
+	case o.Address != "" && common.HexToAddress(o.Address) != common.HexToAddress(o.ExpectAddress):
+		return invalidInput("--expect-address %s and --address %s differ", o.ExpectAddress, o.Address)
+	}
+	return nil
+}
+
+// validateNonceOverrides rejects nonce overrides that contradict each other or
+// cannot apply to every account of a batch
+func (o TxOptions) validateNonceOverrides() error {
+	switch {
+	case o.Batch && o.VictimNonce != nil:
+		return invalidInput("--victim-nonce cannot be combined with --batch, which reads several accounts")
+	case o.Batch && o.AuthNonce != nil:
+		return invalidInput("--auth-nonce cannot be combined with --batch, which reads several accounts")
+	case o.SelfSponsor && o.RelayerNonce != nil:
+		return invalidInput("--relayer-nonce cannot be combined with --relayer-same-as-user, the account pays with its own nonce: use --victim-nonce")
+	case !o.SelfSponsor && o.VictimNonce != nil && o.AuthNonce != nil:
+		return invalidInput("--victim-nonce and --auth-nonce cannot be combined, the authorization signs the account's nonce: give one of them")
+	}
+	return nil
+}
+
+// hasCall reports whether the transaction calls the authorized account, with
+// CallData or a CallValue
+func (o TxOptions) hasCall() bool {
+	return len(o.CallData) > 0 || (o.CallValue != nil && o.CallValue.Sign() > 0)
+}
+
+// validateCall rejects a call to the authorized account without GasLimit when
+// it cannot be estimated: with Offline no node is asked, and when the
+// transaction is not broadcast to the node, the estimate would hand the node
+// the signed authorization ahead of the channel chosen to submit it
+func (o TxOptions) validateCall() error {
+	if !o.hasCall() || o.GasLimit != 0 {
+		return nil
+	}
+	switch {
+	case o.Offline != nil:
+		return invalidInput("--data and --value need --gas-limit with --offline, covering the authorization and the call: no node is asked for an estimate")
+	case o.NoBroadcast || o.TxOut != "" || o.BundleOut != "" || o.DryRun:
+		return invalidInput("--data and --value need --gas-limit when the transaction is not broadcast: the estimate would hand the signed authorization to the node")
+	}
+	return nil
+}
+
+// validateNoWait rejects the options that need the transaction to be mined, which NoWait skips
+func (o TxOptions) validateNoWait() error {
+	switch {
+	case !o.NoWait:
+		return nil
+	case o.SafeAddress != "":
+		return invalidInput("--no-wait cannot be combined with --safe-address: the sweep needs the clear to be mined first")
+	case len(o.BumpSchedule) > 0:
+		return invalidInput("--no-wait cannot be combined with --bump-schedule, which resubmits while waiting")
+	case o.ReportFile != "":
+		return invalidInput("--no-wait cannot be combined with --report-file, which reports the mined clear")
+	}
+	return nil
+}
+
+// batchSize returns the number of batch transactions per chunk, at least 1
+func (o TxOptions) batchSize() int {
+	if o.BatchSize < 1 {
+		return 1
+	}
+	return o.BatchSize
+}
+
+// console returns the printer for the set/clear output, honouring quiet mode
+func (o TxOptions) console() console {
+	return console{quiet: o.Quiet}
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateDryRun(t *testing.T) {
+	tests := []struct {
+		name string
+		opts TxOptions
+		err  string
+	}{
+		{"alone", TxOptions{DryRun: true}, ""},
+		{"batch", TxOptions{DryRun: true, Batch: true}, "--dry-run cannot be combined with --batch"},
+		{"batch with checkpoint", TxOptions{DryRun: true, Batch: true, CheckpointFile: "progress.json"}, "--dry-run cannot be combined with --batch"},
+		{"no broadcast", TxOptions{DryRun: true, NoBroadcast: true}, "--dry-run cannot be combined with --no-broadcast"},
+		{"batch without dry run", TxOptions{Batch: true}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.validateDryRun()
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Fatalf("error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestValidateCall(t *testing.T) {
+	data := []byte{0xc4, 0xd6, 0x6d, 0xe8}
+	tests := []struct {
+		name string
+		opts TxOptions
+		err  string
+	}{
+		{"no call", TxOptions{NoBroadcast: true}, ""},
+		{"estimated", TxOptions{CallData: data}, ""},
+		{"value only", TxOptions{CallValue: big.NewInt(1)}, ""},
+		{"zero value", TxOptions{CallValue: new(big.Int), Offline: &OfflineParams{}}, ""},
+		{"offline", TxOptions{CallData: data, Offline: &OfflineParams{}}, "need --gas-limit with --offline"},
+		{"offline with gas limit", TxOptions{CallData: data, Offline: &OfflineParams{}, GasLimit: 200000}, ""},
+		{"no broadcast", TxOptions{CallData: data, NoBroadcast: true}, "not broadcast"},
+		{"out", TxOptions{CallValue: big.NewInt(1), TxOut: "tx.hex"}, "not broadcast"},
+		{"bundle", TxOptions{CallData: data, BundleOut: "bundle.json"}, "not broadcast"},
+		{"dry run", TxOptions{CallData: data, DryRun: true}, "not broadcast"},
+		{"dry run with gas limit", TxOptions{CallData: data, DryRun: true, GasLimit: 200000}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.validateCall()
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Fatalf("error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestValidateBroadcastAt(t *testing.T) {
+	at := time.Now().Add(time.Hour)
+	nonce := uint64(5)
+	tests := []struct {
+		name string
+		opts TxOptions
+		err  string
+	}{
+		{"not scheduled", TxOptions{RelayerNonce: &nonce}, ""},
+		{"scheduled", TxOptions{BroadcastAt: at}, ""},
+		{"batch", TxOptions{BroadcastAt: at, Batch: true}, "cannot be combined with --batch"},
+		{"victim nonce", TxOptions{BroadcastAt: at, VictimNonce: &nonce}, "--victim-nonce"},
+		{"relayer nonce", TxOptions{BroadcastAt: at, RelayerNonce: &nonce}, "--relayer-nonce"},
+		{"auth nonce", TxOptions{BroadcastAt: at, AuthNonce: &nonce}, "--auth-nonce"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.validateBroadcastAt()
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Fatalf("error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestAwaitBroadcastTimeCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := awaitBroadcastTime(ctx, time.Now().Add(time.Hour), "0x")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+}
+
+// TestValidationErrorType checks that rejected flag combinations are reported
+// as invalid input in --json mode, not as internal errors
+func TestValidationErrorType(t *testing.T) {
+	nonce := uint64(1)
+	tests := []struct {
+		name     string
+		validate func() error
+	}{
+		{"dry run", TxOptions{DryRun: true, Batch: true}.validateDryRun},
+		{"broadcast at", TxOptions{BroadcastAt: time.Now().Add(time.Hour), Batch: true}.validateBroadcastAt},
+		{"bundle out", TxOptions{BundleOut: "bundle.json", Batch: true}.validateBundleOut},
+		{"nonce overrides", TxOptions{VictimNonce: &nonce, Batch: true}.validateNonceOverrides},
+		{"offline", TxOptions{Batch: true}.validateOffline},
+		{"clear", func() error { return Clear(TxOptions{VerifyOnly: true}) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.validate()
+			if err == nil {
+				t.Fatal("no error")
+			}
+			if got := ErrorType(err); got != ErrorTypeInvalidInput {
+				t.Fatalf("ErrorType(%v) = %q, want %q", err, got, ErrorTypeInvalidInput)
+			}
+		})
+	}
+}
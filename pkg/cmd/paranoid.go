@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// verifySignedTx re-decodes a fully signed transaction and checks every field
+// against the request the user confirmed, recovering both the authority and the
+// sender from their signatures. Any discrepancy is returned as a detailed diff.
+func verifySignedTx(signedTx string, req SetAuthorizationRequest) error {
+	tx, err := DecodeSetCodeTx(signedTx)
+	if err != nil {
+		return fmt.Errorf("paranoid check failed: %w", err)
+	}
+
+	var diffs []string
+	check := func(field string, expected, actual interface{}) {
+		if fmt.Sprint(expected) != fmt.Sprint(actual) {
+			diffs = append(diffs, fmt.Sprintf("  %-22s expected %v, got %v", field+":", expected, actual))
+		}
+	}
+
+	check("chain id", req.ChainId, tx.ChainID)
+	check("relayer nonce", req.RelayerNonce, tx.Nonce)
+	check("priority fee", req.GasTip, tx.GasTipCap)
+	check("max fee per gas", req.GasFeeCap, tx.GasFeeCap)
+	check("gas limit", req.GasLimit, tx.Gas)
+	to, value, data, err := req.call()
+	if err != nil {
+		return fmt.Errorf("paranoid check failed: %w", err)
+	}
+	check("to", to.Hex(), tx.To.Hex())
+	check("value", value, tx.Value)
+	check("data", hexutil.Encode(data), hexutil.Encode(tx.Data))
+
+	relayer, err := req.relayerSigner()
+	if err != nil {
+		return fmt.Errorf("paranoid check failed: %w", err)
+	}
+	sender, err := tx.Sender()
+	if err != nil {
+		diffs = append(diffs, fmt.Sprintf("  sender: failed to recover: %v", err))
+	} else {
+		check("sender", relayer.Address().Hex(), sender.Hex())
+	}
+
+	accounts := req.accounts()
+	check("authorizations", len(accounts), len(tx.AuthList))
+	if len(tx.AuthList) == len(accounts) {
+		for i, account := range accounts {
+			auth := tx.AuthList[i]
+			prefix, authorityField := "auth", "authority"
+			if len(accounts) > 1 {
+				prefix = fmt.Sprintf("auth %d", i+1)
+				authorityField = prefix + " authority"
+			}
+			authChainID, _ := account.authChainID()
+			if account.Authorization != nil {
+				authChainID = account.Authorization.ChainID
+			}
+			check(prefix+" chain id", authChainID, auth.ChainID)
+			check(prefix+" nonce", account.UserEOANonce, auth.Nonce)
+			check(prefix+" target", req.TemplateAddress.Hex(), auth.Address.Hex())
+
+			expected, err := account.authority()
+			if err != nil {
+				return fmt.Errorf("paranoid check failed: %w", err)
+			}
+			authority, err := auth.Authority()
+			if err != nil {
+				diffs = append(diffs, fmt.Sprintf("  %s: failed to recover: %v", authorityField, err))
+			} else {
+				check(authorityField, expected.Hex(), authority.Hex())
+			}
+		}
+	}
+
+	if len(diffs) > 0 {
+		return fmt.Errorf("paranoid check failed, the signed transaction does not match what was confirmed:\n%s",
+			strings.Join(diffs, "\n"))
+	}
+	return nil
+}
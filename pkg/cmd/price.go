@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// DefaultPriceURL is the price API used for fiat conversions. %s are replaced
+// with the coin id and the currency.
+const DefaultPriceURL = "https://api.coingecko.com/api/v3/simple/price?ids=%s&vs_currencies=%s"
+
+// fetchNativePrice returns the price of one unit of the chain's native coin in the given fiat currency
+func fetchNativePrice(chainID *big.Int, currency string) (float64, error) {
+	coinID := "ethereum"
+	if network, ok := LookupNetwork(chainID); ok && network.PriceID != "" {
+		coinID = network.PriceID
+	}
+	currency = strings.ToLower(currency)
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	resp, err := httpClient.Get(fmt.Sprintf(DefaultPriceURL, coinID, currency))
+	if err != nil {
+		return 0, fmt.Errorf("price request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read price response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("price API returned HTTP %d", resp.StatusCode)
+	}
+
+	var prices map[string]map[string]float64
+	if err := json.Unmarshal(body, &prices); err != nil {
+		return 0, fmt.Errorf("invalid price response: %w", err)
+	}
+	price, ok := prices[coinID][currency]
+	if !ok || price <= 0 {
+		return 0, fmt.Errorf("no %s price available for %s", strings.ToUpper(currency), coinID)
+	}
+	return price, nil
+}
+
+// weiToFiat converts a Wei amount to fiat at the given native coin price
+func weiToFiat(wei *big.Int, price float64) float64 {
+	value, _ := new(big.Float).Mul(weiToEth(wei), big.NewFloat(price)).Float64()
+	return value
+}
+
+// checkCostCeiling aborts before signing when the maximum gas cost of the
+// transaction exceeds --max-cost-usd (converted at the fetched price) or
+// --max-cost. Without a price, --max-cost is relied on alone; if it is not set
+// either, the operation aborts unless --ignore-price-failure is given.
+func checkCostCeiling(chainID, cost *big.Int, opts TxOptions) error {
+	symbol := nativeSymbol(chainID)
+	if opts.MaxCost != nil {
+		if cost.Cmp(opts.MaxCost) > 0 {
+			return fmt.Errorf("estimated max gas cost %.9f %s exceeds --max-cost %.9f %s, aborting before signing",
+				weiToEth(cost), symbol, weiToEth(opts.MaxCost), symbol)
+		}
+		fmt.Fprintf(promptOutput, "Estimated max gas cost is within --max-cost (%.9f %s)\n", weiToEth(opts.MaxCost), symbol)
+	}
+	if opts.MaxCostUSD <= 0 {
+		return nil
+	}
+
+	price, err := fetchNativePrice(chainID, "usd")
+	if err != nil {
+		switch {
+		case opts.MaxCost != nil:
+			notice(color.FgYellow, "USD price unavailable (%v), relying on --max-cost instead of --max-cost-usd.", err)
+			return nil
+		case opts.IgnorePriceFailure:
+			notice(color.FgYellow, "USD price unavailable (%v), --max-cost-usd not enforced (--ignore-price-failure).", err)
+			return nil
+		}
+		return fmt.Errorf("cannot enforce --max-cost-usd without a USD price (%v); pass --max-cost with a cap in %s, or --ignore-price-failure", err, symbol)
+	}
+
+	usd := weiToFiat(cost, price)
+	if usd > opts.MaxCostUSD {
+		return fmt.Errorf("estimated max gas cost $%.2f exceeds --max-cost-usd $%.2f, aborting before signing", usd, opts.MaxCostUSD)
+	}
+	fmt.Fprintf(promptOutput, "Estimated max gas cost: $%.2f (--max-cost-usd $%.2f)\n", usd, opts.MaxCostUSD)
+	return nil
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// isFlaggedSelector is the selector of the registry query isFlagged(address) returns (bool)
+var isFlaggedSelector = crypto.Keccak256([]byte("isFlagged(address)"))[:4]
+
+// RegistryVerdict is what an on-chain abuse registry says about a delegation target
+type RegistryVerdict int
+
+const (
+	VerdictUnknown    RegistryVerdict = iota // The registry could not be queried
+	VerdictNotFlagged                        // The registry does not list the target
+	VerdictFlagged                           // The registry lists the target as malicious
+)
+
+func (v RegistryVerdict) String() string {
+	switch v {
+	case VerdictNotFlagged:
+		return "not flagged"
+	case VerdictFlagged:
+		return "flagged"
+	default:
+		return "unknown"
+	}
+}
+
+// registryAddress validates the configured registry contract address
+func (o CheckOptions) registryAddress() (common.Address, error) {
+	if !common.IsHexAddress(o.RegistryAddress) {
+		return common.Address{}, invalidInput("--use-onchain-registry requires a valid --registry-address, got %q", o.RegistryAddress)
+	}
+	return common.HexToAddress(o.RegistryAddress), nil
+}
+
+// QueryRegistry asks the registry contract whether target is flagged by calling
+// isFlagged(target). Any failure, including a registry that does not implement
+// the call, yields VerdictUnknown rather than an error.
+func QueryRegistry(rpcURL string, registry, target common.Address) RegistryVerdict {
+	data := append(append([]byte{}, isFlaggedSelector...), common.LeftPadBytes(target.Bytes(), 32)...)
+	result, err := ethCall(rpcURL, registry, data)
+	if err != nil || len(result) < 32 {
+		return VerdictUnknown
+	}
+	switch new(big.Int).SetBytes(result[:32]).Uint64() {
+	case 0:
+		return VerdictNotFlagged
+	case 1:
+		return VerdictFlagged
+	default:
+		return VerdictUnknown
+	}
+}
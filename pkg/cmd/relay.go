@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Relay performs the relay command: it broadcasts an authorization signed on
+// another machine, read from path or from stdin when path is "-", in a type 4
+// transaction signed by the relayer. An authorization for the zero address
+// runs the clear flow and any other the set flow to its address, with the
+// checks of --auth-file and, for set, those of the delegation target.
+func Relay(path string, opts TxOptions) error {
+	if opts.AuthFile != "" {
+		return invalidInput("relay takes the authorization file as its argument, not --auth-file")
+	}
+	data, err := readAuthData(path)
+	if err != nil {
+		return fmt.Errorf("failed to read the authorization: %w", err)
+	}
+	var auth AuthorizationTuple
+	if err := json.Unmarshal(data, &auth); err != nil {
+		return invalidInput("invalid authorization: %v", err)
+	}
+
+	opts.AuthFile = path
+	if auth.Address == (common.Address{}) {
+		fmt.Fprintln(promptOutput, "The authorization clears the delegation of the account that signed it.")
+		fmt.Fprintln(promptOutput, "")
+		return Clear(opts)
+	}
+	fmt.Fprintf(promptOutput, "The authorization delegates the account that signed it to %s.\n\n", auth.Address.Hex())
+	return Set(auth.Address.Hex(), opts)
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// rescueReport summarizes a completed clear for incident documentation
+type rescueReport struct {
+	GeneratedAt      time.Time      `json:"generatedAt"`
+	Address          common.Address `json:"address"`
+	ChainID          string         `json:"chainId"`
+	Chain            string         `json:"chain"`
+	DelegationBefore string         `json:"delegationBefore"` // Delegate address, "none" or "unknown"
+	ClearTxHash      string         `json:"clearTxHash"`
+	ClearStatus      string         `json:"clearStatus"` // confirmed, pending or failed
+	ClearBlock       string         `json:"clearBlock,omitempty"`
+	ClearCost        string         `json:"clearCost,omitempty"` // Wei paid by the relayer
+	Verification     string         `json:"verification"`
+	Sweeps           []sweepRecord  `json:"sweeps"`
+	Redelegations    []redelegation `json:"redelegations"`
+
+	symbol string // Native currency symbol of the chain
+}
+
+// delegationLabel describes the delegation found by a pre-flight check
+func delegationLabel(result *CheckResult, err error) string {
+	switch {
+	case err != nil:
+		return "unknown"
+	case result.Status == StatusDelegated:
+		return result.Delegate.Hex()
+	case result.Status == StatusClean:
+		return "none"
+	default:
+		return "code that is not an EIP-7702 delegation"
+	}
+}
+
+// newRescueReport collects the outcome of a clear
+func newRescueReport(delegationBefore string, result *authResult, waitErr error, redelegations []redelegation) *rescueReport {
+	report := &rescueReport{
+		GeneratedAt:      time.Now().UTC(),
+		Address:          result.User,
+		ChainID:          result.ChainID.String(),
+		Chain:            chainLabel(result.ChainID),
+		DelegationBefore: delegationBefore,
+		ClearTxHash:      result.TxHash,
+		ClearStatus:      txStatus(result.Receipt, waitErr),
+		Verification:     result.Verification,
+		Sweeps:           result.Sweeps,
+		Redelegations:    redelegations,
+		symbol:           nativeSymbol(result.ChainID),
+	}
+	if report.Verification == "" {
+		report.Verification = "not run"
+	}
+	if report.Sweeps == nil {
+		report.Sweeps = []sweepRecord{}
+	}
+	if report.Redelegations == nil {
+		report.Redelegations = []redelegation{}
+	}
+	if receipt := result.Receipt; receipt != nil {
+		if block, ok := new(big.Int).SetString(strings.TrimPrefix(receipt.BlockNumber, "0x"), 16); ok {
+			report.ClearBlock = block.String()
+		}
+		if cost := receipt.cost(); cost != nil {
+			report.ClearCost = cost.String()
+		}
+	}
+	return report
+}
+
+// markdown renders the report for humans
+func (r *rescueReport) markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# EIP-7702 rescue report\n\n")
+	fmt.Fprintf(&b, "Generated %s\n\n", r.GeneratedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "| | |\n|---|---|\n")
+	fmt.Fprintf(&b, "| Address | `%s` |\n", r.Address.Hex())
+	fmt.Fprintf(&b, "| Chain | %s |\n", r.Chain)
+	fmt.Fprintf(&b, "| Delegation before | %s |\n", r.DelegationBefore)
+	fmt.Fprintf(&b, "| Clear transaction | `%s` |\n", r.ClearTxHash)
+	fmt.Fprintf(&b, "| Clear status | %s |\n", r.ClearStatus)
+	if r.ClearBlock != "" {
+		fmt.Fprintf(&b, "| Clear block | %s |\n", r.ClearBlock)
+	}
+	if r.ClearCost != "" {
+		cost, _ := new(big.Int).SetString(r.ClearCost, 10)
+		fmt.Fprintf(&b, "| Clear cost | %.9f %s (%s wei) |\n", weiToEth(cost), r.symbol, r.ClearCost)
+	}
+	fmt.Fprintf(&b, "| Verification | %s |\n", r.Verification)
+
+	fmt.Fprintf(&b, "\n## Funds swept\n\n")
+	if len(r.Sweeps) == 0 {
+		fmt.Fprintf(&b, "None.\n")
+	} else {
+		fmt.Fprintf(&b, "| Asset | Amount | Raw amount (wei or token units) | Transaction | Status |\n|---|---|---|---|---|\n")
+		for _, s := range r.Sweeps {
+			fmt.Fprintf(&b, "| %s | %s | %s | `%s` | %s |\n", s.Asset, s.Display, s.Amount, s.TxHash, s.Status)
+		}
+	}
+
+	fmt.Fprintf(&b, "\n## Re-delegations after the clear\n\n")
+	if len(r.Redelegations) == 0 {
+		fmt.Fprintf(&b, "None detected.\n")
+	} else {
+		for _, d := range r.Redelegations {
+			fmt.Fprintf(&b, "- Block %d: `%s` delegated the address to `%s` (sent by `%s`)\n", d.Block, d.TxHash, d.Target.Hex(), d.From)
+		}
+	}
+	return b.String()
+}
+
+// writeRescueReport writes the report as Markdown when path ends in .md, as JSON otherwise
+func writeRescueReport(path string, report *rescueReport) error {
+	var data []byte
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		data = []byte(report.markdown())
+	default:
+		var err error
+		if data, err = json.MarshalIndent(report, "", "  "); err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write rescue report: %w", err)
+	}
+	fmt.Fprintf(promptOutput, "\nRescue report written to %s\n", path)
+	return nil
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// minReplacementBump is the minimum fee increase, in percent, that nodes
+// require before a transaction replaces a pending one with the same nonce
+const minReplacementBump = 10
+
+// ParseBumpSchedule parses a comma separated list of fee increases in percent, e.g. "12,25,50"
+func ParseBumpSchedule(list string) ([]float64, error) {
+	var schedule []float64
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(item), "%"))
+		if item == "" {
+			continue
+		}
+		percent, err := strconv.ParseFloat(item, 64)
+		if err != nil || percent <= 0 {
+			return nil, invalidInput("invalid fee bump %q: expected a positive percentage", item)
+		}
+		schedule = append(schedule, percent)
+	}
+	return schedule, nil
+}
+
+// minReplacementFee returns the lowest fee a replacement of a transaction paying fee is accepted with
+func minReplacementFee(fee *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(fee, big.NewInt(100+minReplacementBump))
+	bumped.Add(bumped, big.NewInt(99))
+	return bumped.Div(bumped, big.NewInt(100))
+}
+
+// replacementFees returns the fees of a resubmission: the original fees bumped
+// by percent, raised where needed to the replacement minimum over the previous
+// attempt, with the max fee per gas capped at maxFeeCap. ok is false when the
+// cap leaves no room for a replacement the node would accept.
+func replacementFees(originalTip, originalFeeCap, previousTip, previousFeeCap *big.Int, percent float64, maxFeeCap *big.Int) (gasTip, gasFeeCap *big.Int, ok bool) {
+	factor := 1 + percent/100
+	gasTip = bigMax(bumpFee(originalTip, factor), minReplacementFee(previousTip))
+	gasFeeCap = bigMax(bumpFee(originalFeeCap, factor), minReplacementFee(previousFeeCap))
+
+	if maxFeeCap != nil && gasFeeCap.Cmp(maxFeeCap) > 0 {
+		gasFeeCap = new(big.Int).Set(maxFeeCap)
+	}
+	if gasTip.Cmp(gasFeeCap) > 0 {
+		gasTip = new(big.Int).Set(gasFeeCap)
+	}
+
+	ok = gasTip.Cmp(minReplacementFee(previousTip)) >= 0 && gasFeeCap.Cmp(minReplacementFee(previousFeeCap)) >= 0
+	return gasTip, gasFeeCap, ok
+}
+
+// bigMax returns the larger of two amounts
+func bigMax(a, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
+
+// waitWithResubmission waits for an authorization like waitForMined. Each time
+// the wait ends with the transaction still not included, it is signed again
+// with the fees of the next step of opts.BumpSchedule and resubmitted with the
+// same nonces, then the wait starts over for the replacement.
+func waitWithResubmission(ctx context.Context, rpcURL string, result *authResult, opts TxOptions) (*TransactionReceipt, error) {
+	receipt, phase, err := waitForPhase(ctx, rpcURL, result.TxHash, opts)
+	if result.resign == nil {
+		return receipt, err
+	}
+
+	originalTip, originalFeeCap := result.GasTip, result.GasFeeCap
+	for _, percent := range opts.BumpSchedule {
+		if receipt != nil || err != nil || phase >= phaseIncluded || ctx.Err() != nil {
+			break
+		}
+
+		gasTip, gasFeeCap, ok := replacementFees(originalTip, originalFeeCap, result.GasTip, result.GasFeeCap, percent, opts.MaxFeeCap)
+		if !ok {
+			notice(color.FgYellow, "\n--max-fee-cap leaves no room for another replacement, resubmission stopped.")
+			break
+		}
+		notice(color.FgYellow, "\nResubmitting with the fees bumped by %g%%: priority fee %.6f Gwei, max fee per gas %.6f Gwei",
+			percent, weiToGwei(gasTip), weiToGwei(gasFeeCap))
+
+		signedTx, signErr := result.resign(gasTip, gasFeeCap)
+		if signErr != nil {
+			return nil, fmt.Errorf("failed to sign the replacement transaction: %w", signErr)
+		}
+		txHash, sendErr := broadcastRawTx(ctx, signedTx, rpcURL)
+		if sendErr != nil {
+			// The original may have been mined in the meantime, keep following it
+			notice(color.FgYellow, "The replacement was rejected: %v", sendErr)
+		} else {
+			result.TxHash, result.GasTip, result.GasFeeCap = txHash, gasTip, gasFeeCap
+			printTxHash("Replacement transaction sent! Transaction hash:", txHash)
+		}
+		receipt, phase, err = waitForPhase(ctx, rpcURL, result.TxHash, opts)
+	}
+	return receipt, err
+}
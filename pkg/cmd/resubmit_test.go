@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestParseBumpSchedule(t *testing.T) {
+	tests := []struct {
+		name string
+		list string
+		want []float64
+		err  bool
+	}{
+		{"percentages", "12,25,50", []float64{12, 25, 50}, false},
+		{"percent signs and spaces", " 10%, 20 % ,12.5", []float64{10, 20, 12.5}, false},
+		{"empty items skipped", "12,,25,", []float64{12, 25}, false},
+		{"empty", "", nil, false},
+		{"not a number", "12,abc", nil, true},
+		{"zero", "0", nil, true},
+		{"negative", "-5", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBumpSchedule(tt.list)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("ParseBumpSchedule(%q) = %v, want an error", tt.list, got)
+				}
+				if ErrorType(err) != ErrorTypeInvalidInput {
+					t.Fatalf("ErrorType(%v) = %q, want %q", err, ErrorType(err), ErrorTypeInvalidInput)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseBumpSchedule(%q) = %v, want %v", tt.list, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinReplacementFee(t *testing.T) {
+	tests := []struct {
+		fee, want int64
+	}{
+		{0, 0},
+		{1, 2},
+		{15, 17},
+		{100, 110},
+		{1000000000, 1100000000},
+	}
+	for _, tt := range tests {
+		if got := minReplacementFee(big.NewInt(tt.fee)); got.Int64() != tt.want {
+			t.Errorf("minReplacementFee(%d) = %s, want %d", tt.fee, got, tt.want)
+		}
+	}
+}
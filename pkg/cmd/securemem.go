@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"sync"
+	"unsafe"
+
+	"github.com/fatih/color"
+)
+
+// lockedBuffer is memory allocated outside the Go heap and locked into RAM: the
+// garbage collector never moves or copies it, it is not written to swap, and
+// it is wiped before being released
+type lockedBuffer struct {
+	data []byte
+}
+
+var (
+	lockedKeysMu sync.Mutex
+	// lockedKeys maps the keys whose secret scalar was moved by lockKey to its memory
+	lockedKeys = map[*ecdsa.PrivateKey]*lockedBuffer{}
+	// lockWarning is shown once when locked memory is not available
+	lockWarning sync.Once
+)
+
+// lockKey moves the secret scalar of a freshly parsed private key into locked
+// memory and wipes the heap copy, so the key stays out of swap until zeroKey
+// releases it; DisableCoreDumps keeps it out of core dumps. When locked memory
+// is not available, e.g. because of a low RLIMIT_MEMLOCK, the key is left as it
+// is after a one-time warning.
+func lockKey(key *ecdsa.PrivateKey) *ecdsa.PrivateKey {
+	words := key.D.Bits()
+	wordSize := int(unsafe.Sizeof(big.Word(0)))
+	buffer, err := newLockedBuffer(len(words) * wordSize)
+	if err != nil {
+		lockWarning.Do(func() {
+			notice(color.FgYellow, "Warning: keys are kept in ordinary memory, locking it failed: %v", err)
+		})
+		return key
+	}
+	locked := unsafe.Slice((*big.Word)(unsafe.Pointer(&buffer.data[0])), len(words))
+	copy(locked, words)
+	for i := range words {
+		words[i] = 0
+	}
+	// SetBits makes locked the storage of D, big.Int arithmetic on D would
+	// reallocate it but keys are only read once parsed
+	key.D.SetBits(locked)
+
+	lockedKeysMu.Lock()
+	lockedKeys[key] = buffer
+	lockedKeysMu.Unlock()
+	return key
+}
+
+// releaseLockedKey wipes and frees the locked memory of a key moved by lockKey,
+// after detaching it from the key so it is never used once unmapped
+func releaseLockedKey(key *ecdsa.PrivateKey) {
+	lockedKeysMu.Lock()
+	buffer, ok := lockedKeys[key]
+	delete(lockedKeys, key)
+	lockedKeysMu.Unlock()
+	if !ok {
+		return
+	}
+	key.D = new(big.Int)
+	buffer.destroy()
+}
+
+// destroy wipes the buffer, then unlocks and frees its memory
+func (b *lockedBuffer) destroy() {
+	zeroBytes(b.data)
+	freeLockedMemory(b.data)
+	b.data = nil
+}
+
+// newLockedBuffer allocates size bytes of locked memory, at least one byte
+func newLockedBuffer(size int) (*lockedBuffer, error) {
+	data, err := allocLockedMemory(max(size, 1))
+	if err != nil {
+		return nil, err
+	}
+	return &lockedBuffer{data: data[:max(size, 1)]}, nil
+}
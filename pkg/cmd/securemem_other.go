@@ -0,0 +1,18 @@
+//go:build !unix && !windows
+
+package cmd
+
+import "errors"
+
+// DisableCoreDumps is not available on this platform, and does nothing
+func DisableCoreDumps() error {
+	return nil
+}
+
+// allocLockedMemory is not available on this platform
+func allocLockedMemory(size int) ([]byte, error) {
+	return nil, errors.New("locked memory is not supported on this platform")
+}
+
+// freeLockedMemory is never called on this platform
+func freeLockedMemory(data []byte) {}
@@ -0,0 +1,36 @@
+//go:build unix
+
+package cmd
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// DisableCoreDumps sets the core file size limit of the process to zero, so a
+// crash never writes the keys held in memory to disk
+func DisableCoreDumps() error {
+	return unix.Setrlimit(unix.RLIMIT_CORE, &unix.Rlimit{Cur: 0, Max: 0})
+}
+
+// allocLockedMemory maps anonymous pages and locks them into RAM
+func allocLockedMemory(size int) ([]byte, error) {
+	pageSize := os.Getpagesize()
+	data, err := unix.Mmap(-1, 0, (size+pageSize-1)/pageSize*pageSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Mlock(data); err != nil {
+		unix.Munmap(data)
+		return nil, err
+	}
+	return data, nil
+}
+
+// freeLockedMemory unlocks and unmaps memory from allocLockedMemory
+func freeLockedMemory(data []byte) {
+	data = data[:cap(data)]
+	unix.Munlock(data)
+	unix.Munmap(data)
+}
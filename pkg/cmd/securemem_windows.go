@@ -0,0 +1,39 @@
+//go:build windows
+
+package cmd
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// DisableCoreDumps does nothing on Windows, which has no core file size limit;
+// crash dumps are configured system-wide through Windows Error Reporting
+func DisableCoreDumps() error {
+	return nil
+}
+
+// allocLockedMemory allocates pages with VirtualAlloc and locks them into RAM
+func allocLockedMemory(size int) ([]byte, error) {
+	addr, err := windows.VirtualAlloc(0, uintptr(size), windows.MEM_COMMIT|windows.MEM_RESERVE, windows.PAGE_READWRITE)
+	if err != nil {
+		return nil, err
+	}
+	if err := windows.VirtualLock(addr, uintptr(size)); err != nil {
+		windows.VirtualFree(addr, 0, windows.MEM_RELEASE)
+		return nil, err
+	}
+	// The pages are outside the Go heap, never moved or collected. The address is
+	// read in place as the pointer it is rather than converted from a uintptr.
+	data := *(*unsafe.Pointer)(unsafe.Pointer(&addr))
+	return unsafe.Slice((*byte)(data), size), nil
+}
+
+// freeLockedMemory unlocks and frees memory from allocLockedMemory
+func freeLockedMemory(data []byte) {
+	data = data[:cap(data)]
+	addr := uintptr(unsafe.Pointer(&data[0]))
+	windows.VirtualUnlock(addr, uintptr(len(data)))
+	windows.VirtualFree(addr, 0, windows.MEM_RELEASE)
+}
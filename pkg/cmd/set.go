@@ -1,182 +1,228 @@
 package cmd
 
 import (
+	"crypto/ecdsa"
 	"fmt"
-	"math/big"
-	"strings"
-	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/fatih/color"
 )
 
 // Set performs the set command to authorize a specific contract address
-func Set(contractAddress string, rpcURL string, gasLimit uint64) error {
-	if rpcURL == "" {
-		rpcURL = DefaultRPCURL
-	}
-
+func Set(contractAddress string, opts TxOptions) error {
 	// Validate the contract address
 	if !common.IsHexAddress(contractAddress) {
-		return fmt.Errorf("invalid contract address format: %s", contractAddress)
+		return invalidInput("invalid contract address format: %s", contractAddress)
 	}
 
 	templateAddress := common.HexToAddress(contractAddress)
 
-	// Explain why we need two private keys
-	fmt.Println("We will need two private keys to set the EIP-7702 authorization:")
-	fmt.Println("")
-	fmt.Println("1. The private key of the address that will be authorized to use the contract.")
-	fmt.Println("   This is required to sign the authorization transaction.")
-	fmt.Println("")
-	fmt.Println("2. The private key of a separate address to pay for gas fees.")
-	fmt.Println("   This address will broadcast the transaction and pay for gas.")
-	fmt.Println("")
-	fmt.Printf("The authorization will allow the first address to execute code from: %s\n", templateAddress.Hex())
-	fmt.Println("")
-
-	// Get user private key
-	color.Yellow("Please enter the private key of the address to be authorized:")
-	userPrivateKeyHex, err := readPrivateKey()
-	if err != nil {
-		return fmt.Errorf("error reading user private key: %w", err)
+	if opts.VerifyOnly {
+		if opts.Address == "" {
+			return invalidInput("--verify-only requires the --address to verify")
+		}
+		return Verify(opts.Address, "delegated", contractAddress, CheckOptions{RPCURL: opts.RPCURL})
 	}
-
-	userPrivateKey, err := crypto.HexToECDSA(strings.TrimPrefix(userPrivateKeyHex, "0x"))
-	if err != nil {
-		return fmt.Errorf("invalid user private key: %w", err)
+	if err := opts.validateCall(); err != nil {
+		return err
 	}
-
-	// Get relayer private key
-	fmt.Println("\nPlease enter the private key of the address that will pay for gas fees:")
-	relayerPrivateKeyHex, err := readPrivateKey()
-	if err != nil {
-		return fmt.Errorf("error reading relayer private key: %w", err)
+	if opts.Offline != nil {
+		return signOffline(setAction(templateAddress), opts)
 	}
 
-	relayerPrivateKey, err := crypto.HexToECDSA(strings.TrimPrefix(relayerPrivateKeyHex, "0x"))
-	if err != nil {
-		return fmt.Errorf("invalid relayer private key: %w", err)
+	if err := checkTemplateCode(templateAddress, opts); err != nil {
+		return err
 	}
 
-	// Get addresses from private keys
-	userAddress := crypto.PubkeyToAddress(userPrivateKey.PublicKey)
-	relayerAddress := crypto.PubkeyToAddress(relayerPrivateKey.PublicKey)
+	out := opts.console()
 
-	fmt.Printf("\nUser address (to be authorized): %s\n", userAddress.Hex())
-	fmt.Printf("Relayer address (pays gas): %s\n", relayerAddress.Hex())
-	fmt.Printf("Contract address (to authorize): %s\n", templateAddress.Hex())
+	// Explain why we need two private keys
+	if opts.SelfSponsor {
+		out.info("Self-sponsored mode (--relayer-same-as-user): one private key signs the authorization")
+		out.info("and pays for gas from the same address.")
+		out.info("")
+	} else if opts.AuthFile != "" {
+		out.infof("The authorization is read already signed from %s, so only the private key\n", authFileLabel(opts.AuthFile))
+		out.info("of a separate address to pay for gas fees is needed.")
+		out.info("")
+	} else {
+		out.info("We will need two private keys to set the EIP-7702 authorization:")
+		out.info("")
+		out.info("1. The private key of the address that will be authorized to use the contract.")
+		out.info("   This is required to sign the authorization transaction.")
+		out.info("")
+		out.info("2. The private key of a separate address to pay for gas fees.")
+		out.info("   This address will broadcast the transaction and pay for gas.")
+		out.info("")
+	}
+	out.infof("The authorization will allow the first address to execute code from: %s\n", templateAddress.Hex())
+	out.info("")
 
-	// Get chain ID
-	chainID, err := getChainID(rpcURL)
-	if err != nil {
-		return fmt.Errorf("failed to get chain ID: %w", err)
+	if err := opts.validateBundleOut(); err != nil {
+		return err
+	}
+	if err := opts.validateTxOut(); err != nil {
+		return err
+	}
+	if err := opts.validateBroadcastAt(); err != nil {
+		return err
+	}
+	if err := opts.validateNoWait(); err != nil {
+		return err
+	}
+	if err := opts.validateNonceOverrides(); err != nil {
+		return err
+	}
+	opts.warnGasLimit(1)
+	if err := opts.validateKeystores(); err != nil {
+		return err
+	}
+	if err := opts.validateExpectAddress(); err != nil {
+		return err
+	}
+	if opts.SelfSponsor && opts.Batch {
+		return invalidInput("--relayer-same-as-user cannot be combined with --batch")
+	}
+	if opts.CheckpointFile != "" && !opts.Batch {
+		return invalidInput("--checkpoint-file requires --batch")
+	}
+	if opts.OnError != "" && !opts.Batch {
+		return invalidInput("--on-error requires --batch")
+	}
+	if opts.SelfSponsor && opts.RelayerSignerURL != "" {
+		return invalidInput("--relayer-same-as-user cannot be combined with --relayer-signer-url")
+	}
+	previewAuthorization(setAction(templateAddress), opts)
+	if opts.Batch {
+		return runBatch("address to be authorized", opts, func(userPrivateKey *ecdsa.PrivateKey, relayer *relayerSession) (func() error, error) {
+			return startSet(keyAuthorizer(userPrivateKey), relayer, templateAddress, opts)
+		})
 	}
-	fmt.Printf("\nChain ID: %d\n", chainID)
 
-	// Get nonces
-	userNonce, err := getNonce(rpcURL, userAddress.Hex())
+	// Get user private key, or the authorization it signed
+	user, err := opts.loadAuthorizer(templateAddress, "user", color.FgYellow, "Please enter the private key of the address to be authorized:")
 	if err != nil {
-		return fmt.Errorf("failed to get user nonce: %w", err)
+		return err
+	}
+	defer zeroKey(user.key)
+	if err := checkExpectedAccount(setAction(templateAddress), user, opts); err != nil {
+		return err
 	}
 
-	relayerNonce, err := getNonce(rpcURL, relayerAddress.Hex())
+	// Get relayer private key
+	relayer, release, err := readRelayer(user.key, "\nPlease enter the private key of the address that will pay for gas fees:", opts)
 	if err != nil {
-		return fmt.Errorf("failed to get relayer nonce: %w", err)
+		return err
 	}
+	defer release()
 
-	fmt.Printf("User nonce: %d\n", userNonce)
-	fmt.Printf("Relayer nonce: %d\n", relayerNonce)
+	return setAccount(user, relayer, templateAddress, opts)
+}
 
-	// Get gas parameters using EIP-1559 compatible method
-	fmt.Println("\nFetching gas parameters from the network...")
-	gasTip, gasFeeCap, err := getSuggestedGasFees(rpcURL)
+// setAccount delegates one user address to the template contract
+func setAccount(user authorizer, relayer *relayerSession, templateAddress common.Address, opts TxOptions) error {
+	finish, err := startSet(user, relayer, templateAddress, opts)
 	if err != nil {
-		return fmt.Errorf("failed to get suggested gas fees: %w", err)
+		return err
 	}
+	return finish()
+}
 
-	// Use the provided gas limit
-	fmt.Printf("Using gas limit: %d\n", gasLimit)
-
-	// Convert Wei to Gwei for display (1 Gwei = 10^9 Wei)
-	weiToGwei := new(big.Float).SetFloat64(1000000000)
-
-	gasTipGwei := new(big.Float).SetInt(gasTip)
-	gasTipGwei.Quo(gasTipGwei, weiToGwei)
-
-	gasFeeCapGwei := new(big.Float).SetInt(gasFeeCap)
-	gasFeeCapGwei.Quo(gasFeeCapGwei, weiToGwei)
+// setAction describes the set flow for a template contract to broadcastAuthorization
+// and awaitAuthorization
+func setAction(templateAddress common.Address) authAction {
+	return authAction{
+		Template:      templateAddress, // Set to specific contract address
+		UserLabel:     "User",
+		Confirm:       "Are you sure you want to set the EIP-7702 authorization for this address?",
+		WarnOnConfirm: true,
+		Generating:    "Generating EIP-7702 authorization transaction...",
+		Done:          "set",
+	}
+}
 
-	// Calculate total max gas cost in ETH
-	totalGasWei := new(big.Float).SetInt(gasFeeCap)
-	totalGasWei.Mul(totalGasWei, new(big.Float).SetUint64(gasLimit))
+// startSet broadcasts the authorization of one user address and returns the step that waits for it
+func startSet(user authorizer, relayer *relayerSession, templateAddress common.Address, opts TxOptions) (func() error, error) {
+	userAddress := user.address()
 
-	// 1 ETH = 10^18 Wei
-	weiToEth := new(big.Float).SetFloat64(1000000000000000000)
-	totalGasEth := new(big.Float).Set(totalGasWei)
-	totalGasEth.Quo(totalGasEth, weiToEth)
+	fmt.Fprintf(promptOutput, "\nUser address (to be authorized): %s\n", userAddress.Hex())
+	fmt.Fprintf(promptOutput, "Relayer address (pays gas): %s\n", relayer.Address().Hex())
+	fmt.Fprintf(promptOutput, "Contract address (to authorize): %s\n", templateAddress.Hex())
+	if len(opts.CallData) > 0 || opts.CallValue != nil {
+		fmt.Fprintf(promptOutput, "Call to the user address, run with the contract's code: %d bytes of calldata\n", len(opts.CallData))
+	}
 
-	fmt.Printf("\nGas Information:\n")
-	fmt.Printf("Max fee per gas: %.6f Gwei\n", gasFeeCapGwei)
-	fmt.Printf("Priority fee: %.6f Gwei\n", gasTipGwei)
-	fmt.Printf("Gas limit: %d\n", gasLimit)
-	fmt.Printf("Estimated max gas cost: %.9f ETH\n", totalGasEth)
+	if err := checkTemplateNotSigner(templateAddress, userAddress, relayer.Address(), opts); err != nil {
+		return nil, err
+	}
 
-	// Confirm with user
-	color.Yellow("\nAre you sure you want to set the EIP-7702 authorization for this address? (y/n)")
-	var confirmation string
-	fmt.Scanln(&confirmation)
-	if strings.ToLower(confirmation) != "y" && strings.ToLower(confirmation) != "yes" {
-		return fmt.Errorf("operation cancelled by user")
+	action := setAction(templateAddress)
+	result, err := broadcastAuthorization(action, user, relayer, opts)
+	if err != nil {
+		return nil, err
 	}
+	return func() error {
+		return awaitAuthorization(action, result, opts)
+	}, nil
+}
 
-	// Create EIP-7702 authorization request
-	req := SetAuthorizationRequest{
-		UserEOAPrivateKey:    userPrivateKey,
-		UserEOANonce:         uint64(userNonce),
-		RelayerEOAPrivateKey: relayerPrivateKey,
-		RelayerNonce:         uint64(relayerNonce),
-		TemplateAddress:      templateAddress, // Set to specific contract address
-		ChainId:              chainID,
-		GasTip:               gasTip,
-		GasFeeCap:            gasFeeCap,
-		GasLimit:             gasLimit,
+// checkTemplateNotSigner catches a contract address pasted in place of one of
+// the signers. Delegating an account to itself is refused unless
+// AllowSelfTarget is set, delegating it to the relayer only warned about.
+func checkTemplateNotSigner(templateAddress, userAddress, relayerAddress common.Address, opts TxOptions) error {
+	if templateAddress == userAddress && !opts.AllowSelfTarget {
+		return invalidInput("the contract address %s is the address being authorized, refusing to delegate an account to itself (use --allow-self-delegation to override)", templateAddress.Hex())
+	}
+	if templateAddress == relayerAddress && templateAddress != userAddress {
+		notice(color.FgYellow, "Warning: the contract address %s is the relayer address, check that it was not pasted by mistake", templateAddress.Hex())
 	}
+	return nil
+}
 
-	fmt.Println("\nGenerating EIP-7702 authorization transaction...")
-	signedTx, err := GenerateSet7702AuthTx(req)
+// checkTemplateCode refuses a delegation target without contract code unless
+// AllowEmptyTarget is set: delegating to an EOA or an unused address leaves the
+// account without working code and is almost always a mistake, or an attack.
+// When ExpectedCodeHash is set, the keccak256 of the target's code must match it,
+// so only the reviewed implementation can be delegated to.
+func checkTemplateCode(templateAddress common.Address, opts TxOptions) error {
+	pinned := opts.ExpectedCodeHash != (common.Hash{})
+	if opts.AllowEmptyTarget && !pinned {
+		return nil
+	}
+	result, err := CheckAddress(templateAddress.Hex(), CheckOptions{RPCURL: opts.RPCURL})
 	if err != nil {
-		return fmt.Errorf("failed to generate transaction: %w", err)
+		return fmt.Errorf("failed to read the code of %s: %w", templateAddress.Hex(), err)
 	}
 
-	fmt.Println("Broadcasting transaction...")
-	txHash, err := broadcastRawTx(signedTx, rpcURL)
-	if err != nil {
-		return fmt.Errorf("failed to broadcast transaction: %w", err)
-	}
-
-	color.Green("\nTransaction successfully sent!")
-	color.Green("Transaction hash: %s", txHash)
-
-	fmt.Println("\nWaiting for transaction to be mined...")
-	// Wait for the transaction to be mined
-	for i := 0; i < 60; i++ { // Try for 5 minutes (60 * 5 seconds)
-		time.Sleep(5 * time.Second)
-		receipt, err := getTransactionReceipt(rpcURL, txHash)
-		if err == nil && receipt != nil {
-			if receipt.Status == "0x1" {
-				color.Green("\nTransaction successfully mined!")
-				break
-			} else if receipt.Status == "0x0" {
-				return fmt.Errorf("transaction failed: %s", txHash)
-			}
+	if !opts.AllowEmptyTarget {
+		switch result.Status {
+		case StatusClean:
+			return fmt.Errorf("%s has no contract code, refusing to delegate to it (use --allow-empty-target to override)", templateAddress.Hex())
+		case StatusDelegated:
+			// Delegations are not followed, the account would execute nothing
+			return fmt.Errorf("%s is itself an EIP-7702 delegated account, not a contract, refusing to delegate to it (use --allow-empty-target to override)", templateAddress.Hex())
 		}
-		fmt.Print(".")
 	}
 
-	fmt.Println("\nTo verify the EIP-7702 authorization has been set, run:")
-	fmt.Printf("eip7702cleaner check %s --rpc-url %s\n", userAddress.Hex(), rpcURL)
-
+	if pinned {
+		codeHash := crypto.Keccak256Hash(result.Code)
+		if codeHash != opts.ExpectedCodeHash {
+			notice(color.FgRed, "✗ Code hash of %s: %s", templateAddress.Hex(), codeHash.Hex())
+			notice(color.FgRed, "  Expected (--expected-code-hash): %s", opts.ExpectedCodeHash.Hex())
+			return fmt.Errorf("%s does not run the expected implementation, refusing to delegate to it", templateAddress.Hex())
+		}
+		notice(color.FgGreen, "✓ Code hash of %s matches --expected-code-hash", templateAddress.Hex())
+	}
 	return nil
 }
+
+// ParseCodeHash parses a 32-byte hex hash such as a value for --expected-code-hash
+func ParseCodeHash(value string) (common.Hash, error) {
+	raw, err := hexutil.Decode(value)
+	if err != nil || len(raw) != common.HashLength {
+		return common.Hash{}, invalidInput("invalid code hash %q: expected 0x followed by 64 hex characters", value)
+	}
+	return common.BytesToHash(raw), nil
+}
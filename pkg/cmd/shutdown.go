@@ -0,0 +1,9 @@
+package cmd
+
+// Shutdown exports the remaining trace spans. It must be called before the
+// process exits, including on Ctrl+C. Files the tool writes, such as reports,
+// bundles and checkpoints, are written whole in a single call, so there is no
+// buffered output left to flush.
+func Shutdown() {
+	stopTracing()
+}
@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/fatih/color"
+)
+
+// Signer signs digests for an account without exposing how its key is held
+type Signer interface {
+	// Address returns the account the signatures recover to
+	Address() common.Address
+	// SignHash signs a 32-byte digest and returns the 65-byte [R || S || V]
+	// signature, with V being the recovery id (0 or 1)
+	SignHash(hash []byte) ([]byte, error)
+}
+
+// PayloadSigner is implemented by Signers that must see what they sign, such as
+// hardware wallets that show the transaction for approval, rather than its hash
+type PayloadSigner interface {
+	Signer
+	// SignPayload signs an EIP-2718 signing payload, the type byte followed by
+	// the RLP of the unsigned transaction, and returns the signature like SignHash
+	SignPayload(payload []byte) ([]byte, error)
+}
+
+// KeySigner is a Signer backed by an in-memory private key
+type KeySigner struct {
+	key *ecdsa.PrivateKey
+}
+
+// NewKeySigner returns a Signer for a private key. The key stays owned by the
+// caller, who remains responsible for wiping it with zeroKey.
+func NewKeySigner(key *ecdsa.PrivateKey) *KeySigner {
+	return &KeySigner{key: key}
+}
+
+// Address returns the address of the private key
+func (s *KeySigner) Address() common.Address {
+	return crypto.PubkeyToAddress(s.key.PublicKey)
+}
+
+// SignHash signs the digest with the private key
+func (s *KeySigner) SignHash(hash []byte) ([]byte, error) {
+	return crypto.Sign(hash, s.key)
+}
+
+// RemoteSignMethod is the JSON-RPC method a remote signer must expose: it takes
+// the account address and a 32-byte digest, and returns the 65-byte signature
+// of the raw digest, without any EIP-191 prefix
+const RemoteSignMethod = "eth_signHash"
+
+// RemoteSigner is a Signer that delegates signing to a signer daemon over JSON-RPC,
+// so the key never enters this process
+type RemoteSigner struct {
+	url     string
+	address common.Address
+}
+
+// NewRemoteSigner returns a Signer for an account held by the signer at url.
+// When address is empty, the signer must manage exactly one account, read with eth_accounts.
+func NewRemoteSigner(url, address string) (*RemoteSigner, error) {
+	if address != "" {
+		if !common.IsHexAddress(address) {
+			return nil, invalidInput("invalid signer address format: %s", address)
+		}
+		return &RemoteSigner{url: url, address: common.HexToAddress(address)}, nil
+	}
+
+	var accounts []common.Address
+	if err := remoteSignerCall(url, "eth_accounts", []interface{}{}, &accounts); err != nil {
+		return nil, fmt.Errorf("failed to list the accounts of the remote signer: %w", err)
+	}
+	if len(accounts) != 1 {
+		return nil, invalidInput("the remote signer manages %d accounts, select one with --relayer-signer-address", len(accounts))
+	}
+	return &RemoteSigner{url: url, address: accounts[0]}, nil
+}
+
+// Address returns the account the remote signer signs for
+func (s *RemoteSigner) Address() common.Address {
+	return s.address
+}
+
+// SignHash asks the remote signer to sign the digest, normalizes the recovery
+// id of the signature to 0 or 1, and checks that it recovers to the account
+func (s *RemoteSigner) SignHash(hash []byte) ([]byte, error) {
+	var result string
+	params := []interface{}{s.address.Hex(), "0x" + hex.EncodeToString(hash)}
+	if err := remoteSignerCall(s.url, RemoteSignMethod, params, &result); err != nil {
+		return nil, fmt.Errorf("remote signer failed: %w", err)
+	}
+	sig, err := hex.DecodeString(strings.TrimPrefix(result, "0x"))
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return nil, fmt.Errorf("remote signer returned an invalid signature %q", result)
+	}
+	// Signers following the Ethereum convention return 27 or 28
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	pub, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return nil, fmt.Errorf("remote signer returned an unrecoverable signature: %w", err)
+	}
+	if signer := crypto.PubkeyToAddress(*pub); signer != s.address {
+		return nil, fmt.Errorf("remote signature recovers to %s instead of %s; the signer must sign the raw digest, without a message prefix", signer.Hex(), s.address.Hex())
+	}
+	return sig, nil
+}
+
+// remoteSignerCall makes a JSON-RPC call to a signer and decodes its result
+func remoteSignerCall(url, method string, params []interface{}, result interface{}) error {
+	body := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  method,
+		"params":  params,
+	}
+	// The signer holds the key, the chain fallback endpoints cannot stand in for it
+	responseBody, err := callEndpoints(context.Background(), []string{url}, body)
+	if err != nil {
+		return err
+	}
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(responseBody, &response); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("%s: %s", method, response.Error.Message)
+	}
+	if len(response.Result) == 0 || bytes.Equal(response.Result, []byte("null")) {
+		return fmt.Errorf("%s returned no result", method)
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+// nonceManager hands out consecutive nonces for an account within a session,
+// so several transactions can be sent without waiting for the node to catch up
+type nonceManager struct {
+	rpcURL  string
+	address common.Address
+	next    uint64
+	synced  bool
+}
+
+// Next returns the nonce of the next transaction, reading it from the node on first use.
+// The pending count is used so transactions still in the mempool are accounted for.
+func (m *nonceManager) Next() (uint64, error) {
+	if !m.synced {
+		nonce, err := getNonceAt(m.rpcURL, m.address.Hex(), "pending")
+		if err != nil {
+			return 0, err
+		}
+		m.next = uint64(nonce)
+		m.synced = true
+	}
+	return m.next, nil
+}
+
+// Commit records that the nonce returned by Next was used by a broadcast transaction
+func (m *nonceManager) Commit() {
+	m.next++
+}
+
+// Pin sets the nonce of the next transaction instead of reading it from the
+// node, for a transaction still pending or one to replace
+func (m *nonceManager) Pin(nonce uint64) {
+	m.next = nonce
+	m.synced = true
+}
+
+// Reset drops the local counter, the next call to Next re-reads it from the node
+func (m *nonceManager) Reset() {
+	m.synced = false
+}
+
+// readRelayer sets up the relayer: the remote signer at RelayerSignerURL, the
+// Azure Key Vault key at RelayerAzureKey, the Fireblocks vault account
+// FireblocksVault, the Ledger with RelayerLedger, the user's own key with SelfSponsor, the key of
+// the Vault secret at RelayerVaultPath, or else a private key prompted for.
+// prompt introduces the key prompt. The returned function wipes a loaded key or
+// releases the Ledger. The first nonce is RelayerNonce when given, VictimNonce
+// with SelfSponsor.
+func readRelayer(userPrivateKey *ecdsa.PrivateKey, prompt string, opts TxOptions) (*relayerSession, func(), error) {
+	relayer, release, err := openRelayer(userPrivateKey, prompt, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	// With --relayer-same-as-user the account's nonce is the transaction's
+	nonce := opts.RelayerNonce
+	if opts.SelfSponsor {
+		nonce = opts.VictimNonce
+	}
+	if nonce != nil {
+		relayer.nonces.Pin(*nonce)
+	}
+	return relayer, release, nil
+}
+
+// openRelayer sets up the relayer session of readRelayer from the configured key source
+func openRelayer(userPrivateKey *ecdsa.PrivateKey, prompt string, opts TxOptions) (*relayerSession, func(), error) {
+	rpcURL := opts.rpcURLOrDefault()
+	switch {
+	case opts.RelayerSignerURL != "":
+		signer, err := NewRemoteSigner(opts.RelayerSignerURL, opts.RelayerSignerAddress)
+		if err != nil {
+			return nil, nil, err
+		}
+		fmt.Fprintf(promptOutput, "\nRelayer %s is signed for by the remote signer\n", signer.Address().Hex())
+		return newRelayerSession(rpcURL, signer), func() {}, nil
+	case opts.RelayerAzureKey != "":
+		signer, err := NewAzureKeyVaultSigner(opts.RelayerAzureKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		fmt.Fprintf(promptOutput, "\nRelayer %s is signed for by Azure Key Vault\n", signer.Address().Hex())
+		return newRelayerSession(rpcURL, signer), func() {}, nil
+	case opts.FireblocksVault != "":
+		note := opts.FireblocksNote
+		if note == "" {
+			note = "eip7702cleaner relayer transaction"
+		}
+		signer, err := NewFireblocksSigner(opts.FireblocksVault, opts.FireblocksAsset, note)
+		if err != nil {
+			return nil, nil, err
+		}
+		fmt.Fprintf(promptOutput, "\nRelayer %s is signed for by Fireblocks vault account %s\n", signer.Address().Hex(), opts.FireblocksVault)
+		return newRelayerSession(rpcURL, signer), func() {}, nil
+	case opts.RelayerLedger:
+		signer, err := OpenLedgerSigner(opts.RelayerHDPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		fmt.Fprintf(promptOutput, "\nRelayer %s is signed for by the Ledger, approve the transaction on the device when asked\n", signer.Address().Hex())
+		return newRelayerSession(rpcURL, signer), func() { signer.Close() }, nil
+	case opts.SelfSponsor:
+		return newRelayerSession(rpcURL, NewKeySigner(userPrivateKey)), func() {}, nil
+	}
+
+	key, err := opts.relayerKeySource().load(color.Reset, prompt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading relayer private key: %w", err)
+	}
+	return newRelayerSession(rpcURL, NewKeySigner(key)), func() { zeroKey(key) }, nil
+}
+
+// relayerSession is the gas-paying account shared by every transaction of a session
+type relayerSession struct {
+	signer Signer
+	nonces *nonceManager
+}
+
+// newRelayerSession sets up a relayer for the given signer
+func newRelayerSession(rpcURL string, signer Signer) *relayerSession {
+	return &relayerSession{
+		signer: signer,
+		nonces: &nonceManager{rpcURL: rpcURL, address: signer.Address()},
+	}
+}
+
+// Address returns the relayer address
+func (r *relayerSession) Address() common.Address {
+	return r.signer.Address()
+}
+
+// validate checks once, up front, that the relayer can pay for gas and reads its nonce
+func (r *relayerSession) validate() error {
+	balance, err := getBalance(r.nonces.rpcURL, r.Address().Hex())
+	if err != nil {
+		return fmt.Errorf("failed to get relayer balance: %w", err)
+	}
+	if balance.Sign() == 0 {
+		return fmt.Errorf("relayer %s has no funds to pay for gas", r.Address().Hex())
+	}
+	if _, err := r.nonces.Next(); err != nil {
+		return fmt.Errorf("failed to get relayer nonce: %w", err)
+	}
+	fmt.Fprintf(promptOutput, "Relayer balance: %.9f ETH\n", weiToEth(balance))
+	return nil
+}
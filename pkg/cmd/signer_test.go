@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNonceManager(t *testing.T) {
+	server := httptest.NewServer(&rpcStub{})
+	defer server.Close()
+
+	// The stub reports a pending nonce of 9
+	tests := []struct {
+		name  string
+		steps func(*nonceManager)
+		want  uint64
+	}{
+		{"read from the node", func(*nonceManager) {}, 9},
+		{"committed", func(m *nonceManager) { m.Next(); m.Commit(); m.Commit() }, 11},
+		{"pinned", func(m *nonceManager) { m.Pin(3) }, 3},
+		{"pinned and committed", func(m *nonceManager) { m.Pin(3); m.Commit() }, 4},
+		{"reset re-reads the node", func(m *nonceManager) { m.Next(); m.Commit(); m.Reset() }, 9},
+		{"reset drops a pin", func(m *nonceManager) { m.Pin(3); m.Reset() }, 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &nonceManager{rpcURL: server.URL, address: testKey(t, 2).Address()}
+			tt.steps(m)
+			got, err := m.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("Next = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/fatih/color"
+)
+
+// errOverrideUnsupported is returned when the node rejects the eth_call state override parameter
+var errOverrideUnsupported = errors.New("the node does not support eth_call state overrides")
+
+// ethCallCleared executes a read-only call from an account as if its code had
+// been cleared, using the eth_call state override set
+func ethCallCleared(rpcURL string, from, to common.Address, value *big.Int, data []byte) ([]byte, error) {
+	call := map[string]interface{}{
+		"from": from.Hex(),
+		"to":   to.Hex(),
+		"data": "0x" + hex.EncodeToString(data),
+	}
+	if value != nil && value.Sign() > 0 {
+		call["value"] = "0x" + value.Text(16)
+	}
+	body := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_call",
+		"params": []interface{}{
+			call,
+			"latest",
+			map[string]interface{}{from.Hex(): map[string]interface{}{"code": "0x"}},
+		},
+	}
+
+	responseBody, err := makeRPCCall(rpcURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Result string `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		return nil, err
+	}
+	if result.Error != nil {
+		// -32602 invalid params / -32601 method not found: the third parameter was refused
+		if result.Error.Code == -32602 || result.Error.Code == -32601 {
+			return nil, fmt.Errorf("%w: %s", errOverrideUnsupported, result.Error.Message)
+		}
+		return nil, fmt.Errorf("eth_call failed: %s", result.Error.Message)
+	}
+
+	return hex.DecodeString(strings.TrimPrefix(result.Result, "0x"))
+}
+
+// SimulateRescue previews the sweep that would follow a clear of victim by
+// simulating it against current state with the victim's code overridden to
+// empty. Nothing is signed or broadcast. Nodes without state override support
+// are reported and the simulation is skipped.
+func SimulateRescue(victim string, opts TxOptions) error {
+	if !common.IsHexAddress(victim) {
+		return invalidInput("invalid victim address format: %s", victim)
+	}
+	if !common.IsHexAddress(opts.SafeAddress) {
+		return invalidInput("--simulate-with-state-override requires a valid --safe-address")
+	}
+	victimAddress := common.HexToAddress(victim)
+	safeAddress := common.HexToAddress(opts.SafeAddress)
+	rpcURL := opts.rpcURLOrDefault()
+
+	color.Cyan("Simulating the rescue of %s as if its delegation were cleared", victimAddress.Hex())
+
+	// Probe support with a call that cannot fail on its own
+	if _, err := ethCallCleared(rpcURL, victimAddress, safeAddress, nil, nil); err != nil {
+		if errors.Is(err, errOverrideUnsupported) {
+			color.Yellow("State overrides are not supported by this node, the simulation was skipped: %v", err)
+			return nil
+		}
+		return fmt.Errorf("simulation failed: %w", err)
+	}
+
+	failures := 0
+	for _, token := range opts.SweepTokens {
+		info := getTokenInfo(rpcURL, token)
+		balance, err := getTokenBalance(rpcURL, token, victimAddress)
+		if err != nil {
+			color.Yellow("? %s: failed to read balance: %v", info.label(token), err)
+			continue
+		}
+		if balance.Sign() == 0 {
+			fmt.Printf("- %s: zero balance, nothing to transfer\n", info.label(token))
+			continue
+		}
+
+		result, err := ethCallCleared(rpcURL, victimAddress, token, nil, tokenTransferData(safeAddress, balance))
+		// Tokens that return nothing are treated as successful, as with a real transfer
+		if err != nil || (len(result) >= 32 && new(big.Int).SetBytes(result[:32]).Sign() == 0) {
+			failures++
+			color.Red("✗ Transfer of %s from %s would fail: %v", info.format(balance), token.Hex(), err)
+			continue
+		}
+		color.Green("✓ Transfer of %s from %s would succeed", info.format(balance), token.Hex())
+	}
+
+	balance, err := getBalance(rpcURL, victimAddress.Hex())
+	if err != nil {
+		return fmt.Errorf("failed to get victim balance: %w", err)
+	}
+	_, gasFeeCap, err := getSuggestedGasFees(rpcURL)
+	if err != nil {
+		return fmt.Errorf("failed to get suggested gas fees: %w", err)
+	}
+	amount := new(big.Int).Sub(balance, maxGasCost(gasFeeCap, transferGasLimit))
+	if amount.Sign() <= 0 {
+		fmt.Println("- ETH: the balance does not cover the sweep gas, nothing to transfer")
+	} else if _, err := ethCallCleared(rpcURL, victimAddress, safeAddress, amount, nil); err != nil {
+		failures++
+		color.Red("✗ Sweep of %.9f ETH would fail: %v", weiToEth(amount), err)
+	} else {
+		color.Green("✓ Sweep of %.9f ETH would succeed", weiToEth(amount))
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%d simulated rescue step(s) would fail", failures)
+	}
+	color.Green("\nThe rescue plan succeeds against current state once the delegation is cleared.")
+	return nil
+}
@@ -0,0 +1,281 @@
+package cmd
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/fatih/color"
+)
+
+// DefaultReplacementBump is the fee increase of speedup and cancel in percent
+// when none is given, with some margin over the minimum nodes require of a
+// replacement
+const DefaultReplacementBump = 12.5
+
+// pendingTx is a transaction still waiting to be mined, fetched to be replaced
+type pendingTx struct {
+	hash      string
+	sender    common.Address
+	chainID   *big.Int
+	nonce     uint64
+	gasTip    *big.Int
+	gasFeeCap *big.Int
+	tx        *Transaction
+}
+
+// fetchPendingTx fetches the transaction txHash and checks it can still be
+// replaced: the node knows it, it is not mined, and no mined transaction used
+// its nonce
+func fetchPendingTx(ctx context.Context, rpcURL, txHash string) (*pendingTx, error) {
+	if hash, err := hexutil.Decode(txHash); err != nil || len(hash) != common.HashLength {
+		return nil, invalidInput("invalid transaction hash %s", txHash)
+	}
+	tx, err := getTransactionByHash(ctx, rpcURL, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction %s: %w", txHash, err)
+	}
+	switch {
+	case tx == nil:
+		return nil, fmt.Errorf("the node does not know transaction %s: it may have been dropped, or not have reached this node", txHash)
+	case tx.BlockNumber != nil:
+		return nil, fmt.Errorf("transaction %s is already mined in block %s, there is nothing to replace", txHash, *tx.BlockNumber)
+	case tx.ChainID == nil || tx.MaxFeePerGas == nil || tx.MaxPriorityFeePerGas == nil:
+		return nil, invalidInput("transaction %s of type %s has no EIP-1559 fees, it was not sent by this tool", txHash, tx.Type)
+	}
+	nonce, err := hexutil.DecodeUint64(tx.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nonce %q of transaction %s: %w", tx.Nonce, txHash, err)
+	}
+
+	sender := common.HexToAddress(tx.From)
+	mined, err := getNonce(rpcURL, sender.Hex())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sender nonce: %w", err)
+	}
+	if uint64(mined) > nonce {
+		return nil, fmt.Errorf("nonce %d of %s is already used by a mined transaction, %s can no longer be replaced", nonce, sender.Hex(), txHash)
+	}
+	return &pendingTx{
+		hash:      txHash,
+		sender:    sender,
+		chainID:   tx.ChainID.ToInt(),
+		nonce:     nonce,
+		gasTip:    tx.MaxPriorityFeePerGas.ToInt(),
+		gasFeeCap: tx.MaxFeePerGas.ToInt(),
+		tx:        tx,
+	}, nil
+}
+
+// print shows the pending transaction about to be replaced
+func (p *pendingTx) print() {
+	fmt.Fprintf(promptOutput, "\nPending transaction %s:\n", p.hash)
+	fmt.Fprintf(promptOutput, "  Chain:            %s\n", chainLabel(p.chainID))
+	fmt.Fprintf(promptOutput, "  Sender:           %s (nonce %d)\n", p.sender.Hex(), p.nonce)
+	fmt.Fprintf(promptOutput, "  Max fee per gas:  %.6f Gwei\n", weiToGwei(p.gasFeeCap))
+	fmt.Fprintf(promptOutput, "  Priority fee:     %.6f Gwei\n", weiToGwei(p.gasTip))
+	fmt.Fprintf(promptOutput, "  Gas limit:        %d\n", uint64(p.tx.Gas))
+	for i, auth := range p.tx.AuthorizationList {
+		authority := "unrecoverable signature"
+		if address, err := auth.Authority(); err == nil {
+			authority = address.Hex()
+		}
+		action := "delegates to " + auth.Address.Hex()
+		if auth.Address == (common.Address{}) {
+			action = "clears its delegation"
+		}
+		fmt.Fprintf(promptOutput, "  Authorization #%d: %s %s (nonce %d)\n", i+1, authority, action, auth.Nonce)
+	}
+}
+
+// replacementFees returns the fees of a replacement of p: GasTip and GasFeeCap
+// when pinned, or else p's fees bumped by percent and raised to the network's
+// current suggestion, the max fee capped at MaxFeeCap. It fails when they are
+// below what nodes accept as a replacement.
+func (p *pendingTx) replacementFees(percent float64, opts TxOptions) (*big.Int, *big.Int, error) {
+	var gasTip, gasFeeCap *big.Int
+	if opts.feesPinned() {
+		var err error
+		if gasTip, gasFeeCap, err = opts.pinnedGasFees(p.chainID); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		factor := 1 + percent/100
+		gasTip, gasFeeCap = bumpFee(p.gasTip, factor), bumpFee(p.gasFeeCap, factor)
+		// The base fee may have risen past the bump since the transaction was sent
+		if suggestedTip, suggestedFeeCap, err := networkGasFees(opts.rpcURLOrDefault(), p.chainID); err == nil {
+			gasTip, gasFeeCap = bigMax(gasTip, suggestedTip), bigMax(gasFeeCap, suggestedFeeCap)
+		}
+		if opts.MaxFeeCap != nil && gasFeeCap.Cmp(opts.MaxFeeCap) > 0 {
+			gasFeeCap = new(big.Int).Set(opts.MaxFeeCap)
+		}
+		if gasTip.Cmp(gasFeeCap) > 0 {
+			gasTip = new(big.Int).Set(gasFeeCap)
+		}
+	}
+
+	minTip, minFeeCap := minReplacementFee(p.gasTip), minReplacementFee(p.gasFeeCap)
+	if gasTip.Cmp(minTip) < 0 || gasFeeCap.Cmp(minFeeCap) < 0 {
+		return nil, nil, invalidInput("nodes only accept a replacement paying %d%% more: a priority fee of at least %.6f Gwei and a max fee of at least %.6f Gwei, not %.6f and %.6f Gwei",
+			minReplacementBump, weiToGwei(minTip), weiToGwei(minFeeCap), weiToGwei(gasTip), weiToGwei(gasFeeCap))
+	}
+	return gasTip, gasFeeCap, nil
+}
+
+// replacementBump returns the fee increase of a replacement, Bump or
+// DefaultReplacementBump, refusing one nodes would not accept
+func (o TxOptions) replacementBump() (float64, error) {
+	switch {
+	case o.Bump == 0:
+		return DefaultReplacementBump, nil
+	case o.Bump < minReplacementBump:
+		return 0, invalidInput("--bump must be at least %d%%, nodes reject smaller replacements", minReplacementBump)
+	}
+	return o.Bump, nil
+}
+
+// readSender reads the key of the sender of p, from the relayer key sources
+func readSender(p *pendingTx, opts TxOptions) (*relayerSession, func(), error) {
+	relayer, release, err := readRelayer(nil, fmt.Sprintf("\nPlease enter the private key of the sender %s:", p.sender.Hex()), opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if relayer.Address() != p.sender {
+		release()
+		return nil, nil, invalidInput("the key entered is for %s, the transaction was sent by %s", relayer.Address().Hex(), p.sender.Hex())
+	}
+	return relayer, release, nil
+}
+
+// submitReplacement broadcasts signedTx in place of p, or prints it with
+// DryRun or writes it out with TxOut or NoBroadcast, then waits for it unless NoWait is set
+func submitReplacement(p *pendingTx, signedTx string, opts TxOptions) error {
+	if opts.DryRun {
+		return printDryRun(signedTx)
+	}
+	if opts.TxOut != "" || opts.NoBroadcast {
+		return writeSignedTx(signedTx, opts)
+	}
+	rpcURL := opts.rpcURLOrDefault()
+	ctx, cancel := context.WithCancel(opts.baseContext())
+	if opts.MaxWait > 0 {
+		ctx, cancel = context.WithTimeout(opts.baseContext(), opts.MaxWait)
+	}
+	defer cancel()
+
+	opts.console().info("Broadcasting the replacement...")
+	txHash, err := broadcastRawTx(ctx, signedTx, rpcURL)
+	if err != nil {
+		// Most likely the original was mined in the meantime
+		return fmt.Errorf("the replacement was rejected, %s may have been mined meanwhile: %w", p.hash, err)
+	}
+	printTxHash("\nReplacement transaction sent! Transaction hash:", txHash)
+	if opts.NoWait {
+		return nil
+	}
+	receipt, err := waitForMined(ctx, rpcURL, txHash, opts)
+	if err != nil {
+		return err
+	}
+	if receipt == nil {
+		notice(color.FgYellow, "The replacement is not mined yet. Either it or %s will be, as both use nonce %d of %s.", p.hash, p.nonce, p.sender.Hex())
+		return nil
+	}
+	notice(color.FgGreen, "✓ The replacement was mined in block %s, %s will not be", receipt.BlockNumber, p.hash)
+	return nil
+}
+
+// Speedup performs the speedup command: it rebuilds the pending EIP-7702
+// transaction txHash with the same nonce, call and authorizations but higher
+// fees, has the sender sign it again and broadcasts it in place of the
+// original. The authorizations are carried as signed, so only the key of the
+// sender, the relayer, is needed.
+func Speedup(txHash string, opts TxOptions) error {
+	percent, err := opts.replacementBump()
+	if err != nil {
+		return err
+	}
+	for _, validate := range []func() error{opts.validateTxOut, opts.validateKeystores} {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+
+	rpcCtx, cancel := context.WithTimeout(opts.baseContext(), time.Minute)
+	defer cancel()
+	p, err := fetchPendingTx(rpcCtx, opts.rpcURLOrDefault(), strings.TrimSpace(txHash))
+	if err != nil {
+		return err
+	}
+	if p.tx.Type != "0x4" || p.tx.To == nil {
+		return invalidInput("transaction %s is of type %s, speedup only rebuilds EIP-7702 (type 0x4) transactions", p.hash, p.tx.Type)
+	}
+	p.print()
+
+	gasTip, gasFeeCap, err := p.replacementFees(percent, opts)
+	if err != nil {
+		return err
+	}
+	value := new(big.Int)
+	if p.tx.Value != nil {
+		value = p.tx.Value.ToInt()
+	}
+	replacement := &SetCodeTx{
+		ChainID:    p.chainID,
+		Nonce:      p.nonce,
+		GasTipCap:  gasTip,
+		GasFeeCap:  gasFeeCap,
+		Gas:        uint64(p.tx.Gas),
+		To:         *p.tx.To,
+		Value:      value,
+		Data:       p.tx.Input,
+		AccessList: p.tx.AccessList,
+		AuthList:   p.tx.AuthorizationList,
+	}
+	if opts.feesPinned() {
+		fmt.Fprintln(promptOutput, "\nReplacement fees, from --max-fee/--priority-fee:")
+	} else {
+		fmt.Fprintf(promptOutput, "\nReplacement fees, %g%% over the pending transaction or the current suggestion when higher:\n", percent)
+	}
+	printGasInfo(gasTip, gasFeeCap, replacement.Gas)
+	if err := checkCostCeiling(p.chainID, maxGasCost(gasFeeCap, replacement.Gas), opts); err != nil {
+		return err
+	}
+
+	relayer, release, err := readSender(p, opts)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	unsigned, err := replacement.unsignedBytes()
+	if err != nil {
+		return fmt.Errorf("failed to encode the replacement: %w", err)
+	}
+	signedTx, err := signEIP7702Tx(hex.EncodeToString(unsigned), relayer.signer)
+	if err != nil {
+		return fmt.Errorf("failed to sign the replacement: %w", err)
+	}
+	signed, err := DecodeSetCodeTx(signedTx)
+	if err != nil {
+		return fmt.Errorf("failed to decode the signed replacement: %w", err)
+	}
+	if sender, err := signed.Sender(); err != nil || sender != p.sender {
+		return verificationFailure("the signed replacement does not recover to %s, aborting", p.sender.Hex())
+	}
+
+	if opts.Yes {
+		opts.console().info("\nConfirmation skipped (--yes)")
+	} else if !opts.DryRun {
+		fmt.Fprintf(promptOutput, "\nSend the replacement of %s? (y/n)\n", p.hash)
+		if err := confirmOrCancel(); err != nil {
+			return err
+		}
+	}
+	return submitReplacement(p, signedTx, opts)
+}
@@ -0,0 +1,442 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/fatih/color"
+)
+
+// DYNAMIC_FEE_TX_TYPE is the EIP-1559 transaction type used for sweep transfers
+const DYNAMIC_FEE_TX_TYPE = 0x02
+
+// transferGasLimit is the gas used by a plain ETH transfer to an EOA
+const transferGasLimit = 21000
+
+// getBalance gets the ETH balance of an address in Wei
+func getBalance(rpcURL, address string) (*big.Int, error) {
+	body := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_getBalance",
+		"params":  []interface{}{address, "latest"},
+	}
+
+	responseBody, err := makeRPCCall(rpcURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Result string `json:"result"`
+	}
+
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		return nil, err
+	}
+
+	balance := new(big.Int)
+	balance.SetString(strings.TrimPrefix(result.Result, "0x"), 16)
+
+	return balance, nil
+}
+
+// ERC-20 function selectors
+var (
+	balanceOfSelector = []byte{0x70, 0xa0, 0x82, 0x31} // balanceOf(address)
+	transferSelector  = []byte{0xa9, 0x05, 0x9c, 0xbb} // transfer(address,uint256)
+)
+
+// defaultTokenTransferGas is used when a token transfer cannot be estimated
+const defaultTokenTransferGas = 100000
+
+// ethCall executes a read-only call against the latest block and returns the result bytes
+func ethCall(rpcURL string, to common.Address, data []byte) ([]byte, error) {
+	body := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_call",
+		"params": []interface{}{
+			map[string]interface{}{"to": to.Hex(), "data": "0x" + hex.EncodeToString(data)},
+			"latest",
+		},
+	}
+
+	responseBody, err := makeRPCCall(rpcURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Result string `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		return nil, err
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("eth_call failed: %s", result.Error.Message)
+	}
+
+	return hex.DecodeString(strings.TrimPrefix(result.Result, "0x"))
+}
+
+// estimateGas asks the node how much gas a call from an address would use
+func estimateGas(rpcURL string, from, to common.Address, data []byte) (uint64, error) {
+	body := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_estimateGas",
+		"params": []interface{}{
+			map[string]interface{}{"from": from.Hex(), "to": to.Hex(), "data": "0x" + hex.EncodeToString(data)},
+		},
+	}
+
+	responseBody, err := makeRPCCall(rpcURL, body)
+	if err != nil {
+		return 0, err
+	}
+
+	var result struct {
+		Result string `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		return 0, err
+	}
+	if result.Error != nil {
+		return 0, fmt.Errorf("eth_estimateGas failed: %s", result.Error.Message)
+	}
+
+	gas, ok := new(big.Int).SetString(strings.TrimPrefix(result.Result, "0x"), 16)
+	if !ok || !gas.IsUint64() {
+		return 0, fmt.Errorf("invalid gas estimate: %s", result.Result)
+	}
+	return gas.Uint64(), nil
+}
+
+// getTokenBalance returns the ERC-20 balance of holder in the token's smallest unit
+func getTokenBalance(rpcURL string, token, holder common.Address) (*big.Int, error) {
+	data := append(append([]byte{}, balanceOfSelector...), common.LeftPadBytes(holder.Bytes(), 32)...)
+	result, err := ethCall(rpcURL, token, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) < 32 {
+		return nil, fmt.Errorf("unexpected balanceOf result from %s", token.Hex())
+	}
+	return new(big.Int).SetBytes(result[:32]), nil
+}
+
+// tokenTransferData encodes transfer(to, amount)
+func tokenTransferData(to common.Address, amount *big.Int) []byte {
+	data := append([]byte{}, transferSelector...)
+	data = append(data, common.LeftPadBytes(to.Bytes(), 32)...)
+	return append(data, common.LeftPadBytes(amount.Bytes(), 32)...)
+}
+
+// ParseTokenList parses a comma separated list of token addresses
+func ParseTokenList(list string) ([]common.Address, error) {
+	var tokens []common.Address
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !common.IsHexAddress(item) {
+			return nil, invalidInput("invalid token address format: %s", item)
+		}
+		tokens = append(tokens, common.HexToAddress(item))
+	}
+	return tokens, nil
+}
+
+// buildDynamicFeeTx builds and signs an EIP-1559 transaction, returning its raw hex
+func buildDynamicFeeTx(
+	chainId *big.Int,
+	sender Signer,
+	nonce uint64,
+	gasTip *big.Int,
+	gasFeeCap *big.Int,
+	gasLimit uint64,
+	to common.Address,
+	value *big.Int,
+	data []byte,
+) (string, error) {
+	fields := []interface{}{
+		chainId, nonce, gasTip, gasFeeCap, gasLimit, to, value, data,
+		[]interface{}{}, // access_list
+	}
+	payload, err := rlp.EncodeToBytes(fields)
+	if err != nil {
+		return "", err
+	}
+
+	sig, err := signTypedPayload(sender, append([]byte{DYNAMIC_FEE_TX_TYPE}, payload...))
+	if err != nil {
+		return "", err
+	}
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:64])
+	yParity := uint8(sig[64])
+
+	finalPayload, err := rlp.EncodeToBytes(append(fields, yParity, r, s))
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(append([]byte{DYNAMIC_FEE_TX_TYPE}, finalPayload...)), nil
+}
+
+// sweepETH moves the victim's remaining ETH to the safe address after a successful
+// clear. The transfer is signed by the (now un-delegated) victim key and pays its
+// own gas, so the gas cost is reserved from the balance before computing the amount.
+func sweepETH(victimPrivateKey *ecdsa.PrivateKey, safeAddress common.Address, auth *authResult, opts TxOptions) error {
+	rpcURL := opts.rpcURLOrDefault()
+	out := opts.console()
+	victimAddress := crypto.PubkeyToAddress(victimPrivateKey.PublicKey)
+
+	notice(color.FgCyan, "\nFund sweep to %s", safeAddress.Hex())
+
+	balance, err := getBalance(rpcURL, victimAddress.Hex())
+	if err != nil {
+		return fmt.Errorf("failed to get victim balance: %w", err)
+	}
+
+	// Re-fetch fees, the clear may have taken a while to be mined
+	gasTip, gasFeeCap, err := opts.gasFees(auth.ChainID)
+	if err != nil {
+		return fmt.Errorf("failed to get suggested gas fees: %w", err)
+	}
+	reserve := maxGasCost(gasFeeCap, transferGasLimit)
+	amount := new(big.Int).Sub(balance, reserve)
+
+	fmt.Fprintf(promptOutput, "Victim balance: %.9f ETH\n", weiToEth(balance))
+	fmt.Fprintf(promptOutput, "Reserved for gas: %.9f ETH\n", weiToEth(reserve))
+	if amount.Sign() <= 0 {
+		notice(color.FgYellow, "Nothing to sweep: the balance does not cover the transfer gas.")
+		return nil
+	}
+	fmt.Fprintf(promptOutput, "Amount to sweep: %.9f ETH\n", weiToEth(amount))
+
+	if opts.Yes {
+		out.info("Confirmation skipped (--yes)")
+	} else {
+		notice(color.FgYellow, "\nSweep %.9f ETH from %s to %s? (y/n)", weiToEth(amount), victimAddress.Hex(), safeAddress.Hex())
+		if agreed, err := askConfirmation(); err != nil {
+			return err
+		} else if !agreed {
+			fmt.Fprintln(promptOutput, "Sweep skipped.")
+			return nil
+		}
+	}
+
+	// Pending, so that a token transfer still waiting to be mined does not share its nonce
+	nonce, err := getNonceAt(rpcURL, victimAddress.Hex(), "pending")
+	if err != nil {
+		return fmt.Errorf("failed to get victim nonce: %w", err)
+	}
+
+	signedTx, err := buildDynamicFeeTx(auth.ChainID, NewKeySigner(victimPrivateKey), uint64(nonce), gasTip, gasFeeCap,
+		transferGasLimit, safeAddress, amount, []byte{})
+	if err != nil {
+		return fmt.Errorf("failed to build sweep transaction: %w", err)
+	}
+
+	ctx, cancel := auth.context()
+	defer cancel()
+
+	out.info("Broadcasting sweep transaction...")
+	txHash, err := broadcastRawTx(ctx, signedTx, rpcURL)
+	if err != nil {
+		if ctx.Err() != nil {
+			notice(color.FgYellow, "%s before the node acknowledged the sweep; look up %s before retrying.", waitEnded(ctx), signedTxHash(signedTx))
+		}
+		return fmt.Errorf("failed to broadcast sweep transaction: %w", err)
+	}
+	printTxHash("Sweep transaction hash:", txHash)
+
+	receipt, err := waitForMined(ctx, rpcURL, txHash, opts)
+	symbol := nativeSymbol(auth.ChainID)
+	auth.recordSweep(symbol, amount, fmt.Sprintf("%.9f %s", weiToEth(amount), symbol), txHash, receipt, err)
+	return err
+}
+
+// sweepRecord is a transfer made by the fund sweep, for the rescue report
+type sweepRecord struct {
+	Asset   string `json:"asset"`   // Native symbol or token address
+	Amount  string `json:"amount"`  // Wei for the native asset, raw units for tokens
+	Display string `json:"display"` // Amount in whole units with the symbol, e.g. "1,234.56 USDC"
+	TxHash  string `json:"txHash"`
+	Status  string `json:"status"` // confirmed, pending or failed
+}
+
+// recordSweep records the outcome of a sweep transfer
+func (r *authResult) recordSweep(asset string, amount *big.Int, display, txHash string, receipt *TransactionReceipt, err error) {
+	r.Sweeps = append(r.Sweeps, sweepRecord{
+		Asset:   asset,
+		Amount:  amount.String(),
+		Display: display,
+		TxHash:  txHash,
+		Status:  txStatus(receipt, err),
+	})
+}
+
+// txStatus summarizes the outcome of waiting for a transaction
+func txStatus(receipt *TransactionReceipt, err error) string {
+	switch {
+	case receipt != nil:
+		return "confirmed"
+	case errors.Is(err, context.Canceled):
+		// The wait was interrupted, not the transaction
+		return "pending"
+	case err != nil:
+		return "failed"
+	default:
+		return "pending"
+	}
+}
+
+// tokenSweep is a planned ERC-20 transfer to the safe address
+type tokenSweep struct {
+	Token   common.Address
+	Info    tokenInfo
+	Amount  *big.Int
+	GasUsed uint64
+}
+
+// sweepTokens moves the victim's ERC-20 balances to the safe address with one
+// transfer(safe, balanceOf(victim)) transaction per token, signed by the victim
+// and paid from its ETH balance. Tokens with a zero balance are skipped. An
+// error lists the transfers that were not confirmed.
+func sweepTokens(victimPrivateKey *ecdsa.PrivateKey, safeAddress common.Address, tokens []common.Address, auth *authResult, opts TxOptions) error {
+	rpcURL := opts.rpcURLOrDefault()
+	out := opts.console()
+	victimAddress := crypto.PubkeyToAddress(victimPrivateKey.PublicKey)
+
+	notice(color.FgCyan, "\nToken sweep to %s", safeAddress.Hex())
+
+	var plan []tokenSweep
+	var totalGas uint64
+	for _, token := range tokens {
+		info := getTokenInfo(rpcURL, token)
+		balance, err := getTokenBalance(rpcURL, token, victimAddress)
+		if err != nil {
+			notice(color.FgYellow, "Skipping %s: failed to read balance: %v", info.label(token), err)
+			continue
+		}
+		if balance.Sign() == 0 {
+			out.infof("Skipping %s: zero balance\n", info.label(token))
+			continue
+		}
+
+		gas, err := estimateGas(rpcURL, victimAddress, token, tokenTransferData(safeAddress, balance))
+		if err != nil {
+			gas = defaultTokenTransferGas
+		} else {
+			gas = gas * 12 / 10 // 20% headroom over the estimate
+		}
+		fmt.Fprintf(promptOutput, "Token %s: %s\n", info.label(token), info.format(balance))
+		plan = append(plan, tokenSweep{Token: token, Info: info, Amount: balance, GasUsed: gas})
+		totalGas += gas
+	}
+
+	if len(plan) == 0 {
+		fmt.Fprintln(promptOutput, "No token balances to sweep.")
+		return nil
+	}
+
+	gasTip, gasFeeCap, err := opts.gasFees(auth.ChainID)
+	if err != nil {
+		return fmt.Errorf("failed to get suggested gas fees: %w", err)
+	}
+	ethBalance, err := getBalance(rpcURL, victimAddress.Hex())
+	if err != nil {
+		return fmt.Errorf("failed to get victim balance: %w", err)
+	}
+	gasCost := maxGasCost(gasFeeCap, totalGas)
+	fmt.Fprintf(promptOutput, "Estimated max gas cost for %d token transfers: %.9f ETH\n", len(plan), weiToEth(gasCost))
+	if ethBalance.Cmp(gasCost) < 0 {
+		notice(color.FgYellow, "The victim holds %.9f ETH, which does not cover the token transfer gas; skipping the token sweep.", weiToEth(ethBalance))
+		return nil
+	}
+
+	if opts.Yes {
+		out.info("Confirmation skipped (--yes)")
+	} else {
+		notice(color.FgYellow, "\nTransfer these %d token balances to %s? (y/n)", len(plan), safeAddress.Hex())
+		if agreed, err := askConfirmation(); err != nil {
+			return err
+		} else if !agreed {
+			fmt.Fprintln(promptOutput, "Token sweep skipped.")
+			return nil
+		}
+	}
+
+	nonce, err := getNonce(rpcURL, victimAddress.Hex())
+	if err != nil {
+		return fmt.Errorf("failed to get victim nonce: %w", err)
+	}
+
+	ctx, cancel := auth.context()
+	defer cancel()
+
+	var moved []tokenSweep
+	var unmoved []string
+	for i, item := range plan {
+		signedTx, err := buildDynamicFeeTx(auth.ChainID, NewKeySigner(victimPrivateKey), uint64(nonce), gasTip, gasFeeCap,
+			item.GasUsed, item.Token, big.NewInt(0), tokenTransferData(safeAddress, item.Amount))
+		if err != nil {
+			return fmt.Errorf("failed to build transfer for %s: %w", item.Info.label(item.Token), err)
+		}
+
+		txHash, err := broadcastRawTx(ctx, signedTx, rpcURL)
+		if err != nil {
+			notice(color.FgRed, "Failed to broadcast transfer for %s: %v", item.Info.label(item.Token), err)
+			if ctx.Err() != nil {
+				notice(color.FgYellow, "%s; look up %s before retrying.", waitEnded(ctx), signedTxHash(signedTx))
+			}
+			// The nonce may or may not be used now, later transfers cannot be signed safely
+			for _, rest := range plan[i:] {
+				unmoved = append(unmoved, rest.Info.label(rest.Token))
+			}
+			break
+		}
+		nonce++
+		printTxHash(fmt.Sprintf("Transfer of %s sent:", item.Info.format(item.Amount)), txHash)
+
+		receipt, err := waitForMined(ctx, rpcURL, txHash, opts)
+		auth.recordSweep(item.Token.Hex(), item.Amount, item.Info.format(item.Amount), txHash, receipt, err)
+		if err != nil {
+			notice(color.FgRed, "Transfer of %s failed: %v", item.Info.label(item.Token), err)
+		}
+		if receipt == nil || err != nil {
+			unmoved = append(unmoved, item.Info.label(item.Token))
+			continue
+		}
+		moved = append(moved, item)
+	}
+
+	fmt.Fprintf(promptOutput, "\nMoved %d of %d token balances:\n", len(moved), len(plan))
+	for _, item := range moved {
+		fmt.Fprintf(promptOutput, "  %s: %s\n", item.Info.label(item.Token), item.Info.format(item.Amount))
+	}
+	if len(unmoved) > 0 {
+		return fmt.Errorf("%d token transfers did not confirm: %s", len(unmoved), strings.Join(unmoved, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"math/big"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans of this tool
+const tracerName = "github.com/ethanzhrepo/eip7702cleaner"
+
+// tracingFlushTimeout bounds how long exporting the remaining spans may delay the exit
+const tracingFlushTimeout = 5 * time.Second
+
+var (
+	tracerProvider *sdktrace.TracerProvider
+	// operationCtx carries the span of the running command, the parent of RPC spans
+	operationCtx  = context.Background()
+	operationSpan = trace.SpanFromContext(context.Background())
+)
+
+// StartTracing exports OpenTelemetry traces over OTLP/HTTP to endpoint (e.g.
+// http://localhost:4318, "/v1/traces" being the default path) and opens the
+// span of operation, the command being run. Every RPC call then gets a child
+// span. Without an endpoint nothing is set up and spans are no-ops.
+func StartTracing(endpoint, operation string) error {
+	if endpoint == "" {
+		return nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return invalidInput("invalid --otel-endpoint %q, expected an http(s) URL such as http://localhost:4318", endpoint)
+	}
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(u.Host)}
+	if u.Path != "" && u.Path != "/" {
+		options = append(options, otlptracehttp.WithURLPath(u.Path))
+	}
+	if u.Scheme == "http" {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(context.Background(), options...)
+	if err != nil {
+		return err
+	}
+
+	tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName("eip7702cleaner"),
+			semconv.ServiceVersion(Version),
+		)),
+	)
+	otel.SetTracerProvider(tracerProvider)
+	operationCtx, operationSpan = tracer().Start(context.Background(), operation)
+	return nil
+}
+
+// FailOperation records the error that ends the running command on its span
+func FailOperation(err error) {
+	operationSpan.RecordError(err)
+	operationSpan.SetStatus(codes.Error, err.Error())
+	operationSpan.SetAttributes(attribute.String("error.type", ErrorType(err)))
+}
+
+// stopTracing ends the command's span and exports the spans not sent yet
+func stopTracing() {
+	if tracerProvider == nil {
+		return
+	}
+	operationSpan.End()
+	ctx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+	defer cancel()
+	tracerProvider.Shutdown(ctx)
+	tracerProvider = nil
+}
+
+// tracer returns the tracer of the tool, a no-op one unless StartTracing set up an exporter
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// startRPCSpan opens the span of an RPC call, under the span ctx carries or else the command's
+func startRPCSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	parent := ctx
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		parent = trace.ContextWithSpan(ctx, operationSpan)
+	}
+	return tracer().Start(parent, method, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("rpc.system", "jsonrpc"), attribute.String("rpc.method", method)))
+}
+
+// traceChainID attaches the chain the command talks to to its span
+func traceChainID(chainID *big.Int) {
+	operationSpan.SetAttributes(attribute.String("chain.id", chainID.String()))
+}
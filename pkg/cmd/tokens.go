@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	decimalsSelector = []byte{0x31, 0x3c, 0xe5, 0x67} // decimals()
+	symbolSelector   = []byte{0x95, 0xd8, 0x9b, 0x41} // symbol()
+)
+
+// maxTokenDecimals bounds the decimals() values taken at face value, 10^77 being the largest power of ten below 2^256
+const maxTokenDecimals = 77
+
+// tokenInfo is the display metadata of an ERC-20 token
+type tokenInfo struct {
+	Symbol      string // Empty when symbol() is missing or unreadable
+	Decimals    uint8
+	HasDecimals bool // False when decimals() is missing or unreadable, amounts are then shown in raw units
+}
+
+var (
+	tokenInfoMu    sync.Mutex
+	tokenInfoCache = map[common.Address]tokenInfo{}
+)
+
+// getTokenInfo resolves the symbol and decimals of a token with eth_call, once
+// per token and run. Tokens lacking either method are not an error, the
+// corresponding field is left unset.
+func getTokenInfo(rpcURL string, token common.Address) tokenInfo {
+	tokenInfoMu.Lock()
+	info, ok := tokenInfoCache[token]
+	tokenInfoMu.Unlock()
+	if ok {
+		return info
+	}
+
+	if result, err := ethCall(rpcURL, token, decimalsSelector); err == nil && len(result) >= 32 {
+		decimals := new(big.Int).SetBytes(result[:32])
+		if decimals.Cmp(big.NewInt(maxTokenDecimals)) <= 0 {
+			info.Decimals = uint8(decimals.Uint64())
+			info.HasDecimals = true
+		}
+	}
+	if result, err := ethCall(rpcURL, token, symbolSelector); err == nil {
+		info.Symbol = decodeTokenSymbol(result)
+	}
+
+	tokenInfoMu.Lock()
+	tokenInfoCache[token] = info
+	tokenInfoMu.Unlock()
+	return info
+}
+
+// decodeTokenSymbol decodes a symbol() result, either an ABI string or, for
+// older tokens like MKR, a zero padded bytes32. Unprintable symbols are dropped.
+func decodeTokenSymbol(result []byte) string {
+	var symbol []byte
+	switch {
+	case len(result) >= 64 && new(big.Int).SetBytes(result[:32]).Cmp(big.NewInt(32)) == 0:
+		length := new(big.Int).SetBytes(result[32:64])
+		if !length.IsUint64() || length.Uint64() > uint64(len(result)-64) {
+			return ""
+		}
+		symbol = result[64 : 64+length.Uint64()]
+	case len(result) == 32:
+		symbol = bytes.TrimRight(result, "\x00")
+	default:
+		return ""
+	}
+
+	if len(symbol) == 0 || len(symbol) > 32 || !utf8.Valid(symbol) {
+		return ""
+	}
+	for _, r := range string(symbol) {
+		if !unicode.IsPrint(r) {
+			return ""
+		}
+	}
+	return string(symbol)
+}
+
+// label names a token in messages, by symbol and address when the symbol is known
+func (t tokenInfo) label(token common.Address) string {
+	if t.Symbol == "" {
+		return token.Hex()
+	}
+	return fmt.Sprintf("%s (%s)", t.Symbol, token.Hex())
+}
+
+// format shows an amount in whole tokens with thousands separators, e.g.
+// "1,234.56 USDC", or in raw units with a note when the decimals are unknown
+func (t tokenInfo) format(amount *big.Int) string {
+	symbol := t.Symbol
+	if symbol == "" {
+		symbol = "tokens"
+	}
+	if !t.HasDecimals {
+		return fmt.Sprintf("%s raw units of %s (decimals() unavailable)", amount, symbol)
+	}
+	return fmt.Sprintf("%s %s", formatUnits(amount, t.Decimals), symbol)
+}
+
+// formatUnits formats an amount of the smallest unit as a decimal number of
+// whole units, with thousands separators and without trailing zeros
+func formatUnits(amount *big.Int, decimals uint8) string {
+	digits := new(big.Int).Abs(amount).String()
+	if len(digits) <= int(decimals) {
+		digits = strings.Repeat("0", int(decimals)-len(digits)+1) + digits
+	}
+	whole, fraction := digits[:len(digits)-int(decimals)], strings.TrimRight(digits[len(digits)-int(decimals):], "0")
+
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+	formatted := grouped.String()
+	if fraction != "" {
+		formatted += "." + fraction
+	}
+	if amount.Sign() < 0 {
+		formatted = "-" + formatted
+	}
+	return formatted
+}
@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gmath "github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// AccessTuple is an EIP-2930 access list entry
+type AccessTuple struct {
+	Address     common.Address `json:"address"`
+	StorageKeys []common.Hash  `json:"storageKeys"`
+}
+
+// AuthorizationTuple is a signed EIP-7702 authorization as carried in a type 0x04 transaction
+type AuthorizationTuple struct {
+	ChainID *big.Int
+	Address common.Address
+	Nonce   uint64
+	YParity uint8
+	R       *big.Int
+	S       *big.Int
+}
+
+// SetCodeTx is a decoded, signed EIP-7702 (type 0x04) transaction.
+// The field order matches the RLP layout produced by build7702Tx and signEIP7702Tx.
+type SetCodeTx struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasTipCap  *big.Int
+	GasFeeCap  *big.Int
+	Gas        uint64
+	To         common.Address
+	Value      *big.Int
+	Data       []byte
+	AccessList []AccessTuple
+	AuthList   []AuthorizationTuple
+	V          uint8
+	R          *big.Int
+	S          *big.Int
+}
+
+// DecodeSetCodeTx decodes a signed EIP-7702 transaction from its raw hex form
+func DecodeSetCodeTx(rawHex string) (*SetCodeTx, error) {
+	txBytes, err := hex.DecodeString(strings.TrimPrefix(rawHex, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid transaction hex: %w", err)
+	}
+	if len(txBytes) < 1 || txBytes[0] != SET_CODE_TX_TYPE {
+		return nil, errors.New("not a EIP-7702 tx hex")
+	}
+
+	var tx SetCodeTx
+	if err := rlp.DecodeBytes(txBytes[1:], &tx); err != nil {
+		return nil, fmt.Errorf("failed to decode transaction: %w", err)
+	}
+	return &tx, nil
+}
+
+// Hash returns the transaction hash, as reported by eth_sendRawTransaction
+func (tx *SetCodeTx) Hash() (common.Hash, error) {
+	payload, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(append([]byte{SET_CODE_TX_TYPE}, payload...)), nil
+}
+
+// unsignedBytes returns the typed transaction without the relayer signature, as built by build7702Tx
+func (tx *SetCodeTx) unsignedBytes() ([]byte, error) {
+	payload, err := rlp.EncodeToBytes([]interface{}{
+		tx.ChainID, tx.Nonce, tx.GasTipCap, tx.GasFeeCap, tx.Gas, tx.To, tx.Value, tx.Data,
+		tx.AccessList, tx.AuthList,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{SET_CODE_TX_TYPE}, payload...), nil
+}
+
+// signingHash returns the hash the relayer signed, i.e. the payload without the signature
+func (tx *SetCodeTx) signingHash() ([]byte, error) {
+	unsigned, err := tx.unsignedBytes()
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256(unsigned), nil
+}
+
+// signature returns the relayer signature in [R || S || V] form
+func (tx *SetCodeTx) signature() []byte {
+	return signatureBytes(tx.R, tx.S, tx.V)
+}
+
+// signature returns the authorization signature in [R || S || V] form
+func (a AuthorizationTuple) signature() []byte {
+	return signatureBytes(a.R, a.S, a.YParity)
+}
+
+// signatureBytes packs r, s and the recovery id into a 65-byte signature,
+// returning nil if r or s do not fit in 32 bytes
+func signatureBytes(r, s *big.Int, yParity uint8) []byte {
+	if r == nil || s == nil || r.BitLen() > 256 || s.BitLen() > 256 {
+		return nil
+	}
+	sig := make([]byte, crypto.SignatureLength)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:64])
+	sig[64] = yParity
+	return sig
+}
+
+// Sender recovers the address that signed (and pays for) the transaction
+func (tx *SetCodeTx) Sender() (common.Address, error) {
+	hash, err := tx.signingHash()
+	if err != nil {
+		return common.Address{}, err
+	}
+	return recoverAddress(hash, tx.R, tx.S, tx.V)
+}
+
+// Authority recovers the address that signed the authorization
+func (a AuthorizationTuple) Authority() (common.Address, error) {
+	return recoverAddress(authTupleMessage(a.ChainID, a.Address, a.Nonce), a.R, a.S, a.YParity)
+}
+
+// recoverAddress recovers the signer of hash from an r/s/yParity signature
+func recoverAddress(hash []byte, r, s *big.Int, yParity uint8) (common.Address, error) {
+	if yParity > 1 {
+		return common.Address{}, fmt.Errorf("invalid signature y parity: %d", yParity)
+	}
+	pub, err := crypto.Ecrecover(hash, signatureBytes(r, s, yParity))
+	if err != nil {
+		return common.Address{}, err
+	}
+	return common.BytesToAddress(crypto.Keccak256(pub[1:])[12:]), nil
+}
+
+// authorizationJSON is the standard JSON shape of a signed authorization
+type authorizationJSON struct {
+	ChainID *hexutil.Big   `json:"chainId"`
+	Address common.Address `json:"address"`
+	Nonce   hexutil.Uint64 `json:"nonce"`
+	YParity hexutil.Uint64 `json:"yParity"`
+	R       *hexutil.Big   `json:"r"`
+	S       *hexutil.Big   `json:"s"`
+}
+
+// MarshalJSON encodes the authorization in the form wallets and libraries expect
+func (a AuthorizationTuple) MarshalJSON() ([]byte, error) {
+	return json.Marshal(authorizationJSON{
+		ChainID: (*hexutil.Big)(a.ChainID),
+		Address: a.Address,
+		Nonce:   hexutil.Uint64(a.Nonce),
+		YParity: hexutil.Uint64(a.YParity),
+		R:       (*hexutil.Big)(a.R),
+		S:       (*hexutil.Big)(a.S),
+	})
+}
+
+// UnmarshalJSON decodes an authorization from the form nodes return it in
+func (a *AuthorizationTuple) UnmarshalJSON(data []byte) error {
+	var dec authorizationJSON
+	if err := json.Unmarshal(data, &dec); err != nil {
+		return err
+	}
+	if dec.ChainID == nil || dec.R == nil || dec.S == nil {
+		return errors.New("authorization is missing chainId, r or s")
+	}
+	// Out of range values are kept for Authority to reject: invalid
+	// authorizations can still be included in a valid transaction
+	if dec.YParity > math.MaxUint8 {
+		return fmt.Errorf("invalid authorization y parity: %d", dec.YParity)
+	}
+	*a = AuthorizationTuple{
+		ChainID: dec.ChainID.ToInt(),
+		Address: dec.Address,
+		Nonce:   uint64(dec.Nonce),
+		YParity: uint8(dec.YParity),
+		R:       dec.R.ToInt(),
+		S:       dec.S.ToInt(),
+	}
+	return nil
+}
+
+// MarshalJSON encodes the transaction in the EIP-2718 typed transaction JSON form
+func (tx *SetCodeTx) MarshalJSON() ([]byte, error) {
+	hash, err := tx.Hash()
+	if err != nil {
+		return nil, err
+	}
+
+	accessList := tx.AccessList
+	if accessList == nil {
+		accessList = []AccessTuple{}
+	}
+	authList := tx.AuthList
+	if authList == nil {
+		authList = []AuthorizationTuple{}
+	}
+
+	return json.Marshal(struct {
+		Type                 hexutil.Uint64       `json:"type"`
+		ChainID              *hexutil.Big         `json:"chainId"`
+		Nonce                hexutil.Uint64       `json:"nonce"`
+		To                   common.Address       `json:"to"`
+		Gas                  hexutil.Uint64       `json:"gas"`
+		MaxPriorityFeePerGas *hexutil.Big         `json:"maxPriorityFeePerGas"`
+		MaxFeePerGas         *hexutil.Big         `json:"maxFeePerGas"`
+		Value                *hexutil.Big         `json:"value"`
+		Input                hexutil.Bytes        `json:"input"`
+		AccessList           []AccessTuple        `json:"accessList"`
+		AuthorizationList    []AuthorizationTuple `json:"authorizationList"`
+		V                    hexutil.Uint64       `json:"v"`
+		R                    *hexutil.Big         `json:"r"`
+		S                    *hexutil.Big         `json:"s"`
+		YParity              hexutil.Uint64       `json:"yParity"`
+		Hash                 common.Hash          `json:"hash"`
+	}{
+		Type:                 SET_CODE_TX_TYPE,
+		ChainID:              (*hexutil.Big)(tx.ChainID),
+		Nonce:                hexutil.Uint64(tx.Nonce),
+		To:                   tx.To,
+		Gas:                  hexutil.Uint64(tx.Gas),
+		MaxPriorityFeePerGas: (*hexutil.Big)(tx.GasTipCap),
+		MaxFeePerGas:         (*hexutil.Big)(tx.GasFeeCap),
+		Value:                (*hexutil.Big)(tx.Value),
+		Input:                tx.Data,
+		AccessList:           accessList,
+		AuthorizationList:    authList,
+		V:                    hexutil.Uint64(tx.V),
+		R:                    (*hexutil.Big)(tx.R),
+		S:                    (*hexutil.Big)(tx.S),
+		YParity:              hexutil.Uint64(tx.V),
+		Hash:                 hash,
+	})
+}
+
+// RecoverAuthority recovers the signer of an authorization tuple found in the
+// wild from its fields: chain ID, delegation target, nonce and r/s/yParity
+// signature. Numbers may be decimal or 0x-prefixed hex.
+func RecoverAuthority(chainID, contract, nonce, r, s, yParity string) (common.Address, error) {
+	var auth AuthorizationTuple
+	var ok bool
+	if auth.ChainID, ok = gmath.ParseBig256(chainID); !ok {
+		return common.Address{}, invalidInput("invalid chain ID %q", chainID)
+	}
+	if !common.IsHexAddress(contract) {
+		return common.Address{}, invalidInput("invalid contract address format: %s", contract)
+	}
+	auth.Address = common.HexToAddress(contract)
+	if auth.Nonce, ok = gmath.ParseUint64(nonce); !ok {
+		return common.Address{}, invalidInput("invalid nonce %q", nonce)
+	}
+
+	if auth.R, ok = gmath.ParseBig256(r); !ok || auth.R.Sign() == 0 || auth.R.Cmp(secp256k1N) >= 0 {
+		return common.Address{}, invalidInput("invalid r %q: must be a non-zero 256-bit value below the curve order", r)
+	}
+	// EIP-7702 only accepts low-s signatures
+	if auth.S, ok = gmath.ParseBig256(s); !ok || auth.S.Sign() == 0 || auth.S.Cmp(secp256k1HalfN) > 0 {
+		return common.Address{}, invalidInput("invalid s %q: must be non-zero and at most half the curve order", s)
+	}
+	parity, ok := gmath.ParseUint64(yParity)
+	if !ok || parity > 1 {
+		return common.Address{}, invalidInput("invalid yParity %q: must be 0 or 1", yParity)
+	}
+	auth.YParity = uint8(parity)
+
+	return auth.Authority()
+}
+
+// secp256k1 curve order and its half, the bounds of valid signature values
+var (
+	secp256k1N     = crypto.S256().Params().N
+	secp256k1HalfN = new(big.Int).Rsh(secp256k1N, 1)
+)
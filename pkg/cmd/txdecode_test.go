@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDecodeSetCodeTx(t *testing.T) {
+	signedTx, err := GenerateSet7702AuthTx(testRequest(t, testKey(t, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		raw   string
+		err   string
+		nonce uint64
+	}{
+		{"signed transaction", signedTx, "", 3},
+		{"without 0x prefix", strings.TrimPrefix(signedTx, "0x"), "", 3},
+		{"not hex", "0xzz", "invalid transaction hex", 0},
+		{"empty", "0x", "not a EIP-7702 tx hex", 0},
+		{"other type", "0x02" + signedTx[4:], "not a EIP-7702 tx hex", 0},
+		{"truncated", signedTx[:len(signedTx)-10], "failed to decode transaction", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, err := DecodeSetCodeTx(tt.raw)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tx.Nonce != tt.nonce || len(tx.AuthList) != 1 {
+				t.Fatalf("decoded nonce %d with %d authorizations, want nonce %d with 1", tx.Nonce, len(tx.AuthList), tt.nonce)
+			}
+		})
+	}
+}
+
+func TestAuthorizationTupleJSON(t *testing.T) {
+	auth, err := signAuthTuple(big.NewInt(11155111), common.HexToAddress("0x000000000000000000000000000000000000dEaD"), 7, testKey(t, 1).key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := json.Marshal(auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{`"chainId":"0xaa36a7"`, `"nonce":"0x7"`, `"address":"0x000000000000000000000000000000000000dead"`} {
+		if !strings.Contains(string(encoded), field) {
+			t.Errorf("%s does not contain %s", encoded, field)
+		}
+	}
+	var decoded AuthorizationTuple
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, auth) {
+		t.Fatalf("round trip gave %+v, want %+v", decoded, auth)
+	}
+	if authority, err := decoded.Authority(); err != nil || authority != testKey(t, 1).Address() {
+		t.Fatalf("authority %s (%v), want %s", authority.Hex(), err, testKey(t, 1).Address().Hex())
+	}
+}
+
+func TestAuthorizationTupleUnmarshalJSONErrors(t *testing.T) {
+	const valid = `"chainId":"0x1","address":"0x000000000000000000000000000000000000dead","nonce":"0x0","r":"0x1","s":"0x1"`
+	tests := []struct {
+		name, json, err string
+	}{
+		{"missing chain id", `{"address":"0x000000000000000000000000000000000000dead","nonce":"0x0","yParity":"0x0","r":"0x1","s":"0x1"}`, "missing chainId, r or s"},
+		{"missing s", `{"chainId":"0x1","address":"0x000000000000000000000000000000000000dead","nonce":"0x0","yParity":"0x0","r":"0x1"}`, "missing chainId, r or s"},
+		{"y parity out of range", `{` + valid + `,"yParity":"0x100"}`, "invalid authorization y parity"},
+		{"decimal nonce", `{` + valid + `,"yParity":"0x0","nonce":7}`, "cannot unmarshal"},
+		{"not an object", `"0x01"`, "cannot unmarshal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var auth AuthorizationTuple
+			err := json.Unmarshal([]byte(tt.json), &auth)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/fatih/color"
+)
+
+// txChecklist collects the outcome of the checks of ValidateTx
+type txChecklist struct {
+	passed, failed int
+}
+
+func (c *txChecklist) pass(name, format string, a ...interface{}) {
+	c.passed++
+	color.Green("✓ %s: %s", name, fmt.Sprintf(format, a...))
+}
+
+func (c *txChecklist) fail(name, format string, a ...interface{}) {
+	c.failed++
+	color.Red("✗ %s: %s", name, fmt.Sprintf(format, a...))
+}
+
+// done prints the totals and returns an error if any check failed
+func (c *txChecklist) done() error {
+	fmt.Printf("\n%d passed, %d failed\n", c.passed, c.failed)
+	if c.failed > 0 {
+		return verificationFailure("%d of %d checks failed, do not broadcast this transaction", c.failed, c.passed+c.failed)
+	}
+	return nil
+}
+
+// skip reports a check that could not run because an earlier one failed
+func (c *txChecklist) skip(name, reason string) {
+	c.failed++
+	color.Yellow("- %s: skipped, %s", name, reason)
+}
+
+// ValidateTx runs every pre-broadcast check on a signed EIP-7702 transaction
+// and prints a pass/fail checklist: type byte, RLP round trip, chain ID against
+// the node, recovery of the sender and of every authority, nonces against
+// on-chain state, and whether the sender can afford the gas. Nothing is
+// broadcast. An error is returned when any check fails.
+func ValidateTx(rawHex string, opts CheckOptions) error {
+	rpcURL := opts.rpcURLOrDefault()
+	checks := &txChecklist{}
+
+	raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(rawHex), "0x"))
+	if err != nil {
+		return invalidInput("invalid transaction hex: %v", err)
+	}
+	if len(raw) == 0 || raw[0] != SET_CODE_TX_TYPE {
+		typeByte := "none"
+		if len(raw) > 0 {
+			typeByte = fmt.Sprintf("0x%02x", raw[0])
+		}
+		checks.fail("Type", "expected 0x04, got %s", typeByte)
+		return checks.done()
+	}
+	checks.pass("Type", "0x04 (EIP-7702 set code)")
+
+	var tx SetCodeTx
+	if err := rlp.DecodeBytes(raw[1:], &tx); err != nil {
+		checks.fail("RLP", "failed to decode: %v", err)
+		return checks.done()
+	}
+	encoded, err := rlp.EncodeToBytes(&tx)
+	if err != nil || !bytes.Equal(encoded, raw[1:]) {
+		checks.fail("RLP", "decoding and re-encoding does not give back the same bytes (non-canonical encoding)")
+	} else {
+		checks.pass("RLP", "decodes and re-encodes to the same bytes")
+	}
+	if hash, err := tx.Hash(); err == nil {
+		fmt.Printf("  Transaction hash: %s\n", hash.Hex())
+	}
+
+	chainID, err := getChainID(rpcURL)
+	switch {
+	case err != nil:
+		return fmt.Errorf("failed to get chain ID: %w", err)
+	case tx.ChainID == nil || tx.ChainID.Cmp(chainID) != 0:
+		checks.fail("Chain ID", "transaction is for chain %v, the node is on %s", tx.ChainID, chainLabel(chainID))
+	default:
+		checks.pass("Chain ID", "%s, same as the node", chainLabel(chainID))
+	}
+
+	sender, senderErr := tx.Sender()
+	if senderErr != nil {
+		checks.fail("Sender", "signature does not recover: %v", senderErr)
+	} else if tx.S != nil && tx.S.Cmp(secp256k1HalfN) > 0 {
+		checks.fail("Sender", "signature s is not in the lower half of the curve order, nodes reject it")
+	} else {
+		checks.pass("Sender", "%s", sender.Hex())
+	}
+
+	if len(tx.AuthList) == 0 {
+		checks.fail("Authorizations", "the authorization list is empty, nodes reject such a transaction")
+	}
+	authorities := make([]common.Address, len(tx.AuthList))
+	authorityErrs := make([]error, len(tx.AuthList))
+	for i, auth := range tx.AuthList {
+		name := fmt.Sprintf("Authority #%d", i+1)
+		authorities[i], authorityErrs[i] = auth.Authority()
+		switch {
+		case authorityErrs[i] != nil:
+			checks.fail(name, "signature does not recover: %v", authorityErrs[i])
+		case auth.ChainID == nil || (auth.ChainID.Sign() != 0 && auth.ChainID.Cmp(chainID) != 0):
+			checks.fail(name, "%s signed for chain %v, it would be skipped on %s", authorities[i].Hex(), auth.ChainID, chainLabel(chainID))
+		case auth.S.Cmp(secp256k1HalfN) > 0:
+			checks.fail(name, "%s signed with a high s value, the authorization would be skipped", authorities[i].Hex())
+		case auth.Address == (common.Address{}):
+			checks.pass(name, "%s clears its delegation", authorities[i].Hex())
+		default:
+			checks.pass(name, "%s delegates to %s", authorities[i].Hex(), auth.Address.Hex())
+		}
+	}
+
+	if senderErr != nil {
+		checks.skip("Sender nonce", "the sender is unknown")
+		checks.skip("Gas funds", "the sender is unknown")
+	} else {
+		pendingNonce, err := getNonceAt(rpcURL, sender.Hex(), "pending")
+		if err != nil {
+			return fmt.Errorf("failed to get sender nonce: %w", err)
+		}
+		switch {
+		case uint64(pendingNonce) > tx.Nonce:
+			checks.fail("Sender nonce", "transaction nonce %d is already used, the account is at %d", tx.Nonce, pendingNonce)
+		case uint64(pendingNonce) < tx.Nonce:
+			checks.fail("Sender nonce", "transaction nonce %d leaves a gap, the account is at %d; it would stay pending", tx.Nonce, pendingNonce)
+		default:
+			checks.pass("Sender nonce", "%d, the next one of the account", tx.Nonce)
+		}
+
+		balance, err := getBalance(rpcURL, sender.Hex())
+		if err != nil {
+			return fmt.Errorf("failed to get sender balance: %w", err)
+		}
+		cost := new(big.Int).Add(maxGasCost(tx.GasFeeCap, tx.Gas), tx.Value)
+		if balance.Cmp(cost) < 0 {
+			checks.fail("Gas funds", "balance of %.9f ETH does not cover the max cost of %.9f ETH", weiToEth(balance), weiToEth(cost))
+		} else {
+			checks.pass("Gas funds", "balance of %.9f ETH covers the max cost of %.9f ETH", weiToEth(balance), weiToEth(cost))
+		}
+	}
+
+	// Authorizations are applied in order after the sender nonce is incremented,
+	// each one incrementing its authority's nonce
+	expected := map[common.Address]uint64{}
+	for i, auth := range tx.AuthList {
+		name := fmt.Sprintf("Authority #%d nonce", i+1)
+		if authorityErrs[i] != nil {
+			checks.skip(name, "the authority is unknown")
+			continue
+		}
+		authority := authorities[i]
+		if _, seen := expected[authority]; !seen {
+			if senderErr == nil && authority == sender {
+				expected[authority] = tx.Nonce + 1
+			} else {
+				nonce, err := getNonce(rpcURL, authority.Hex())
+				if err != nil {
+					return fmt.Errorf("failed to get authority nonce: %w", err)
+				}
+				expected[authority] = uint64(nonce)
+			}
+		}
+		if auth.Nonce != expected[authority] {
+			checks.fail(name, "signed for nonce %d, %s will be at %d; the authorization would be skipped", auth.Nonce, authority.Hex(), expected[authority])
+		} else {
+			checks.pass(name, "%d, as expected", auth.Nonce)
+			expected[authority]++
+		}
+	}
+
+	if minimum := IntrinsicGas(len(tx.AuthList), tx.Data, tx.AccessList); tx.Gas < minimum {
+		checks.fail("Gas limit", "%d is below the intrinsic cost of %d", tx.Gas, minimum)
+	} else {
+		checks.pass("Gas limit", "%d covers the intrinsic cost of %d", tx.Gas, minimum)
+	}
+
+	return checks.done()
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"math/big"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidateTx(t *testing.T) {
+	server := httptest.NewServer(&rpcStub{})
+	defer server.Close()
+
+	// The stub is on Sepolia with every account at nonce 9
+	tests := []struct {
+		name   string
+		modify func(*SetAuthorizationRequest)
+		raw    func(string) string
+		want   string // Error type, empty when every check passes
+	}{
+		{"every check passes", func(*SetAuthorizationRequest) {}, nil, ""},
+		{"used relayer nonce", func(req *SetAuthorizationRequest) { req.RelayerNonce = 3 }, nil, ErrorTypeVerification},
+		{"relayer nonce gap", func(req *SetAuthorizationRequest) { req.RelayerNonce = 12 }, nil, ErrorTypeVerification},
+		{"stale authorization nonce", func(req *SetAuthorizationRequest) { req.UserEOANonce = 7 }, nil, ErrorTypeVerification},
+		{"other chain", func(req *SetAuthorizationRequest) { req.ChainId = big.NewInt(1) }, nil, ErrorTypeVerification},
+		{"other type", func(*SetAuthorizationRequest) {}, func(raw string) string { return "0x02" + raw[4:] }, ErrorTypeVerification},
+		{"not hex", func(*SetAuthorizationRequest) {}, func(string) string { return "0xzz" }, ErrorTypeInvalidInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testRequest(t, testKey(t, 2))
+			req.UserEOANonce, req.RelayerNonce = 9, 9
+			tt.modify(&req)
+			signedTx, err := GenerateSet7702AuthTx(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.raw != nil {
+				signedTx = tt.raw(signedTx)
+			}
+
+			err = ValidateTx(signedTx, CheckOptions{RPCURL: server.URL})
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("ValidateTx: %v", err)
+				}
+				return
+			}
+			if got := ErrorType(err); got != tt.want {
+				t.Fatalf("ErrorType(%v) = %q, want %q", err, got, tt.want)
+			}
+		})
+	}
+}
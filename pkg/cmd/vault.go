@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// DefaultVaultField is the field of the Vault secret holding the hex private key
+const DefaultVaultField = "private_key"
+
+// vaultSecret is a hex private key stored in a HashiCorp Vault KV secret, read
+// with the token of VAULT_TOKEN from the server at VAULT_ADDR. path is the API
+// path of the secret below /v1/, e.g. secret/data/relayer for the KV version 2
+// engine mounted at secret/, or secret/relayer for version 1.
+type vaultSecret struct {
+	path  string
+	field string
+}
+
+func (v vaultSecret) load(c color.Attribute, prompt string) (*ecdsa.PrivateKey, error) {
+	addr, token := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN")
+	if addr == "" || token == "" {
+		return nil, invalidInput("VAULT_ADDR and VAULT_TOKEN must be set to read a key from Vault")
+	}
+	base, err := url.Parse(strings.TrimRight(addr, "/"))
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, invalidInput("invalid VAULT_ADDR %q", addr)
+	}
+	if base.Scheme == "http" {
+		if ip := net.ParseIP(base.Hostname()); base.Hostname() != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			notice(color.FgYellow, "Warning: VAULT_ADDR is not https, the token and the key cross the network in clear text")
+		}
+	}
+	field := v.field
+	if field == "" {
+		field = DefaultVaultField
+	}
+	fmt.Fprintf(promptOutput, "Reading the key from field %s of Vault secret %s...\n", field, v.path)
+
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base.String()+"/v1/"+strings.TrimLeft(v.path, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	req.Header.Set("User-Agent", userAgent())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to Vault failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the Vault response: %w", err)
+	}
+	// The body holds the key, wipe it once parsed
+	defer zeroBytes(body)
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusForbidden:
+		return nil, errors.New("access to the Vault secret was denied, check VAULT_TOKEN and its policies")
+	case http.StatusNotFound:
+		return nil, invalidInput("no Vault secret at %s (KV version 2 paths include data/, e.g. secret/data/relayer)", v.path)
+	default:
+		return nil, &httpStatusError{status: resp.StatusCode}
+	}
+
+	// The field is kept as raw JSON rather than decoded into a string, so it can be wiped
+	var secret struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return nil, fmt.Errorf("invalid Vault response: %w", err)
+	}
+	fields := secret.Data
+	if nested, ok := fields["data"]; ok && fields["metadata"] != nil {
+		// KV version 2 nests the secret under data.data
+		defer zeroBytes(nested)
+		fields = nil
+		if err := json.Unmarshal(nested, &fields); err != nil {
+			return nil, fmt.Errorf("invalid Vault response: %w", err)
+		}
+	}
+	raw, ok := fields[field]
+	if !ok {
+		return nil, invalidInput("Vault secret %s has no field %q", v.path, field)
+	}
+	defer zeroBytes(raw)
+	if len(raw) < 2 || raw[0] != '"' || raw[len(raw)-1] != '"' || bytes.IndexByte(raw[1:len(raw)-1], '\\') >= 0 {
+		return nil, invalidInput("field %q of Vault secret %s is not a hex private key", field, v.path)
+	}
+	key, err := parseHexKey(raw[1 : len(raw)-1])
+	if err != nil {
+		return nil, fmt.Errorf("field %q of Vault secret %s: %w", field, v.path, err)
+	}
+	return key, nil
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/fatih/color"
+)
+
+// verifyDelegation checks that an address is in the expected state: clean when
+// target is the zero address, delegated to target otherwise
+func verifyDelegation(address common.Address, target common.Address, opts CheckOptions) error {
+	result, err := CheckAddress(address.Hex(), opts)
+	if err != nil {
+		return fmt.Errorf("verification failed: %w", err)
+	}
+
+	if target == (common.Address{}) {
+		switch result.Status {
+		case StatusClean:
+			return nil
+		case StatusDelegated:
+			return verificationFailure("%s is still delegated to %s", address.Hex(), result.Delegate.Hex())
+		default:
+			return verificationFailure("%s has code that is not an EIP-7702 delegation", address.Hex())
+		}
+	}
+
+	switch {
+	case result.Status == StatusDelegated && result.Delegate == target:
+		return nil
+	case result.Status == StatusDelegated:
+		return verificationFailure("%s is delegated to %s, expected %s", address.Hex(), result.Delegate.Hex(), target.Hex())
+	case result.Status == StatusClean:
+		return verificationFailure("%s has no delegation, expected %s", address.Hex(), target.Hex())
+	default:
+		return verificationFailure("%s has code that is not an EIP-7702 delegation", address.Hex())
+	}
+}
+
+// Verify checks that an address is clean (expect "clean") or delegated to the
+// contract to (expect "delegated"), and returns an error describing the actual
+// state when it is not
+func Verify(address, expect, to string, opts CheckOptions) error {
+	if !common.IsHexAddress(address) {
+		return invalidInput("invalid Ethereum address format: %s", address)
+	}
+
+	var target common.Address
+	switch expect {
+	case "clean":
+		if to != "" {
+			return invalidInput("--to cannot be used with --expect clean")
+		}
+	case "delegated":
+		if !common.IsHexAddress(to) {
+			return invalidInput("--expect delegated requires a valid --to address")
+		}
+		target = common.HexToAddress(to)
+	default:
+		return invalidInput("invalid --expect value %q (expected clean or delegated)", expect)
+	}
+
+	if err := verifyDelegation(common.HexToAddress(address), target, opts); err != nil {
+		color.Red("✗ %v", err)
+		// Keep the classification of the cause, an unreachable node is not a failed verification
+		return &TypedError{Type: ErrorType(err), Err: errors.New("verification failed")}
+	}
+	if target == (common.Address{}) {
+		color.Green("✓ Verified: %s has no delegation", common.HexToAddress(address).Hex())
+	} else {
+		color.Green("✓ Verified: %s is delegated to %s", common.HexToAddress(address).Hex(), target.Hex())
+	}
+	return nil
+}
+
+// verificationFailure returns a formatted error classified as ErrorTypeVerification
+func verificationFailure(format string, a ...interface{}) error {
+	return &TypedError{Type: ErrorTypeVerification, Err: fmt.Errorf(format, a...)}
+}
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// DefaultConfirmTimeout is how long waitForMined waits when no timeout is configured
+const DefaultConfirmTimeout = 5 * time.Minute
+
+// DefaultPollInterval is the delay between two status checks of a pending transaction
+const DefaultPollInterval = 5 * time.Second
+
+// txPhase is how far a broadcast transaction has progressed
+type txPhase int
+
+const (
+	phaseBroadcast txPhase = iota // Accepted by eth_sendRawTransaction, not seen since
+	phaseMempool                  // Known to the node, still pending
+	phaseIncluded                 // Mined, not yet deep enough
+	phaseConfirmed                // Mined and buried under the requested number of blocks
+)
+
+func (p txPhase) String() string {
+	switch p {
+	case phaseMempool:
+		return "seen in mempool"
+	case phaseIncluded:
+		return "included in block"
+	case phaseConfirmed:
+		return "confirmed"
+	default:
+		return "broadcast accepted"
+	}
+}
+
+// timeoutAdvice tells the user what to do when the wait ends in this phase
+func (p txPhase) timeoutAdvice() string {
+	switch p {
+	case phaseMempool:
+		return "The transaction is still pending. Wait longer, or replace it with higher gas fees if the network is congested."
+	case phaseIncluded:
+		return "The transaction is mined but not yet deep enough. Wait longer before relying on it."
+	default:
+		return "The node no longer knows the transaction; it may have been dropped. Check the nonce and retry, possibly with higher gas fees."
+	}
+}
+
+// errReverted is returned when a transaction waited for was mined but reverted
+var errReverted = errors.New("transaction failed: reverted")
+
+// waitForMined polls the node until the transaction is confirmed or the wait times out,
+// reporting each phase it goes through: broadcast accepted, seen in mempool,
+// included in block and confirmed N deep.
+// It returns the receipt of a successful transaction, nil if the wait timed out
+// or ctx reached its --max-wait deadline (after reporting the phase it reached),
+// and an error if the transaction was mined but reverted or ctx was cancelled.
+func waitForMined(ctx context.Context, rpcURL, txHash string, opts TxOptions) (*TransactionReceipt, error) {
+	receipt, _, err := waitForPhase(ctx, rpcURL, txHash, opts)
+	return receipt, err
+}
+
+// waitForPhase is waitForMined, also returning the phase the transaction reached
+func waitForPhase(ctx context.Context, rpcURL, txHash string, opts TxOptions) (*TransactionReceipt, txPhase, error) {
+	out := opts.console()
+	confirmations := opts.confirmations()
+	out.infof("\nWaiting for the transaction to be mined (%d confirmation(s), timeout %s)...\n", confirmations, opts.confirmTimeout())
+
+	phase := phaseBroadcast
+	advance := func(next txPhase, detail string) {
+		if next <= phase {
+			return
+		}
+		phase = next
+		out.infof("\n%s%s\n", phase, detail)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, opts.confirmTimeout())
+	defer cancel()
+
+	var receipt *TransactionReceipt
+poll:
+	for {
+		select {
+		case <-waitCtx.Done():
+			break poll
+		case <-time.After(opts.pollInterval()):
+		}
+
+		if phase < phaseIncluded {
+			// Some providers lag on receipts, so optionally detect inclusion through
+			// the transaction's block number and only then ask for the receipt
+			tx, err := getTransactionByHash(waitCtx, rpcURL, txHash)
+			if err == nil && tx != nil {
+				advance(phaseMempool, "")
+				if tx.BlockNumber != nil {
+					advance(phaseIncluded, " "+*tx.BlockNumber)
+				}
+			}
+			if opts.PollViaTxLookup && phase < phaseIncluded {
+				out.infof(".")
+				continue
+			}
+		}
+
+		if receipt == nil {
+			r, err := getTransactionReceipt(waitCtx, rpcURL, txHash)
+			if err != nil || r == nil {
+				out.infof(".")
+				continue
+			}
+			if r.Status == "0x0" {
+				return nil, phaseIncluded, fmt.Errorf("%w in block %s: %s", errReverted, r.BlockNumber, txHash)
+			}
+			receipt = r
+			advance(phaseIncluded, " "+receipt.BlockNumber)
+		}
+
+		depth, err := confirmationDepth(waitCtx, rpcURL, receipt)
+		if err == nil && depth >= confirmations {
+			advance(phaseConfirmed, fmt.Sprintf(" %d deep", depth))
+			notice(color.FgGreen, "\nTransaction successfully mined!")
+			return receipt, phase, nil
+		}
+		out.infof(".")
+	}
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		notice(color.FgYellow, "\n--max-wait expired waiting for %s: last phase reached was %q.", txHash, phase.String())
+	case ctx.Err() != nil:
+		notice(color.FgYellow, "\nInterrupted while waiting for %s: last phase reached was %q.", txHash, phase.String())
+		notice(color.FgYellow, "The transaction was broadcast and may still be mined; look it up before retrying.")
+		return nil, phase, fmt.Errorf("interrupted while waiting for %s: %w", txHash, ctx.Err())
+	default:
+		notice(color.FgYellow, "\nTimed out after %s waiting for %s: last phase reached was %q.", opts.confirmTimeout(), txHash, phase.String())
+	}
+	notice(color.FgYellow, phase.timeoutAdvice())
+	return nil, phase, nil
+}
+
+// waitEnded names why ctx ended, for the messages about the step it cut short:
+// the --max-wait budget running out, or an interruption such as Ctrl+C
+func waitEnded(ctx context.Context) string {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "--max-wait expired"
+	}
+	return "Interrupted"
+}
+
+// confirmationDepth returns how many blocks deep a mined transaction is, 1 in its own block
+func confirmationDepth(ctx context.Context, rpcURL string, receipt *TransactionReceipt) (uint64, error) {
+	head, err := getBlockNumber(ctx, rpcURL)
+	if err != nil {
+		return 0, err
+	}
+	mined, ok := new(big.Int).SetString(strings.TrimPrefix(receipt.BlockNumber, "0x"), 16)
+	if !ok {
+		return 0, fmt.Errorf("invalid receipt block number %q", receipt.BlockNumber)
+	}
+	if head.Cmp(mined) < 0 {
+		return 0, nil
+	}
+	return new(big.Int).Sub(head, mined).Uint64() + 1, nil
+}
+
+// getBlockNumber returns the number of the node's latest block
+func getBlockNumber(ctx context.Context, rpcURL string) (*big.Int, error) {
+	body := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_blockNumber",
+		"params":  []interface{}{},
+	}
+
+	responseBody, err := makeRPCCallContext(ctx, rpcURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Result string `json:"result"`
+	}
+
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		return nil, err
+	}
+
+	number, ok := new(big.Int).SetString(strings.TrimPrefix(result.Result, "0x"), 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid block number %q", result.Result)
+	}
+	return number, nil
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWaitForMinedEnded checks how a wait cut short by its --max-wait deadline
+// or by an interruption is reported
+func TestWaitForMinedEnded(t *testing.T) {
+	server := httptest.NewServer(&rpcStub{})
+	defer server.Close()
+
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	interrupted, interrupt := context.WithCancel(context.Background())
+	interrupt()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		message string
+		err     error
+	}{
+		{"max-wait expired", expired, "--max-wait expired waiting for", nil},
+		{"interrupted", interrupted, "Interrupted while waiting for", context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			savedPrompt := promptOutput
+			promptOutput = &stderr
+			defer func() { promptOutput = savedPrompt }()
+
+			receipt, err := waitForMined(tt.ctx, server.URL, "0x01", TxOptions{})
+			if receipt != nil {
+				t.Fatalf("receipt %+v, want none", receipt)
+			}
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if !strings.Contains(stderr.String(), tt.message) {
+				t.Fatalf("output does not say %q:\n%s", tt.message, stderr.String())
+			}
+		})
+	}
+}